@@ -0,0 +1,72 @@
+package loader
+
+import "fmt"
+
+// AdmissionPolicy decides whether a fetched value is cached, see WithAdmissionPolicy
+type AdmissionPolicy interface {
+	// ShouldCache is called after every successful fetch.
+	// cost is computed by the function of WithCost, or 1 without it.
+	ShouldCache(key, value interface{}, cost int64) bool
+}
+
+// AdmissionFunc is AdmissionPolicy implemented by a function
+type AdmissionFunc func(key, value interface{}, cost int64) bool
+
+// ShouldCache implements AdmissionPolicy
+func (fn AdmissionFunc) ShouldCache(key, value interface{}, cost int64) bool {
+	return fn(key, value, cost)
+}
+
+// AdmitAlways is AdmissionPolicy that caches every value, like a loader without WithAdmissionPolicy
+var AdmitAlways AdmissionPolicy = AdmissionFunc(func(key, value interface{}, cost int64) bool { return true })
+
+// SizeThreshold is AdmissionPolicy that caches the values whose cost is at most maxCost
+func SizeThreshold(maxCost int64) AdmissionPolicy {
+	return AdmissionFunc(func(key, value interface{}, cost int64) bool { return cost <= maxCost })
+}
+
+// TinyLFU is AdmissionPolicy that caches a key once it has been fetched minHits times recently,
+// so one-hit-wonder keys aren't cached at all. Frequencies are estimated by a count-min sketch,
+// see WithTinyLFU, and samples is the expected number of distinct keys.
+func TinyLFU(samples int, minHits int) AdmissionPolicy {
+	sketch := newTinyLFU(samples)
+	if minHits > maxSketchFreq {
+		minHits = maxSketchFreq
+	}
+	return AdmissionFunc(func(key, value interface{}, cost int64) bool {
+		sketch.increment(key)
+		return int(sketch.estimate(key)) >= minHits
+	})
+}
+
+// WithAdmissionPolicy caches only the fetched values admitted by policy.
+// Rejected values are returned to the callers of the fetch, but they are removed from the cache
+// and counted in Stats.Rejected, so the next load fetches them again. The driver must implement Remover.
+func WithAdmissionPolicy(policy AdmissionPolicy) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.admission = policy
+	})
+}
+
+// checkAdmissionPolicy validates the driver used with WithAdmissionPolicy
+func (l *Loader[Key, Value]) checkAdmissionPolicy() error {
+	if l.admission == nil {
+		return nil
+	}
+	if _, ok := l.driver.(Remover); !ok {
+		return fmt.Errorf("%w: WithAdmissionPolicy requires a driver that implements Remover", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// rejected returns true if value must not be cached because of WithAdmissionPolicy
+func (l *Loader[Key, Value]) rejected(key Key, value Value) bool {
+	if l.admission == nil {
+		return false
+	}
+	cost := int64(1)
+	if l.cost != nil {
+		cost = l.cost(value)
+	}
+	return !l.admission.ShouldCache(key, value, cost)
+}
@@ -0,0 +1,119 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditOp is the mutation of an AuditRecord
+type AuditOp string
+
+const (
+	// AuditSet is recorded when a fetched, primed, preloaded, rebuilt or repaired value is stored in the cache.
+	// Values that aren't cached, like the ones rejected by WithMaxValueSize or WithAdmissionPolicy, aren't recorded.
+	// Err is set when a fetch error is cached.
+	AuditSet AuditOp = "set"
+	// AuditInvalidate is recorded when an item is removed by Invalidate, InvalidateAll, its dependencies, a generation rebuild,
+	// a corrupt entry, or a refreshed value that isn't cached
+	AuditInvalidate AuditOp = "invalidate"
+	// AuditRefresh is recorded when a background refresh stores its value, Err is set if it failed and the stale value is kept or replaced by the error
+	AuditRefresh AuditOp = "refresh"
+)
+
+// AuditRecord is a mutation of the cache recorded by WithAuditLog
+type AuditRecord struct {
+	Time time.Time
+	// Loader is the name of the loader, see WithName
+	Loader string
+	Op     AuditOp
+	Key    interface{}
+	// Reason is set by WithAuditReason on the context of the call that caused the mutation
+	Reason string
+	Err    error
+}
+
+// AuditLog records the mutations of a loader, Append is called synchronously so it must be fast
+type AuditLog interface {
+	Append(record AuditRecord)
+}
+
+// AuditFunc is a function that implements AuditLog
+type AuditFunc func(record AuditRecord)
+
+// Append calls f(record)
+func (f AuditFunc) Append(record AuditRecord) {
+	f(record)
+}
+
+// WithAuditLog appends every Set, Invalidate and Refresh of the loader to log: loads, Prime, Preload and WithFetchAll,
+// Invalidate and InvalidateAll, background refreshes, generation rebuilds and read repairs.
+// Periodic preloads, rebuilds, read repairs and dropped corrupt entries are recorded with their own Reason.
+func WithAuditLog(log AuditLog) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.audit = log
+	})
+}
+
+type auditReasonKey struct{}
+
+// WithAuditReason returns ctx that attaches reason to the AuditRecords of the Load, LoadCtx, InvalidateCtx, InvalidateAllCtx,
+// PrimeCtx, Preload or Rebuild called with it.
+// Refreshes are recorded with the reason of the Load that triggered them.
+func WithAuditReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, auditReasonKey{}, reason)
+}
+
+// auditReason returns the reason set by WithAuditReason
+func auditReason(ctx context.Context) string {
+	reason, _ := ctx.Value(auditReasonKey{}).(string)
+	return reason
+}
+
+// record appends the mutation of key to the audit log, if any
+func (l *Loader[Key, Value]) record(ctx context.Context, op AuditOp, key Key, err error) {
+	if l.audit == nil {
+		return
+	}
+	l.audit.Append(AuditRecord{Time: time.Now(), Loader: l.name, Op: op, Key: key, Reason: auditReason(ctx), Err: err})
+}
+
+// NewAuditWriter returns AuditLog that appends the records to w as JSON lines, e.g. to a file opened with os.O_APPEND.
+// Keys are written with fmt.Sprint. onError is called when a write fails, it can be nil.
+func NewAuditWriter(w io.Writer, onError func(err error)) AuditLog {
+	return &auditWriter{w: w, onError: onError}
+}
+
+type auditWriter struct {
+	mutex   sync.Mutex
+	w       io.Writer
+	onError func(err error)
+}
+
+type auditLine struct {
+	Time   time.Time `json:"time"`
+	Loader string    `json:"loader,omitempty"`
+	Op     AuditOp   `json:"op"`
+	Key    string    `json:"key"`
+	Reason string    `json:"reason,omitempty"`
+	Err    string    `json:"error,omitempty"`
+}
+
+func (a *auditWriter) Append(record AuditRecord) {
+	line := auditLine{Time: record.Time, Loader: record.Loader, Op: record.Op, Key: fmt.Sprint(record.Key), Reason: record.Reason}
+	if record.Err != nil {
+		line.Err = record.Err.Error()
+	}
+	data, err := json.Marshal(line)
+	if err == nil {
+		a.mutex.Lock()
+		_, err = a.w.Write(append(data, '\n'))
+		a.mutex.Unlock()
+	}
+	if err != nil && a.onError != nil {
+		a.onError(err)
+	}
+}
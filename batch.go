@@ -0,0 +1,340 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchFetcher fetches the values of many keys at once, keys missing from the result fail with ErrNotInBatch.
+// Returning BatchErrors fails only its keys, other error fails the whole batch.
+type BatchFetcher[Key comparable, Value any] func(ctx context.Context, keys []Key) (map[Key]Value, error)
+
+// ErrNotInBatch is returned for keys that the BatchFetcher doesn't return
+var ErrNotInBatch = errors.New("loader: key is missing from batch result")
+
+// BatchErrors is returned by BatchFetcher along with the values of the succeeded keys when some keys fail.
+// Failed keys are cached with the error ttl, the values are cached with the normal ttl.
+type BatchErrors[Key comparable] map[Key]error
+
+func (e BatchErrors[Key]) Error() string {
+	return fmt.Sprintf("loader: %d keys of the batch failed", len(e))
+}
+
+// batchResult picks the result of key from the result of BatchFetcher
+func batchResult[Key comparable, Value any](values map[Key]Value, err error, key Key) (Value, error) {
+	var zero Value
+	var keyErrs BatchErrors[Key]
+	if errors.As(err, &keyErrs) {
+		if keyErr, ok := keyErrs[key]; ok {
+			return zero, keyErr
+		}
+	} else if err != nil {
+		return zero, err
+	}
+	value, ok := values[key]
+	if !ok {
+		return zero, ErrNotInBatch
+	}
+	return value, nil
+}
+
+// WithBatchWindow makes the loader of NewBatch wait up to window after the first miss, coalescing the misses
+// of concurrent Loads into one batch of up to maxSize keys, like dataloader. Without it every miss is fetched alone.
+func WithBatchWindow(window time.Duration, maxSize int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.batchWindow = window
+		cfg.batchSize = maxSize
+	})
+}
+
+// NewBatch creates Loader that fetches its misses and refreshes with fn, see WithBatchWindow
+func NewBatch[Key comparable, Value any](fn BatchFetcher[Key, Value], ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	b := &batcher[Key, Value]{fn: fn}
+	l := New(b.fetch, ttl, options...)
+	b.window, b.maxSize = l.batchWindow, l.batchSize
+	l.batch = b
+	return l
+}
+
+type batcher[Key comparable, Value any] struct {
+	fn      BatchFetcher[Key, Value]
+	window  time.Duration
+	maxSize int
+
+	mutex   sync.Mutex
+	current *batch[Key, Value]
+	// planned holds the misses of LoadMany that are fetched together
+	planned map[Key]*plannedBatch[Key, Value]
+}
+
+// plannedBatch is the batch of the misses of LoadMany. The misses are fetched through the Fetcher chain of the loader
+// like the misses of Load, so middlewares apply to them, and the batch is flushed once every miss has either joined it
+// or finished without calling the BatchFetcher, e.g. when a middleware returns early.
+type plannedBatch[Key comparable, Value any] struct {
+	batch *batch[Key, Value]
+	// keys are the misses in the order of LoadMany, joined are the ones whose fetch has joined the batch
+	keys   []Key
+	joined map[Key]bool
+	// remaining is the number of misses that haven't joined or finished yet
+	remaining int
+}
+
+type batch[Key comparable, Value any] struct {
+	// ctx is the fetch context of the first key
+	ctx  context.Context
+	keys []Key
+	once sync.Once
+	done chan struct{}
+
+	values map[Key]Value
+	err    error
+}
+
+// fetch is the Fetcher of the loader, it adds key to the current batch and waits for it
+func (b *batcher[Key, Value]) fetch(ctx context.Context, key Key) (Value, error) {
+	b.mutex.Lock()
+	if plan := b.planned[key]; plan != nil {
+		delete(b.planned, key)
+		plan.joined[key] = true
+		if plan.batch.ctx == nil {
+			plan.batch.ctx = ctx
+		}
+		plan.remaining--
+		last := plan.remaining == 0
+		b.mutex.Unlock()
+		if last {
+			b.runPlan(plan)
+		}
+		return b.wait(ctx, plan.batch, key)
+	}
+	current := b.current
+	if current == nil {
+		current = &batch[Key, Value]{ctx: ctx, done: make(chan struct{})}
+		if b.window > 0 {
+			b.current = current
+			time.AfterFunc(b.window, func() { b.flush(current) })
+		}
+	}
+	current.keys = append(current.keys, key)
+	full := b.window <= 0 || (b.maxSize > 0 && len(current.keys) >= b.maxSize)
+	b.mutex.Unlock()
+
+	if full {
+		b.flush(current)
+	}
+	return b.wait(ctx, current, key)
+}
+
+// wait returns the result of key from current once it's fetched
+func (b *batcher[Key, Value]) wait(ctx context.Context, current *batch[Key, Value], key Key) (Value, error) {
+	select {
+	case <-current.done:
+	case <-ctx.Done():
+		var zero Value
+		return zero, ctx.Err()
+	}
+	return batchResult(current.values, current.err, key)
+}
+
+// plan makes the fetches of keys join one batch, see plannedBatch
+func (b *batcher[Key, Value]) plan(keys []Key) {
+	plan := &plannedBatch[Key, Value]{
+		batch:     &batch[Key, Value]{done: make(chan struct{})},
+		keys:      keys,
+		joined:    make(map[Key]bool, len(keys)),
+		remaining: len(keys),
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.planned == nil {
+		b.planned = map[Key]*plannedBatch[Key, Value]{}
+	}
+	for _, key := range keys {
+		b.planned[key] = plan
+	}
+}
+
+// finished is called when the fetch of a planned key returns, it flushes the batch if key was the last one
+// that hasn't joined it
+func (b *batcher[Key, Value]) finished(key Key) {
+	b.mutex.Lock()
+	plan := b.planned[key]
+	if plan == nil {
+		b.mutex.Unlock()
+		return
+	}
+	delete(b.planned, key)
+	plan.remaining--
+	last := plan.remaining == 0
+	b.mutex.Unlock()
+	if last {
+		b.runPlan(plan)
+	}
+}
+
+// runPlan fetches the keys that joined the plan, once every key has joined or finished
+func (b *batcher[Key, Value]) runPlan(plan *plannedBatch[Key, Value]) {
+	for _, key := range plan.keys {
+		if plan.joined[key] {
+			plan.batch.keys = append(plan.batch.keys, key)
+		}
+	}
+	if len(plan.batch.keys) > 0 {
+		b.run(plan.batch)
+	}
+}
+
+// flush fetches the batch once, when its window passes or it's full
+func (b *batcher[Key, Value]) flush(current *batch[Key, Value]) {
+	b.mutex.Lock()
+	if b.current == current {
+		b.current = nil
+	}
+	b.mutex.Unlock()
+
+	b.run(current)
+}
+
+// run fetches the batch once, a panic of the BatchFetcher fails every key of the batch
+func (b *batcher[Key, Value]) run(current *batch[Key, Value]) {
+	current.once.Do(func() {
+		defer close(current.done)
+		defer func() {
+			if r := recover(); r != nil {
+				current.values, current.err = nil, &PanicError{Value: r}
+			}
+		}()
+		current.values, current.err = b.call(current.ctx, current.keys)
+	})
+}
+
+// call calls the BatchFetcher with up to maxSize keys at a time
+func (b *batcher[Key, Value]) call(ctx context.Context, keys []Key) (values map[Key]Value, err error) {
+	size := len(keys)
+	if b.maxSize > 0 && b.maxSize < size {
+		size = b.maxSize
+	}
+	values = make(map[Key]Value, len(keys))
+	keyErrs := BatchErrors[Key]{}
+	for start := 0; start < len(keys); start += size {
+		end := start + size
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+		chunkValues, chunkErr := b.fn(ctx, chunk)
+		for _, key := range chunk {
+			if value, err := batchResult(chunkValues, chunkErr, key); err != nil {
+				keyErrs[key] = err
+			} else {
+				values[key] = value
+			}
+		}
+	}
+	if len(keyErrs) > 0 {
+		return values, keyErrs
+	}
+	return values, nil
+}
+
+// LoadMany loads many keys at once, it returns the values of the succeeded keys and the errors of the failed ones.
+// Misses of a loader created by NewBatch are fetched with one BatchFetcher call per WithBatchWindow maxSize keys,
+// a failed key doesn't fail the others and is cached with the error ttl. Other loaders load the keys concurrently.
+// The misses go through WithMiddleware and the other options like the misses of Load.
+func (l *Loader[Key, Value]) LoadMany(ctx context.Context, keys []Key) (map[Key]Value, map[Key]error) {
+	type result struct {
+		value Value
+		err   error
+	}
+	results := make(map[Key]result, len(keys))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	type miss struct {
+		key   Key
+		item  *cacheItem[Value]
+		stale *payload[Value]
+	}
+	var misses []miss
+	mapped := make(map[Key]Key, len(keys))
+	claimed := make(map[Key]bool, len(keys))
+
+	for _, key := range keys {
+		if _, ok := mapped[key]; ok {
+			continue
+		}
+		mk := l.mapKey(key)
+		mapped[key] = mk
+		if claimed[mk] {
+			continue
+		}
+		claimed[mk] = true
+		if l.hotKey != nil {
+			l.hotKey.record(mk, time.Now())
+		}
+
+		iface, stale, isMiss, err := l.claim(ctx, mk)
+		switch {
+		case err != nil:
+			results[mk] = result{l.def, err}
+		case isMiss:
+			misses = append(misses, miss{mk, iface.(*cacheItem[Value]), stale})
+		default:
+			if item, ok := iface.(*cacheItem[Value]); ok && item.payload.Load() == nil {
+				// the key is fetched by other Load, wait for it after every key is claimed
+				wg.Add(1)
+				go func(key Key) {
+					defer wg.Done()
+					value, _, err := l.hit(ctx, key, item)
+					mutex.Lock()
+					results[key] = result{value, err}
+					mutex.Unlock()
+				}(mk)
+				continue
+			}
+			value, _, err := l.hit(ctx, mk, iface)
+			mutex.Lock()
+			results[mk] = result{value, err}
+			mutex.Unlock()
+		}
+	}
+
+	if l.batch != nil && len(misses) > 0 {
+		planned := make([]Key, len(misses))
+		for i, m := range misses {
+			planned[i] = m.key
+		}
+		l.batch.plan(planned)
+	}
+	for _, m := range misses {
+		wg.Add(1)
+		go func(m miss) {
+			defer wg.Done()
+			value, _, err := l.fetchForeground(ctx, m.key, m.item, m.stale)
+			if l.batch != nil {
+				l.batch.finished(m.key)
+			}
+			mutex.Lock()
+			results[m.key] = result{value, err}
+			mutex.Unlock()
+		}(m)
+	}
+	wg.Wait()
+
+	values := make(map[Key]Value, len(keys))
+	var errs map[Key]error
+	for _, key := range keys {
+		r := results[mapped[key]]
+		if r.err != nil {
+			if errs == nil {
+				errs = map[Key]error{}
+			}
+			errs[key] = r.err
+			continue
+		}
+		values[key] = l.cloneValue(r.value)
+	}
+	return values, errs
+}
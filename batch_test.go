@@ -0,0 +1,209 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchWindow(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]int
+	fetch := func(ctx context.Context, keys []int) (map[int]string, error) {
+		mutex.Lock()
+		sorted := append([]int(nil), keys...)
+		sort.Ints(sorted)
+		batches = append(batches, sorted)
+		mutex.Unlock()
+
+		values := map[int]string{}
+		for _, key := range keys {
+			if key != 404 {
+				values[key] = fmt.Sprint("value ", key)
+			}
+		}
+		return values, nil
+	}
+	l := NewBatch(fetch, time.Minute, WithBatchWindow(20*time.Millisecond, 3))
+
+	var wg sync.WaitGroup
+	for _, key := range []int{1, 2, 3, 4, 404} {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			val, err := l.Load(key)
+			if key == 404 {
+				assert.ErrorIs(t, err, ErrNotInBatch)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprint("value ", key), val)
+		}(key)
+	}
+	wg.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Len(t, batches, 2, "5 concurrent loads must be coalesced into batches of up to 3 keys")
+	assert.Equal(t, 5, len(batches[0])+len(batches[1]))
+
+	val, _ := l.Load(1)
+	assert.Equal(t, "value 1", val)
+	assert.Len(t, batches, 2, "cached keys must not be fetched")
+}
+
+func TestLoadManyPartialFailure(t *testing.T) {
+	errFailed := errors.New("failed")
+	var mutex sync.Mutex
+	var fetched [][]int
+	fetch := func(ctx context.Context, keys []int) (map[int]string, error) {
+		mutex.Lock()
+		fetched = append(fetched, append([]int(nil), keys...))
+		first := len(fetched) == 1
+		mutex.Unlock()
+		values := map[int]string{}
+		errs := BatchErrors[int]{}
+		for _, key := range keys {
+			switch key {
+			case 2:
+				if first {
+					errs[key] = errFailed
+					continue
+				}
+			case 404:
+				continue
+			}
+			values[key] = fmt.Sprint("value ", key)
+		}
+		return values, errs
+	}
+	l := NewBatch(fetch, time.Minute, WithErrorTTL(50*time.Millisecond))
+
+	values, errs := l.LoadMany(context.Background(), []int{1, 2, 3, 404, 1})
+	assert.Equal(t, map[int]string{1: "value 1", 3: "value 3"}, values)
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[2], errFailed)
+	assert.ErrorIs(t, errs[404], ErrNotInBatch)
+	mutex.Lock()
+	assert.Equal(t, [][]int{{1, 2, 3, 404}}, fetched, "misses must be fetched with one call")
+	mutex.Unlock()
+
+	val, err := l.Load(2)
+	assert.ErrorIs(t, err, errFailed, "failed key must be cached with the error ttl")
+	assert.Equal(t, "", val)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		val, err := l.Load(2)
+		return err == nil && val == "value 2"
+	}, time.Second, 5*time.Millisecond, "failed key must be refreshed after the error ttl")
+	values, errs = l.LoadMany(context.Background(), []int{1, 2, 3})
+	assert.Nil(t, errs)
+	assert.Equal(t, map[int]string{1: "value 1", 2: "value 2", 3: "value 3"}, values)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, [][]int{{1, 2, 3, 404}, {2}}, fetched, "only the failed key must be fetched again")
+}
+
+func TestLoadManyWithoutBatch(t *testing.T) {
+	l := New(func(ctx context.Context, key int) (string, error) {
+		if key < 0 {
+			return "", errors.New("negative")
+		}
+		return fmt.Sprint("value ", key), nil
+	}, time.Minute)
+
+	values, errs := l.LoadMany(context.Background(), []int{1, -1, 2})
+	assert.Equal(t, map[int]string{1: "value 1", 2: "value 2"}, values)
+	assert.Len(t, errs, 1)
+	assert.Error(t, errs[-1])
+}
+
+func TestLoadAll(t *testing.T) {
+	var running, peak int32
+	release := make(chan struct{})
+	l := New(func(ctx context.Context, key int) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		if key%3 == 0 {
+			return "", fmt.Errorf("key %d failed", key)
+		}
+		return fmt.Sprint(key), nil
+	}, time.Minute)
+
+	keys := []int{1, 2, 3, 4, 5, 6, 7}
+	var values []string
+	var errs []error
+	done := make(chan struct{})
+	go func() {
+		values, errs = l.LoadAll(context.Background(), keys, 2)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&running) == 2 }, time.Second, time.Millisecond)
+	close(release)
+	<-done
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(2))
+	assert.Equal(t, []string{"1", "2", "", "4", "5", "", "7"}, values)
+	if assert.Len(t, errs, len(keys)) {
+		for i, key := range keys {
+			if key%3 == 0 {
+				assert.EqualError(t, errs[i], fmt.Sprintf("key %d failed", key))
+			} else {
+				assert.NoError(t, errs[i])
+			}
+		}
+	}
+
+	values, errs = l.LoadAll(context.Background(), []int{1, 2}, 0)
+	assert.Equal(t, []string{"1", "2"}, values)
+	assert.Nil(t, errs)
+}
+
+func TestLoadManyMiddleware(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]int
+	var seen []int
+	l := NewBatch(func(ctx context.Context, keys []int) (map[int]int, error) {
+		mutex.Lock()
+		batches = append(batches, keys)
+		mutex.Unlock()
+		values := map[int]int{}
+		for _, k := range keys {
+			values[k] = k * 10
+		}
+		return values, nil
+	}, time.Minute, WithMiddleware(func(next Fetcher[int, int]) Fetcher[int, int] {
+		return func(ctx context.Context, key int) (int, error) {
+			mutex.Lock()
+			seen = append(seen, key)
+			mutex.Unlock()
+			if key == 0 {
+				// short-circuits without calling the BatchFetcher
+				return -1, nil
+			}
+			return next(ctx, key)
+		}
+	}))
+	defer l.Close()
+
+	values, errs := l.LoadMany(context.Background(), []int{0, 1, 2, 3})
+	assert.Empty(t, errs)
+	assert.Equal(t, map[int]int{0: -1, 1: 10, 2: 20, 3: 30}, values)
+	assert.ElementsMatch(t, []int{0, 1, 2, 3}, seen, "batched misses must go through the middleware")
+	assert.Equal(t, [][]int{{1, 2, 3}}, batches)
+}
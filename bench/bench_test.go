@@ -0,0 +1,86 @@
+package bench
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+func fetch(ctx context.Context, key int) (string, error) {
+	return strconv.Itoa(key), nil
+}
+
+// keys precomputes random keys in [0, cardinality), so rand doesn't dominate the benchmark
+func keys(n, cardinality int) []int {
+	r := rand.New(rand.NewSource(1))
+	keys := make([]int, n)
+	for i := range keys {
+		keys[i] = r.Intn(cardinality)
+	}
+	return keys
+}
+
+func run(b *testing.B, l *loader.Loader[int, string], keys []int) {
+	var counter uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddUint64(&counter, 1)
+			l.Load(keys[i%uint64(len(keys))])
+		}
+	})
+}
+
+func BenchmarkHitHeavy(b *testing.B) {
+	l := loader.New(fetch, time.Hour)
+	keys := keys(1<<16, 1000)
+	for _, key := range keys {
+		l.Load(key)
+	}
+	run(b, l, keys)
+}
+
+func BenchmarkMissHeavy(b *testing.B) {
+	l := loader.NewLRU(fetch, time.Hour, 1000)
+	run(b, l, keys(1<<16, 1<<24))
+}
+
+func BenchmarkExpiringHeavy(b *testing.B) {
+	l := loader.New(fetch, time.Nanosecond)
+	keys := keys(1<<16, 1000)
+	for _, key := range keys {
+		l.Load(key)
+	}
+	run(b, l, keys)
+}
+
+func BenchmarkContention(b *testing.B) {
+	l := loader.New(fetch, time.Hour)
+	keys := keys(1<<16, 4)
+	for _, key := range keys {
+		l.Load(key)
+	}
+	b.SetParallelism(64)
+	run(b, l, keys)
+}
+
+func BenchmarkLargeCardinality(b *testing.B) {
+	for _, driver := range []struct {
+		name   string
+		driver func() loader.CacheDriver
+	}{
+		{"sync.Map", loader.InMemoryCache},
+		{"sharded", func() loader.CacheDriver { return loader.ShardedInMemoryCache(64) }},
+	} {
+		b.Run(driver.name, func(b *testing.B) {
+			l := loader.New(fetch, time.Hour, loader.WithDriver(driver.driver()))
+			run(b, l, keys(1<<20, 1<<20))
+		})
+	}
+}
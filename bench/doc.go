@@ -0,0 +1,6 @@
+// Package bench contains realistic benchmarks for cache-loader.
+//
+// Run them with:
+//
+//	go test -run xxx -bench . -benchmem ./bench
+package bench
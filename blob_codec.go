@@ -0,0 +1,65 @@
+package loader
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// errBlobValue is returned by BlobCodec for values that aren't []byte
+var errBlobValue = errors.New("loader: BlobCodec only encodes []byte values")
+
+type blobCodec struct{}
+
+// BlobCodec is Codec of RemoteCache[[]byte] that writes the value as raw bytes after the metadata of the record,
+// and decodes it without copying: the cached value aliases the data returned by the RemoteStore,
+// like the mapping of mmapstore, so large blobs don't take heap. It can't be combined with WithEncryption or CompressCodec
+// without losing that, since they decode to new buffers.
+var BlobCodec Codec = blobCodec{}
+
+// aliases implements aliasingCodec
+func (blobCodec) aliases() {}
+
+func (blobCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case *remoteRecord[[]byte]:
+		meta := *v
+		meta.Value = nil
+		header, err := json.Marshal(&meta)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, 4, 4+len(header)+len(v.Value))
+		binary.BigEndian.PutUint32(data, uint32(len(header)))
+		data = append(data, header...)
+		return append(data, v.Value...), nil
+	}
+	return nil, errBlobValue
+}
+
+func (blobCodec) Unmarshal(data []byte, v interface{}) error {
+	r, ok := v.(*remoteRecord[[]byte])
+	if !ok {
+		return errBlobValue
+	}
+	if len(data) < 4 {
+		return ErrCorruptValue
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if len(data)-4 < size {
+		return ErrCorruptValue
+	}
+	if err := json.Unmarshal(data[4:4+size], r); err != nil {
+		return err
+	}
+	r.Value = data[4+size : len(data) : len(data)]
+	return nil
+}
+
+// aliasingCodec is implemented by codecs whose decoded values keep referencing the data,
+// so RemoteCache doesn't copy the data it keeps to compare with the next Get
+type aliasingCodec interface {
+	aliases()
+}
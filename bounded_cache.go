@@ -0,0 +1,187 @@
+package loader
+
+import "sync"
+
+// CostReporter is implemented by cache drivers that track the total cost of their items.
+// It is used by Loader.Stats.
+type CostReporter interface {
+	Cost() int64
+}
+
+// peeker is implemented by the drivers that track the accesses of their keys, so the loader can look a key up again
+// without counting it twice, e.g. when it checks the key under the key lock after a miss
+type peeker interface {
+	peek(key interface{}) (interface{}, bool)
+}
+
+// costly is implemented by cacheItem, it reports the cost computed by WithCost
+type costly interface {
+	cost() int64
+}
+
+type boundedCache struct {
+	mutex   sync.Mutex
+	maxCost int64
+	total   int64
+	items   map[interface{}]*boundedEntry
+	policy  EvictionPolicy
+
+	admission *tinyLFU
+	listener  EvictionListener
+}
+
+// BoundedCacheOption configures BoundedInMemoryCache
+type BoundedCacheOption func(c *boundedCache)
+
+type boundedEntry struct {
+	value interface{}
+	cost  int64
+}
+
+// BoundedInMemoryCache creates in-memory cache driver that holds items up to maxCost.
+// Item cost is computed by WithCost option, or 1 if it's not set.
+// When the total cost is exceeded, items are evicted by the EvictionPolicy, see WithEvictionPolicy.
+// The default LRUEviction evicts the costliest of the least recently used items first.
+func BoundedInMemoryCache(maxCost int64, options ...BoundedCacheOption) CacheDriver {
+	c := &boundedCache{
+		maxCost: maxCost,
+		items:   map[interface{}]*boundedEntry{},
+	}
+	for _, o := range options {
+		o(c)
+	}
+	if c.policy == nil {
+		c.policy = LRUEviction()
+	}
+	return c
+}
+
+func itemCost(value interface{}) int64 {
+	if c, ok := value.(costly); ok {
+		return c.cost()
+	}
+	return 1
+}
+
+// Add implements CacheDriver
+func (c *boundedCache) Add(key interface{}, value interface{}) {
+	cost := itemCost(value)
+
+	c.mutex.Lock()
+	evicted := c.add(key, value, cost)
+	c.mutex.Unlock()
+	for _, e := range evicted {
+		c.listener.Evicted(e.key, e.value)
+	}
+}
+
+// add stores the item and returns the evicted ones. The caller must hold the mutex.
+func (c *boundedCache) add(key interface{}, value interface{}, cost int64) []evictedItem {
+	if entry, ok := c.items[key]; ok {
+		c.total += cost - entry.cost
+		entry.value, entry.cost = value, cost
+	} else {
+		// reject the new item if it's less popular than the one it would evict
+		if c.admission != nil && c.total+cost > c.maxCost {
+			if victim, ok := c.policy.Victim(key); ok && !c.admission.admit(key, victim) {
+				return nil
+			}
+		}
+		c.items[key] = &boundedEntry{value: value, cost: cost}
+		c.total += cost
+	}
+	c.policy.Add(key, cost)
+	return c.evict(key)
+}
+
+// evict removes items until the total cost fits, but never the item of keep. The caller must hold the mutex.
+func (c *boundedCache) evict(keep interface{}) (evicted []evictedItem) {
+	for c.total > c.maxCost {
+		victim, ok := c.policy.Victim(keep)
+		if !ok {
+			return evicted
+		}
+		if entry, ok := c.items[victim]; ok {
+			evicted = append(evicted, evictedItem{victim, entry.value})
+		}
+		c.remove(victim)
+	}
+	return evicted
+}
+
+func (c *boundedCache) remove(key interface{}) {
+	if entry, ok := c.items[key]; ok {
+		delete(c.items, key)
+		c.total -= entry.cost
+	}
+	c.policy.Remove(key)
+}
+
+// Get implements CacheDriver
+func (c *boundedCache) Get(key interface{}) (interface{}, bool) {
+	if c.admission != nil {
+		c.admission.increment(key)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.policy.Access(key)
+	return entry.value, true
+}
+
+// peek implements peeker, it doesn't count as an access for the admission and eviction policies
+func (c *boundedCache) peek(key interface{}) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Remove implements Remover
+func (c *boundedCache) Remove(key interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		c.remove(key)
+	}
+}
+
+// Range implements Ranger
+func (c *boundedCache) Range(fn func(key, value interface{}) bool) {
+	c.mutex.Lock()
+	keys := make([]interface{}, 0, len(c.items))
+	values := make([]interface{}, 0, len(c.items))
+	for key, entry := range c.items {
+		keys = append(keys, key)
+		values = append(values, entry.value)
+	}
+	c.mutex.Unlock()
+
+	for i, key := range keys {
+		if !fn(key, values[i]) {
+			return
+		}
+	}
+}
+
+// Cost implements CostReporter
+func (c *boundedCache) Cost() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.total
+}
+
+// OnEvict implements EvictionNotifier
+func (c *boundedCache) OnEvict(fn func(key, value interface{})) (cancel func()) {
+	return c.listener.OnEvict(fn)
+}
@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// AsFetcher uses l as the Fetcher of other Loader, to chain caches like process cache → Redis → origin.
+// The fetch context of the outer loader is passed to l, and the outer value expires no later than the value of l.
+func AsFetcher[Key comparable, Value any](l *Loader[Key, Value]) Fetcher[Key, Value] {
+	return func(ctx context.Context, key Key) (Value, error) {
+		value, info, err := l.LoadWithInfoCtx(ctx, key)
+		if err == nil && !info.Expire.IsZero() {
+			ExpireAt(ctx, info.Expire)
+		}
+		return value, err
+	}
+}
+
+// WriteBack is the Fetcher of a local loader in front of the shared remote loader, like AsFetcher, except for refreshes.
+// Misses load from remote, so the value fetched by any instance is reused. A refresh reuses the remote value
+// if another instance stored it after the local one, otherwise it fetches from origin and primes remote in background,
+// so the other instances get the refreshed value without their own origin fetch.
+//
+//	shared := loader.New(fetchUser, time.Hour, loader.WithDriver(loader.RemoteCache[User](redis)))
+//	local := loader.New(loader.WriteBack(shared, fetchUser), time.Minute, loader.WithRefreshAhead(10*time.Second))
+func WriteBack[Key comparable, Value any](remote *Loader[Key, Value], origin Fetcher[Key, Value]) Fetcher[Key, Value] {
+	load := AsFetcher(remote)
+	return func(ctx context.Context, key Key) (Value, error) {
+		switch FetchReasonFromContext(ctx) {
+		case FetchStaleRefresh, FetchForceRefresh:
+		default:
+			return load(ctx, key)
+		}
+
+		var localFetchedAt time.Time
+		if item, ok := ctx.Value(expiryHintKey{}).(*cacheItem[Value]); ok {
+			if p := item.payload.Load(); p != nil {
+				localFetchedAt = p.fetchedAt
+			}
+		}
+		if p := remote.peek(key); p != nil && p.fetchedAt.After(localFetchedAt) && (p.expire.IsZero() || time.Now().Before(p.expire)) {
+			if !p.expire.IsZero() {
+				ExpireAt(ctx, p.expire)
+			}
+			return p.value, nil
+		}
+
+		value, err := origin(ctx, key)
+		if err == nil && remote.startBackground() {
+			go func() {
+				defer remote.background.Done()
+				remote.Prime(key, value)
+			}()
+		}
+		return value, err
+	}
+}
+
+// peek returns the cached value of key without fetching it, or nil if it's missing or an error
+func (l *Loader[Key, Value]) peek(key Key) *payload[Value] {
+	iface, ok := l.driver.Get(l.driverKey(l.mapKey(key)))
+	if !ok {
+		return nil
+	}
+	item, ok := iface.(*cacheItem[Value])
+	if !ok {
+		return nil
+	}
+	if p := item.payload.Load(); p != nil && p.err == nil {
+		return p
+	}
+	return nil
+}
+
+// ExpireAt makes the value being fetched with ctx expire no later than at, even if the ttl of the loader is longer.
+// Fetchers call it when they know the value becomes stale sooner, like from Cache-Control of an HTTP response.
+// It's a no-op if ctx isn't a fetch context of Loader.
+func ExpireAt(ctx context.Context, at time.Time) {
+	if hint, ok := ctx.Value(expiryHintKey{}).(expiryHinter); ok {
+		hint.hintExpiry(at)
+	}
+}
+
+type expiryHintKey struct{}
+
+// expiryHinter is implemented by cacheItem, it receives ExpireAt of the fetch of the item
+type expiryHinter interface {
+	hintExpiry(at time.Time)
+}
+
+// expiryHintContext lets the fetcher of item call ExpireAt, clearing the hint of the previous fetch
+func expiryHintContext[Value any](ctx context.Context, item *cacheItem[Value]) context.Context {
+	item.expiryHint.Store(0)
+	return context.WithValue(ctx, expiryHintKey{}, item)
+}
+
+// hintExpiry implements expiryHinter, an item is fetched by one goroutine at a time
+func (i *cacheItem[Value]) hintExpiry(at time.Time) {
+	if hint := i.expiryHint.Load(); hint == 0 || at.UnixNano() < hint {
+		i.expiryHint.Store(at.UnixNano())
+	}
+}
+
+// capExpiry applies and resets the ExpireAt of the fetch that produced p
+func (i *cacheItem[Value]) capExpiry(p *payload[Value]) {
+	hint := i.expiryHint.Swap(0)
+	if hint == 0 {
+		return
+	}
+	at := time.Unix(0, hint)
+	if at.Before(p.fetchedAt) {
+		at = p.fetchedAt
+	}
+	if at.Before(p.expire) {
+		p.expire = at
+	}
+}
@@ -0,0 +1,143 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type requestIDKey struct{}
+
+func TestAsFetcher(t *testing.T) {
+	var requestIDs []interface{}
+	inner := New(func(ctx context.Context, key string) (string, error) {
+		requestIDs = append(requestIDs, ctx.Value(requestIDKey{}))
+		return "value " + key, nil
+	}, time.Minute, WithContextValues(requestIDKey{}))
+	outer := New(AsFetcher(inner), time.Hour, WithContextValues(requestIDKey{}))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+	val, info, err := outer.LoadWithInfoCtx(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", val)
+	assert.Equal(t, []interface{}{"req-1"}, requestIDs, "context values must be passed to the inner loader")
+
+	_, innerInfo, _ := inner.LoadWithInfo("a")
+	assert.True(t, innerInfo.Cached)
+	assert.WithinDuration(t, innerInfo.Expire, info.Expire, 0, "outer value must expire with the inner value")
+
+	outer.Load("a")
+	assert.Len(t, requestIDs, 1)
+}
+
+func TestExpireAt(t *testing.T) {
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if key == "short" {
+			ExpireAt(ctx, time.Now().Add(time.Second))
+		}
+		return key, nil
+	}, time.Hour)
+
+	_, info, _ := l.LoadWithInfo("short")
+	assert.WithinDuration(t, time.Now().Add(time.Second), info.Expire, 100*time.Millisecond)
+	_, info, _ = l.LoadWithInfo("long")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), info.Expire, 100*time.Millisecond)
+	ExpireAt(context.Background(), time.Now())
+}
+
+func TestWriteBack(t *testing.T) {
+	var fetches atomic.Int32
+	origin := func(ctx context.Context, key string) (string, error) {
+		return fmt.Sprintf("%s%d", key, fetches.Add(1)), nil
+	}
+	remote := New(origin, time.Hour)
+	defer remote.Close()
+	local1 := New(WriteBack(remote, origin), 20*time.Millisecond)
+	defer local1.Close()
+	local2 := New(WriteBack(remote, origin), 20*time.Millisecond)
+	defer local2.Close()
+
+	v, _ := local1.Load("a")
+	assert.Equal(t, "a1", v)
+	v, _ = local2.Load("a")
+	assert.Equal(t, "a1", v, "miss must load from remote")
+
+	time.Sleep(30 * time.Millisecond)
+	v, _ = local1.Load("a")
+	assert.Equal(t, "a1", v)
+	assert.Eventually(t, func() bool {
+		v, _ := remote.Load("a")
+		return v == "a2"
+	}, time.Second, time.Millisecond, "refreshed value must be written back to remote")
+
+	v, _ = local2.Load("a")
+	assert.Equal(t, "a1", v)
+	assert.Eventually(t, func() bool {
+		v, _ := local2.Load("a")
+		return v == "a2"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), fetches.Load(), "refresh of other instance must reuse the written back value")
+}
+
+func TestReadRepair(t *testing.T) {
+	var fetches atomic.Int32
+	origin := func(ctx context.Context, key string) (string, error) {
+		return fmt.Sprintf("%s%d", key, fetches.Add(1)), nil
+	}
+	remote := New(origin, time.Hour)
+	defer remote.Close()
+	local := New(WriteBack(remote, origin), time.Hour, WithReadRepair(remote, 1))
+	defer local.Close()
+
+	v, _ := local.Load("a")
+	assert.Equal(t, "a1", v)
+
+	remote.Prime("a", "remote")
+	v, _ = local.Load("a")
+	assert.Equal(t, "a1", v)
+	assert.Eventually(t, func() bool {
+		v, _ := local.Load("a")
+		return v == "remote"
+	}, time.Second, time.Millisecond, "newer remote entry must be copied to local")
+	_, info, _ := local.LoadWithInfo("a")
+	_, remoteInfo, _ := remote.LoadWithInfo("a")
+	assert.Equal(t, remoteInfo.FetchedAt, info.FetchedAt, "the copy must keep its fetch time")
+
+	local.Prime("a", "local")
+	assert.Eventually(t, func() bool {
+		local.Load("a")
+		v, _ := remote.Load("a")
+		return v == "local"
+	}, time.Second, time.Millisecond, "newer local entry must be copied to remote")
+	assert.Equal(t, uint64(2), local.Stats().ReadRepairs)
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestReadRepairDoesNotUndoChanges(t *testing.T) {
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "fetched", nil
+	}, time.Hour)
+	defer l.Close()
+	other := &payload[string]{value: "other", fetchedAt: time.Now().Add(time.Minute)}
+
+	l.Load("a")
+	seen := l.peek("a")
+	l.Invalidate("a")
+	assert.False(t, l.replacePayload("a", seen, other))
+	assert.Nil(t, l.peek("a"), "invalidated key must not come back")
+
+	l.Load("a")
+	seen = l.peek("a")
+	l.Prime("a", "primed")
+	assert.False(t, l.replacePayload("a", seen, other))
+	v, _ := l.Load("a")
+	assert.Equal(t, "primed", v, "entry changed since the check must not be replaced")
+
+	assert.True(t, l.replacePayload("a", l.peek("a"), other))
+	v, _ = l.Load("a")
+	assert.Equal(t, "other", v)
+}
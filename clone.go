@@ -0,0 +1,34 @@
+package loader
+
+// Cloner is implemented by values that can make an isolated copy of themselves
+type Cloner[Value any] interface {
+	Clone() Value
+}
+
+// WithCloner makes every Load return clone(value) instead of the cached value,
+// so callers can't mutate the value shared by other callers
+func WithCloner[Value any](clone func(value Value) Value) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.cloner = clone
+	})
+}
+
+// WithCloneMethod makes every Load return value.Clone() for values implementing Cloner
+func WithCloneMethod() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.cloneMethod = true
+	})
+}
+
+// cloneValue copies the value returned to the caller, according to WithCloner or WithCloneMethod
+func (l *Loader[Key, Value]) cloneValue(value Value) Value {
+	if l.cloner != nil {
+		return l.cloner(value)
+	}
+	if l.cloneMethod {
+		if c, ok := any(value).(Cloner[Value]); ok {
+			return c.Clone()
+		}
+	}
+	return value
+}
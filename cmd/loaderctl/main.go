@@ -0,0 +1,204 @@
+// Command loaderctl inspects remote caches written by loader.RemoteCache on Redis.
+//
+//	loaderctl -redis localhost:6379 list 'users:*'
+//	loaderctl show users:42
+//	loaderctl dump users:42
+//	loaderctl delete users:42 users:43
+//
+// Pass the same -aes-key and -gzip as the RemoteCache options, so the entries can be decoded.
+package main
+
+import (
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/abihf/cache-loader/redisstore"
+)
+
+const usage = `usage: loaderctl [flags] command [args]
+
+commands:
+  list [pattern]   list keys matching the glob pattern with their metadata
+  show key         show the metadata of an entry
+  dump key         print the value of an entry as JSON
+  delete key...    delete entries
+
+flags:
+`
+
+func main() {
+	addr := flag.String("redis", "localhost:6379", "redis address")
+	password := flag.String("password", "", "redis password")
+	db := flag.Int("db", 0, "redis database")
+	aesKey := flag.String("aes-key", "", "hex encoded AES key used with WithEncryption")
+	compressed := flag.Bool("gzip", false, "values are written with CompressCodec and GzipCompressor")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options, err := remoteOptions(*aesKey, *compressed)
+	if err != nil {
+		fail(err)
+	}
+	store := redisstore.New(*addr, redisstore.WithPassword(*password), redisstore.WithDB(*db))
+	defer store.Close()
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch {
+	case cmd == "list" && len(args) <= 1:
+		pattern := "*"
+		if len(args) == 1 {
+			pattern = args[0]
+		}
+		err = list(store, pattern, options)
+	case cmd == "show" && len(args) == 1:
+		err = show(store, args[0], options)
+	case cmd == "dump" && len(args) == 1:
+		err = dump(store, args[0], options)
+	case cmd == "delete" && len(args) > 0:
+		for _, key := range args {
+			if err = store.Delete(key); err != nil {
+				break
+			}
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func remoteOptions(aesKey string, compressed bool) ([]loader.RemoteCacheOption, error) {
+	var options []loader.RemoteCacheOption
+	if aesKey != "" {
+		key, err := hex.DecodeString(aesKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -aes-key: %w", err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, loader.WithEncryption(aead))
+	}
+	if compressed {
+		options = append(options, loader.WithCodec(loader.CompressCodec(loader.JSONCodec, loader.GzipCompressor(gzip.DefaultCompression), 0)))
+	}
+	return options, nil
+}
+
+var errNotFound = errors.New("key not found")
+
+func entry(store *redisstore.Store, key string, options []loader.RemoteCacheOption) (*loader.RemoteEntry[json.RawMessage], int, error) {
+	data, err := store.Get(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if data == nil {
+		return nil, 0, errNotFound
+	}
+	e, err := loader.DecodeRemoteEntry[json.RawMessage](key, data, options...)
+	return e, len(data), err
+}
+
+func list(store *redisstore.Store, pattern string, options []loader.RemoteCacheOption) error {
+	keys, err := store.Keys(pattern)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSIZE\tFETCHED\tEXPIRE\tSTATE")
+	now := time.Now()
+	for _, key := range keys {
+		e, size, err := entry(store, key, options)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%v\n", key, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", key, size, ago(now, e.FetchedAt), ago(now, e.Expire), state(now, e))
+	}
+	return w.Flush()
+}
+
+func show(store *redisstore.Store, key string, options []loader.RemoteCacheOption) error {
+	e, size, err := entry(store, key, options)
+	if err != nil {
+		return err
+	}
+	ttl, err := store.TTL(key)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "key:\t%s\n", key)
+	fmt.Fprintf(w, "state:\t%s\n", state(now, e))
+	fmt.Fprintf(w, "size:\t%d bytes\n", size)
+	fmt.Fprintf(w, "fetched at:\t%s (%s)\n", e.FetchedAt.Format(time.RFC3339), ago(now, e.FetchedAt))
+	fmt.Fprintf(w, "expire:\t%s (%s)\n", e.Expire.Format(time.RFC3339), ago(now, e.Expire))
+	if !e.HardExpire.IsZero() {
+		fmt.Fprintf(w, "hard expire:\t%s (%s)\n", e.HardExpire.Format(time.RFC3339), ago(now, e.HardExpire))
+	}
+	if ttl > 0 {
+		fmt.Fprintf(w, "redis ttl:\t%s\n", ttl)
+	}
+	return w.Flush()
+}
+
+func dump(store *redisstore.Store, key string, options []loader.RemoteCacheOption) error {
+	e, _, err := entry(store, key, options)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(e.Value))
+	return nil
+}
+
+func state(now time.Time, e *loader.RemoteEntry[json.RawMessage]) string {
+	switch {
+	case !e.HardExpire.IsZero() && now.After(e.HardExpire):
+		return "expired"
+	case now.After(e.Expire):
+		return "stale"
+	default:
+		return "fresh"
+	}
+}
+
+func ago(now, t time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "loaderctl:", err)
+	os.Exit(1)
+}
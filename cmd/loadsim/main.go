@@ -0,0 +1,112 @@
+// Command loadsim drives a loader with simulated traffic and prints its hit rate and origin QPS,
+// so TTL and refresh-ahead settings can be tuned before production.
+//
+//	go run ./cmd/loadsim -keys 10000 -dist zipf -ttl 5s -refresh-ahead 1s -latency 50ms
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+func main() {
+	keys := flag.Int("keys", 10000, "number of distinct keys")
+	dist := flag.String("dist", "zipf", "key distribution: uniform or zipf")
+	skew := flag.Float64("skew", 1.1, "zipf skew, must be > 1")
+	ttl := flag.Duration("ttl", 5*time.Second, "cache TTL")
+	refreshAhead := flag.Duration("refresh-ahead", 0, "refresh items this long before they expire")
+	latency := flag.Duration("latency", 50*time.Millisecond, "fetch latency of the origin")
+	jitter := flag.Duration("jitter", 10*time.Millisecond, "random extra fetch latency")
+	workers := flag.Int("workers", 64, "number of concurrent callers")
+	duration := flag.Duration("duration", 20*time.Second, "how long the simulation runs")
+	interval := flag.Duration("interval", time.Second, "how often stats are printed")
+	flag.Parse()
+
+	if *dist != "uniform" && *dist != "zipf" {
+		fmt.Fprintf(os.Stderr, "unknown distribution %q\n", *dist)
+		os.Exit(2)
+	}
+
+	var fetches atomic.Uint64
+	fetch := func(ctx context.Context, key int) (int, error) {
+		fetches.Add(1)
+		d := *latency
+		if *jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(*jitter)))
+		}
+		time.Sleep(d)
+		return key, nil
+	}
+	var options []loader.Option
+	if *refreshAhead > 0 {
+		options = append(options, loader.WithRefreshAhead(*refreshAhead))
+	}
+	l := loader.New(fetch, *ttl, options...)
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	var loads atomic.Uint64
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			next := keyGenerator(rand.New(rand.NewSource(seed)), *dist, *skew, *keys)
+			for ctx.Err() == nil {
+				l.Load(next())
+				loads.Add(1)
+			}
+		}(int64(i))
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var last loader.Stats
+	var lastFetches uint64
+	fmt.Printf("%8s %10s %10s %8s %12s\n", "elapsed", "loads/s", "hits", "hit rate", "origin qps")
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			total := l.Stats()
+			elapsed := time.Since(start)
+			fmt.Printf("\ntotal: %d loads, %.2f%% hit rate, %.1f origin qps\n",
+				loads.Load(), hitRate(total.Hits, total.Misses), float64(fetches.Load())/elapsed.Seconds())
+			return
+		case <-ticker.C:
+			stats := l.Stats()
+			f := fetches.Load()
+			hits, misses := stats.Hits-last.Hits, stats.Misses-last.Misses
+			seconds := interval.Seconds()
+			fmt.Printf("%8s %10.0f %10d %7.2f%% %12.1f\n", time.Since(start).Round(time.Second),
+				float64(hits+misses)/seconds, hits, hitRate(hits, misses), float64(f-lastFetches)/seconds)
+			last, lastFetches = stats, f
+		}
+	}
+}
+
+func keyGenerator(r *rand.Rand, dist string, skew float64, keys int) func() int {
+	if dist == "zipf" {
+		zipf := rand.NewZipf(r, skew, 1, uint64(keys-1))
+		return func() int { return int(zipf.Uint64()) }
+	}
+	return func() int { return r.Intn(keys) }
+}
+
+func hitRate(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) * 100 / float64(hits+misses)
+}
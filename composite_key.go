@@ -0,0 +1,51 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key2 is a comparable key made of two parts, e.g. tenant ID and resource ID
+type Key2[A, B comparable] struct {
+	First  A
+	Second B
+}
+
+// Key3 is a comparable key made of three parts
+type Key3[A, B, C comparable] struct {
+	First  A
+	Second B
+	Third  C
+}
+
+// K2 creates Key2
+func K2[A, B comparable](a A, b B) Key2[A, B] {
+	return Key2[A, B]{First: a, Second: b}
+}
+
+// K3 creates Key3
+func K3[A, B, C comparable](a A, b B, c C) Key3[A, B, C] {
+	return Key3[A, B, C]{First: a, Second: b, Third: c}
+}
+
+func (k Key2[A, B]) String() string {
+	return fmt.Sprintf("%v:%v", k.First, k.Second)
+}
+
+func (k Key3[A, B, C]) String() string {
+	return fmt.Sprintf("%v:%v:%v", k.First, k.Second, k.Third)
+}
+
+// Fetcher2 adapts a function with two key parts into Fetcher of Key2
+func Fetcher2[A, B comparable, Value any](fn func(ctx context.Context, a A, b B) (Value, error)) Fetcher[Key2[A, B], Value] {
+	return func(ctx context.Context, key Key2[A, B]) (Value, error) {
+		return fn(ctx, key.First, key.Second)
+	}
+}
+
+// Fetcher3 adapts a function with three key parts into Fetcher of Key3
+func Fetcher3[A, B, C comparable, Value any](fn func(ctx context.Context, a A, b B, c C) (Value, error)) Fetcher[Key3[A, B, C], Value] {
+	return func(ctx context.Context, key Key3[A, B, C]) (Value, error) {
+		return fn(ctx, key.First, key.Second, key.Third)
+	}
+}
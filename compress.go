@@ -0,0 +1,101 @@
+package loader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// Compressor compresses serialized values, snappy or zstd encoders can be adapted to it
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+// GzipCompressor creates Compressor that uses compress/gzip with the level, such as gzip.BestSpeed
+func GzipCompressor(level int) Compressor {
+	return &gzipCompressor{level: level}
+}
+
+func (c *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ErrCorruptValue is returned when a compressed value can't be decoded
+var ErrCorruptValue = errors.New("loader: corrupt compressed value")
+
+// the first byte of data encoded by CompressCodec
+const (
+	rawValue        byte = 0
+	compressedValue byte = 1
+)
+
+type compressCodec struct {
+	codec      Codec
+	compressor Compressor
+	threshold  int
+}
+
+// CompressCodec wraps codec so serialized values larger than threshold bytes are compressed.
+// Smaller values are stored as is, since compressing them costs more than it saves.
+func CompressCodec(codec Codec, compressor Compressor, threshold int) Codec {
+	return &compressCodec{codec: codec, compressor: compressor, threshold: threshold}
+}
+
+func (c *compressCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= c.threshold {
+		return append([]byte{rawValue}, data...), nil
+	}
+	compressed, err := c.compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{compressedValue}, compressed...), nil
+}
+
+func (c *compressCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return ErrCorruptValue
+	}
+	switch data[0] {
+	case rawValue:
+		return c.codec.Unmarshal(data[1:], v)
+	case compressedValue:
+		data, err := c.compressor.Decompress(data[1:])
+		if err != nil {
+			return err
+		}
+		return c.codec.Unmarshal(data, v)
+	default:
+		return ErrCorruptValue
+	}
+}
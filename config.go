@@ -12,30 +12,272 @@ var defaultContextFactory ContextFactory = func() context.Context {
 	return context.Background()
 }
 
+// KeyContextFactory creates context to be used by LoadFunc for the fetch of key, see WithKeyContextFactory
+type KeyContextFactory[Key comparable] func(key Key) context.Context
+
+// forKey adapts ContextFactory to KeyContextFactory, ignoring the key
+func forKey[Key comparable](cf ContextFactory) KeyContextFactory[Key] {
+	return func(Key) context.Context {
+		return cf()
+	}
+}
+
+// Limiter throttles origin fetches.
+// *rate.Limiter from golang.org/x/time/rate satisfies this interface.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
 type config struct {
-	cf     ContextFactory
-	driver CacheDriver
+	cf      ContextFactory
+	keyCf   interface{}
+	driver  CacheDriver
+	limiter Limiter
+	// customDriver is set by WithDriver
+	customDriver bool
+
+	ttl     time.Duration
+	errTtl  time.Duration
+	hardTTL time.Duration
+	// noErrorCaching is set by WithNoErrorCaching
+	noErrorCaching bool
+	// startupSmear is the window of WithStartupSmear
+	startupSmear time.Duration
+
+	namespace     string
+	contextValues []interface{}
+
+	minRefreshInterval time.Duration
+	deadlineFallback   bool
+	refreshWorkers     int
+	refreshAhead       time.Duration
+	// inlineRefresh runs refreshes in Load instead of goroutines, see WithInlineRefresh
+	inlineRefresh bool
+	// invalidationSource and invalidationRetry are set by WithInvalidationSource
+	invalidationSource interface{}
+	invalidationRetry  time.Duration
+	// maxRefetchGoroutines caps the refetch goroutines, see WithMaxRefetchGoroutines
+	maxRefetchGoroutines int
+
+	invalidationDebounce time.Duration
+
+	// hotKey holds *hotKeyDetector[Key], it's resolved by New
+	hotKey interface{}
+	// cost holds func(Value) int64, it's resolved by New
+	cost interface{}
+	// keyMapper holds func(Key) Key, it's resolved by New
+	keyMapper interface{}
+	// coldStart holds *coldStart[Value], it's resolved by New
+	coldStart interface{}
+	// middlewares holds Middleware[Key, Value], they're resolved by New
+	middlewares []interface{}
+	// warmKeys holds func(context.Context) ([]Key, error), it's resolved by New
+	warmKeys interface{}
+	// cloner holds func(Value) Value, it's resolved by New
+	cloner interface{}
+	// cloneMethod makes Load use the Clone method of the value
+	cloneMethod bool
+	// mutationCheck verifies cached values are not mutated by callers
+	mutationCheck bool
+	onMutation    func(err error)
+	// notFoundTtl is the ttl of ErrNotFound, skipZeroValues doesn't cache zero values
+	notFoundTtl    time.Duration
+	skipZeroValues bool
+	// maxValueSize is the largest value that is cached
+	maxValueSize int64
+	// admission decides which fetched values are cached, nil caches all of them
+	admission AdmissionPolicy
+	// evictionListener reports the evictions of the driver, see WithEvictionListener
+	evictionListener *EvictionListener
+	// audit records the mutations of the loader, see WithAuditLog
+	audit AuditLog
+	// sink receives the events of the loader, see WithStatsSink
+	sink StatsSink
+	// maxRefreshErrorRate is the threshold of Healthy over the last refreshErrorWindow refreshes
+	maxRefreshErrorRate float64
+	refreshErrorWindow  int
+	// batchWindow and batchSize configure the batcher of NewBatch
+	batchWindow time.Duration
+	batchSize   int
+	// periodicRefresh is the interval of WithPeriodicRefresh
+	periodicRefresh     time.Duration
+	periodicConcurrency int
+	// driverTimeout limits how long Load waits for the driver Get
+	driverTimeout time.Duration
+	// name is set by WithName, register adds the loader to DefaultRegistry
+	name     string
+	register bool
+	// locker holds KeyLocker[Key], it's resolved by New
+	locker interface{}
+	// corruptRecovery is set by WithCorruptEntryRecovery
+	corruptRecovery bool
+	onCorruptEntry  func(err error)
+	// leaseStore and leaseTTL configure WithRefreshLease
+	leaseStore LeaseStore
+	leaseTTL   time.Duration
+	// lockDebug is the threshold of WithLockDebug
+	lockDebug   time.Duration
+	onLockError func(err error)
+	// tenantOf holds func(Key) string, it's resolved by New
+	tenantOf    interface{}
+	tenantLimit int64
+	// revalidating is set for loaders created by NewRevalidating, versioned for NewVersioned.
+	// They're set before newLoader, so the goroutines it starts see them.
+	revalidating bool
+	versioned    bool
+	// typed holds OptionT[Key, Value], they're applied by New
+	typed []interface{}
+}
 
-	ttl    time.Duration
-	errTtl time.Duration
+// Option configures Loader, it's accepted by New
+type Option interface {
+	apply(cfg *config)
 }
 
-type Option func(cfg *config)
+// optionFunc is Option that doesn't need Key or Value type
+type optionFunc func(cfg *config)
+
+func (o optionFunc) apply(cfg *config) {
+	o(cfg)
+}
 
 func WithDriver(driver CacheDriver) Option {
-	return func(cfg *config) {
+	return optionFunc(func(cfg *config) {
 		cfg.driver = driver
-	}
+		cfg.customDriver = true
+	})
 }
 
 func WithErrorTTL(ttl time.Duration) Option {
-	return func(cfg *config) {
+	return optionFunc(func(cfg *config) {
 		cfg.errTtl = ttl
-	}
+	})
 }
 
 func WithContextFactory(cf ContextFactory) Option {
-	return func(cfg *config) {
+	return optionFunc(func(cfg *config) {
 		cfg.cf = cf
-	}
+	})
+}
+
+// WithKeyContextFactory works like WithContextFactory, but cf receives the fetched key,
+// so the context can carry key-derived values such as the tenant or shard, or a deadline for the kind of key.
+// It takes precedence over WithContextFactory. Batch fetches use the context of their first key.
+func WithKeyContextFactory[Key comparable](cf func(key Key) context.Context) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.keyCf = KeyContextFactory[Key](cf)
+	})
+}
+
+// WithMinRefreshInterval prevents a key from being refetched more often than every d,
+// regardless of its ttl. Use it to protect rate-limited upstream APIs.
+func WithMinRefreshInterval(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.minRefreshInterval = d
+	})
+}
+
+// WithRateLimiter applies limiter to every origin fetch, both foreground and background.
+// If the limiter returns an error, the fetch fails with that error.
+func WithRateLimiter(limiter Limiter) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.limiter = limiter
+	})
+}
+
+// WithRefreshWorkers bounds the number of concurrent background refreshes to n.
+// When more items are expired than there are workers, the most accessed keys are refreshed first.
+func WithRefreshWorkers(n int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.refreshWorkers = n
+	})
+}
+
+// WithRefreshAhead refreshes items d before they expire, so hot keys are never served stale.
+// Only items that have been accessed since their last fetch are refreshed ahead.
+func WithRefreshAhead(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.refreshAhead = d
+	})
+}
+
+// WithCost computes the cost of each value, e.g. its estimated size in bytes.
+// The cost is used by BoundedInMemoryCache and reported in Stats.
+func WithCost[Value any](fn func(value Value) int64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.cost = fn
+	})
+}
+
+// WithNamespace isolates the keys of the loader within its cache driver,
+// so several loaders can share one driver without key collisions.
+// Loader.InvalidateAll only removes the items in its namespace.
+func WithNamespace(name string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.namespace = name
+	})
+}
+
+// WithKeyMapper normalizes every key before it's used for cache lookup, locking, and invalidation,
+// e.g. strings.ToLower. The fetcher receives the normalized key.
+func WithKeyMapper[Key comparable](mapper func(key Key) Key) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.keyMapper = mapper
+	})
+}
+
+// WithContextValues copies the values of keys from the context passed to LoadCtx into the fetcher context,
+// including background refreshes triggered by that load. Use it for request-scoped values like tenant or locale.
+func WithContextValues(keys ...interface{}) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.contextValues = append(cfg.contextValues, keys...)
+	})
+}
+
+// WithName names the loader and adds it to DefaultRegistry until it's closed,
+// so metrics, logs, and admin endpoints can tell the loaders apart.
+func WithName(name string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.name = name
+		cfg.register = true
+	})
+}
+
+// WithDeadlineFallback makes LoadCtx return the stale value (marked by Info.Fallback) instead of the context error,
+// when its context is done while waiting for the fetch that replaces a hard expired item.
+// Without it, LoadCtx still stops waiting and returns the context error. Either way the fetch keeps running in background and its result is cached.
+func WithDeadlineFallback() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.deadlineFallback = true
+	})
+}
+
+type coldStart[Value any] struct {
+	timeout     time.Duration
+	placeholder Value
+}
+
+// WithColdStartTimeout returns placeholder when the fetch of a missing item takes longer than d.
+// The fetch keeps running in background and its result is cached. The placeholder is never cached.
+func WithColdStartTimeout[Value any](d time.Duration, placeholder Value) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.coldStart = &coldStart[Value]{timeout: d, placeholder: placeholder}
+	})
+}
+
+// WithHardTTL sets the maximum age of a cached value. After it passes, the item is treated as a miss
+// and Load waits for a fresh value instead of serving the stale one. It's disabled by default.
+func WithHardTTL(ttl time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.hardTTL = ttl
+	})
+}
+
+// WithInvalidationDebounce delays the fetch of an invalidated key until it hasn't been invalidated for d.
+// Loads during the delay wait for that single fetch, so a burst of invalidations on a hot key
+// doesn't stampede the origin.
+func WithInvalidationDebounce(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.invalidationDebounce = d
+	})
 }
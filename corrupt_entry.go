@@ -0,0 +1,56 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrCorruptEntry is wrapped by the error of Load when the driver returns nil or a value that isn't an item of the loader,
+// like a key of a shared store written by another application
+var ErrCorruptEntry = errors.New("loader: corrupt cache entry")
+
+// WithCorruptEntryRecovery treats the corrupt entries returned by the driver as misses instead of failing every Load:
+// the entry is removed if the driver implements Remover, and the key is fetched again, overwriting it.
+// report receives the error wrapping ErrCorruptEntry, it can be nil.
+func WithCorruptEntryRecovery(report func(err error)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.corruptRecovery = true
+		cfg.onCorruptEntry = report
+	})
+}
+
+// corruptEntry returns the error of iface if it isn't an item of the loader
+func corruptEntry[Value any](iface interface{}) error {
+	if iface == nil {
+		return fmt.Errorf("%w: cache driver returns ok but the value is nil", ErrCorruptEntry)
+	}
+	if _, ok := iface.(*cacheItem[Value]); !ok {
+		return fmt.Errorf("%w: cache driver returns invalid value %v", ErrCorruptEntry, iface)
+	}
+	return nil
+}
+
+// dropCorrupt removes iface stored at dk and returns true if it's corrupt and WithCorruptEntryRecovery is used
+func (l *Loader[Key, Value]) dropCorrupt(dk interface{}, iface interface{}) bool {
+	if !l.corruptRecovery {
+		return false
+	}
+	err := corruptEntry[Value](iface)
+	if err == nil {
+		return false
+	}
+	if l.onCorruptEntry != nil {
+		l.onCorruptEntry(err)
+	}
+	if remover, ok := l.driver.(Remover); ok {
+		remover.Remove(dk)
+		if key, ok := l.loaderKey(dk); ok {
+			l.record(corruptEntryContext, AuditInvalidate, key, err)
+		}
+	}
+	return true
+}
+
+// corruptEntryContext is the context of the AuditRecords of dropped corrupt entries
+var corruptEntryContext = WithAuditReason(context.Background(), "corrupt entry")
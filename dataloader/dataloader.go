@@ -0,0 +1,95 @@
+// Package dataloader adapts loader to the dataloader interface of GraphQL servers:
+// within a request the keys are deduplicated and batched, and across requests they're cached by the Loader.
+//
+//	users := dataloader.New(loader.NewBatch(fetchUsers, time.Minute, loader.WithBatchWindow(time.Millisecond, 100)))
+//	// in the HTTP middleware
+//	r = r.WithContext(dataloader.WithRequest(r.Context()))
+//	// in the resolvers
+//	user, err := users.Load(ctx, id)()
+package dataloader
+
+import (
+	"context"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// Thunk waits for the result of Load
+type Thunk[Value any] func() (Value, error)
+
+// ThunkMany waits for the results of LoadMany, errs is nil if every key succeeds, otherwise it's aligned with keys
+type ThunkMany[Value any] func() (values []Value, errs []error)
+
+// Loader is the dataloader of one type
+type Loader[Key comparable, Value any] struct {
+	cache   *loader.Loader[Key, Value]
+	request *loader.RequestCache[Key, Value]
+}
+
+// New creates Loader backed by cache. Create cache with loader.NewBatch and loader.WithBatchWindow,
+// so the keys loaded concurrently by the resolvers are fetched in batches.
+func New[Key comparable, Value any](cache *loader.Loader[Key, Value]) *Loader[Key, Value] {
+	return &Loader[Key, Value]{cache: cache, request: loader.NewRequestCache(cache)}
+}
+
+// WithRequest returns ctx that deduplicates the keys of every Loader for one request
+func WithRequest(ctx context.Context) context.Context {
+	return loader.WithRequestScope(ctx)
+}
+
+// Load starts loading key and returns the Thunk of its result
+func (d *Loader[Key, Value]) Load(ctx context.Context, key Key) Thunk[Value] {
+	var value Value
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err = d.request.Load(ctx, key)
+	}()
+	return func() (Value, error) {
+		<-done
+		return value, err
+	}
+}
+
+// LoadMany starts loading keys and returns the ThunkMany of their results
+func (d *Loader[Key, Value]) LoadMany(ctx context.Context, keys []Key) ThunkMany[Value] {
+	thunks := make([]Thunk[Value], len(keys))
+	for i, key := range keys {
+		thunks[i] = d.Load(ctx, key)
+	}
+	return func() ([]Value, []error) {
+		values := make([]Value, len(keys))
+		var errs []error
+		for i, thunk := range thunks {
+			var err error
+			if values[i], err = thunk(); err != nil {
+				if errs == nil {
+					errs = make([]error, len(keys))
+				}
+				errs[i] = err
+			}
+		}
+		return values, errs
+	}
+}
+
+// Clear removes key from the cache and from the request of ctx, like after a mutation
+func (d *Loader[Key, Value]) Clear(ctx context.Context, key Key) *Loader[Key, Value] {
+	d.cache.Invalidate(key)
+	d.request.Clear(ctx, key)
+	return d
+}
+
+// ClearAll removes every key from the cache
+func (d *Loader[Key, Value]) ClearAll() *Loader[Key, Value] {
+	d.cache.InvalidateAll()
+	return d
+}
+
+// Prime adds value of key to the cache, replacing the value seen by the request of ctx
+func (d *Loader[Key, Value]) Prime(ctx context.Context, key Key, value Value) *Loader[Key, Value] {
+	d.cache.Prime(key, value)
+	d.request.Clear(ctx, key)
+	return d
+}
@@ -0,0 +1,55 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]int
+	cache := loader.NewBatch(func(ctx context.Context, keys []int) (map[int]string, error) {
+		mutex.Lock()
+		sorted := append([]int(nil), keys...)
+		sort.Ints(sorted)
+		batches = append(batches, sorted)
+		mutex.Unlock()
+		values := map[int]string{}
+		for _, key := range keys {
+			if key > 0 {
+				values[key] = fmt.Sprint("user ", key)
+			}
+		}
+		return values, nil
+	}, time.Minute, loader.WithBatchWindow(20*time.Millisecond, 100))
+	users := New(cache)
+
+	ctx := WithRequest(context.Background())
+	values, errs := users.LoadMany(ctx, []int{1, 2, 1, -1})()
+	assert.Equal(t, []string{"user 1", "user 2", "user 1", ""}, values)
+	assert.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[3], loader.ErrNotInBatch)
+	assert.Equal(t, [][]int{{-1, 1, 2}}, batches, "keys must be deduplicated and batched")
+
+	val, err := users.Load(WithRequest(context.Background()), 2)()
+	assert.NoError(t, err)
+	assert.Equal(t, "user 2", val)
+	assert.Len(t, batches, 1, "other request must be served from cache")
+
+	users.Prime(ctx, 2, "primed")
+	val, _ = users.Load(ctx, 2)()
+	assert.Equal(t, "primed", val)
+
+	users.Clear(ctx, 2)
+	val, _ = users.Load(ctx, 2)()
+	assert.Equal(t, "user 2", val)
+	assert.Len(t, batches, 2)
+}
@@ -0,0 +1,55 @@
+package loader
+
+import (
+	"sync"
+	"time"
+)
+
+// invalidationDebouncer delays the fetch of recently invalidated keys,
+// so a burst of invalidations is repopulated by exactly one fetch
+type invalidationDebouncer[Key comparable] struct {
+	delay time.Duration
+
+	mutex sync.Mutex
+	last  map[Key]time.Time
+}
+
+func newInvalidationDebouncer[Key comparable](delay time.Duration) *invalidationDebouncer[Key] {
+	return &invalidationDebouncer[Key]{delay: delay, last: map[Key]time.Time{}}
+}
+
+// invalidated records the invalidation of key
+func (d *invalidationDebouncer[Key]) invalidated(key Key) {
+	now := time.Now()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.last[key] = now
+	// keys that are invalidated but never loaded again must not leak
+	if len(d.last) > 1024 {
+		for k, t := range d.last {
+			if now.Sub(t) >= d.delay {
+				delete(d.last, k)
+			}
+		}
+	}
+}
+
+// wait blocks until key hasn't been invalidated for the debounce delay
+func (d *invalidationDebouncer[Key]) wait(key Key) {
+	for {
+		d.mutex.Lock()
+		last, ok := d.last[key]
+		remaining := d.delay - time.Since(last)
+		if ok && remaining <= 0 {
+			delete(d.last, key)
+		}
+		d.mutex.Unlock()
+
+		if !ok || remaining <= 0 {
+			return
+		}
+		time.Sleep(remaining)
+	}
+}
@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrDeltaUnavailable is returned by DeltaFetcher when the delta since the previous entry can't be computed,
+// e.g. the origin has compacted its change log, so the loader fetches the full value instead
+var ErrDeltaUnavailable = errors.New("loader: delta unavailable")
+
+// DeltaFetcher fetches the changes since prev, and the tag of the result to fetch the next delta from.
+// It can return ErrNotModified when nothing changed, or ErrDeltaUnavailable to fetch the full value.
+type DeltaFetcher[Key comparable, Value any, Delta any] func(ctx context.Context, key Key, prev *Entry[Value]) (delta Delta, tag string, err error)
+
+// NewDelta creates Loader whose first fetch of each key uses full, and refreshes use delta merged into the cached value.
+// merge must return a new value instead of modifying prev, since prev may be in use by other callers.
+// WithTransform is applied to the merged value too.
+func NewDelta[Key comparable, Value any, Delta any](full RevalidatingFetcher[Key, Value], delta DeltaFetcher[Key, Value, Delta], merge func(prev Value, delta Delta) Value, ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	return NewRevalidating(func(ctx context.Context, key Key, prev *Entry[Value]) (*Entry[Value], error) {
+		if prev == nil {
+			return full(ctx, key, nil)
+		}
+		d, tag, err := delta(ctx, key, prev)
+		if errors.Is(err, ErrDeltaUnavailable) {
+			return full(ctx, key, prev)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Entry[Value]{Value: merge(prev.Value, d), Tag: tag}, nil
+	}, ttl, options...)
+}
@@ -0,0 +1,81 @@
+package loader
+
+import "sync"
+
+// WithDependencies declares the keys each fetched value depends on, so invalidating one of them
+// also invalidates the value, and its dependents in turn. Dependencies are updated on every successful fetch,
+// and removed when the item is evicted, see EvictionNotifier.
+func WithDependencies[Key comparable, Value any](deps func(key Key, value Value) []Key) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.deps = &dependencyGraph[Key, Value]{
+			of:           deps,
+			dependents:   map[Key]map[Key]struct{}{},
+			dependencies: map[Key][]Key{},
+		}
+	}
+}
+
+// dependencyGraph maps each key to the keys that depend on it
+type dependencyGraph[Key comparable, Value any] struct {
+	of func(key Key, value Value) []Key
+
+	mutex        sync.Mutex
+	dependents   map[Key]map[Key]struct{}
+	dependencies map[Key][]Key
+}
+
+// set replaces the dependencies of key
+func (g *dependencyGraph[Key, Value]) set(key Key, value Value) {
+	deps := g.of(key, value)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.unlink(key)
+	for _, dep := range deps {
+		if g.dependents[dep] == nil {
+			g.dependents[dep] = map[Key]struct{}{}
+		}
+		g.dependents[dep][key] = struct{}{}
+	}
+	if len(deps) > 0 {
+		g.dependencies[key] = deps
+	}
+}
+
+// unlink removes the edges from key to its dependencies, the caller must hold the mutex
+func (g *dependencyGraph[Key, Value]) unlink(key Key) {
+	for _, dep := range g.dependencies[key] {
+		delete(g.dependents[dep], key)
+		if len(g.dependents[dep]) == 0 {
+			delete(g.dependents, dep)
+		}
+	}
+	delete(g.dependencies, key)
+}
+
+// evicted removes the edges from key to its dependencies when its item leaves the cache without Invalidate.
+// The edges to key are kept, so invalidating it still cascades to the dependents that are cached.
+func (g *dependencyGraph[Key, Value]) evicted(key Key) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.unlink(key)
+}
+
+// invalidated removes key from the graph and returns its dependents
+func (g *dependencyGraph[Key, Value]) invalidated(key Key) []Key {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.unlink(key)
+	dependents := make([]Key, 0, len(g.dependents[key]))
+	for dependent := range g.dependents[key] {
+		dependents = append(dependents, dependent)
+	}
+	return dependents
+}
+
+func (g *dependencyGraph[Key, Value]) clear() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.dependents = map[Key]map[Key]struct{}{}
+	g.dependencies = map[Key][]Key{}
+}
@@ -0,0 +1,141 @@
+// Package diskstore implements loader.RemoteStore with one file per key, for large values like build artifacts
+// or API payloads that don't fit in memory:
+//
+//	store, err := diskstore.New(filepath.Join(cacheDir, "payloads"))
+//	driver := loader.RemoteCache[Payload](store)
+//
+// File names are the SHA-256 of the keys, sharded into two levels of directories so no directory grows too large.
+// Files are written to a temporary file and renamed, so readers never see a partial value,
+// and the expiry is stored in the header of the file.
+package diskstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// headerSize is the size of the header of each file: the expiry in unix milliseconds, zero if it never expires
+const headerSize = 8
+
+// tempPrefix is the prefix of the files being written, they're skipped by Vacuum
+const tempPrefix = ".tmp-"
+
+// Store is loader.RemoteStore backed by a directory
+type Store struct {
+	dir string
+}
+
+// New creates Store in dir, creating it if it doesn't exist
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// path returns the file of key
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, name[:2], name[2:4], name)
+}
+
+// Get implements loader.RemoteStore, expired files are removed
+func (s *Store) Get(key string) ([]byte, error) {
+	path := s.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize {
+		// not written by Store
+		return nil, nil
+	}
+	if expired(data, time.Now()) {
+		os.Remove(path)
+		return nil, nil
+	}
+	return data[headerSize:], nil
+}
+
+// Set implements loader.RemoteStore
+func (s *Store) Set(key string, data []byte, ttl time.Duration) error {
+	path := s.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, tempPrefix)
+	if err != nil {
+		return err
+	}
+	var header [headerSize]byte
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(header[:], uint64(time.Now().Add(ttl).UnixMilli()))
+	}
+	_, err = f.Write(header[:])
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Delete implements loader.RemoteStore
+func (s *Store) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Vacuum removes the expired files, it returns how many are removed
+func (s *Store) Vacuum() (int, error) {
+	now := time.Now()
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		var header [headerSize]byte
+		_, err = io.ReadFull(f, header[:])
+		f.Close()
+		if err == nil && expired(header[:], now) && os.Remove(path) == nil {
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func expired(data []byte, now time.Time) bool {
+	expiresAt := int64(binary.BigEndian.Uint64(data[:headerSize]))
+	return expiresAt != 0 && expiresAt <= now.UnixMilli()
+}
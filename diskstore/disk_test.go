@@ -0,0 +1,80 @@
+package diskstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	s, err := New(filepath.Join(t.TempDir(), "cache"))
+	assert.NoError(t, err)
+
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("a", []byte("1"), 0))
+	assert.NoError(t, s.Set("a", []byte("2"), time.Hour))
+	assert.NoError(t, s.Set("empty", []byte{}, 0))
+	v, _ = s.Get("a")
+	assert.Equal(t, []byte("2"), v)
+	v, _ = s.Get("empty")
+	assert.NotNil(t, v, "empty value must not be a miss")
+
+	rel, _ := filepath.Rel(s.dir, s.path("a"))
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if assert.Len(t, parts, 3, "files must be sharded into two levels") {
+		assert.Equal(t, parts[2][:2], parts[0])
+		assert.Equal(t, parts[2][2:4], parts[1])
+	}
+
+	assert.NoError(t, s.Delete("a"))
+	assert.NoError(t, s.Delete("a"), "deleting a missing key must not fail")
+	v, _ = s.Get("a")
+	assert.Nil(t, v)
+}
+
+func TestExpiry(t *testing.T) {
+	s, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Set("short", []byte("1"), time.Millisecond))
+	assert.NoError(t, s.Set("vacuumed", []byte("1"), time.Millisecond))
+	assert.NoError(t, s.Set("long", []byte("2"), time.Hour))
+	time.Sleep(5 * time.Millisecond)
+
+	v, _ := s.Get("short")
+	assert.Nil(t, v, "expired value must not be returned")
+	_, err = os.Stat(s.path("short"))
+	assert.True(t, os.IsNotExist(err), "expired file must be removed")
+
+	n, err := s.Vacuum()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	v, _ = s.Get("long")
+	assert.Equal(t, []byte("2"), v)
+}
+
+func TestRemoteCache(t *testing.T) {
+	s, err := New(t.TempDir())
+	assert.NoError(t, err)
+
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(loader.RemoteCache[string](s)))
+	defer l.Close()
+	l.Load("a")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", v)
+	assert.Equal(t, 1, fetches)
+}
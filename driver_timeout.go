@@ -0,0 +1,39 @@
+package loader
+
+import "time"
+
+// WithDriverTimeout abandons the driver Get of Load after timeout and treats it as cache miss,
+// so a slow remote driver can't add more than the timeout (twice on a miss, as the loader re-checks the driver
+// after taking the key lock) to the latency. Abandoned Gets are counted in Stats.DriverTimeouts.
+func WithDriverTimeout(timeout time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.driverTimeout = timeout
+	})
+}
+
+type driverResult struct {
+	value interface{}
+	ok    bool
+}
+
+// driverGet gets the item from the driver, waiting up to the timeout of WithDriverTimeout
+func (l *Loader[Key, Value]) driverGet(dk interface{}) (interface{}, bool) {
+	if l.driverTimeout <= 0 {
+		return l.driver.Get(dk)
+	}
+
+	result := make(chan driverResult, 1)
+	go func() {
+		value, ok := l.driver.Get(dk)
+		result <- driverResult{value, ok}
+	}()
+	timer := time.NewTimer(l.driverTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-result:
+		return r.value, r.ok
+	case <-timer.C:
+		l.counters.driverTimeouts.Add(1)
+		return nil, false
+	}
+}
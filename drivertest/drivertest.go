@@ -0,0 +1,172 @@
+// Package drivertest is the conformance suite of loader.CacheDriver implementations.
+package drivertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// Run runs the conformance suite against the drivers created by newDriver, each subtest gets a new driver.
+// The drivers must hold the items of a loader whose keys and values are strings, and have room for 100 items.
+// The Remover and Ranger subtests are skipped if the drivers don't implement them.
+func Run(t *testing.T, newDriver func() loader.CacheDriver) {
+	t.Run("AddGet", func(t *testing.T) { testAddGet(t, newDriver()) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newDriver()) })
+	t.Run("Loader", func(t *testing.T) { testLoader(t, newDriver()) })
+	t.Run("TTL", func(t *testing.T) { testTTL(t, newDriver()) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newDriver()) })
+	t.Run("Remover", func(t *testing.T) { testRemover(t, newDriver()) })
+	t.Run("Ranger", func(t *testing.T) { testRanger(t, newDriver()) })
+}
+
+// item returns an item of loader, fetched and stored by the in-memory driver
+func item(t *testing.T, key, value string) interface{} {
+	source := loader.InMemoryCache()
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		return value, nil
+	}, time.Minute, loader.WithDriver(source))
+	defer l.Close()
+	if _, err := l.Load(key); err != nil {
+		t.Fatal(err)
+	}
+	stored, ok := source.Get(key)
+	if !ok {
+		t.Fatal("in-memory driver lost the item")
+	}
+	return stored
+}
+
+// assertEntry asserts that stored is an item of the loader with value
+func assertEntry(t *testing.T, stored interface{}, value string) {
+	t.Helper()
+	envelope, ok := stored.(loader.Envelope)
+	if !assert.True(t, ok, "Get must return the item added by the loader, got %T", stored) {
+		return
+	}
+	got, ok := envelope.EntryValue()
+	assert.True(t, ok, "item must be loaded")
+	assert.Equal(t, value, got)
+	meta, _ := envelope.EntryMeta()
+	assert.False(t, meta.Expire.IsZero(), "expiry must be kept")
+}
+
+func testAddGet(t *testing.T, driver loader.CacheDriver) {
+	_, ok := driver.Get("missing")
+	assert.False(t, ok, "Get of missing key must return false")
+
+	driver.Add("a", item(t, "a", "value a"))
+	stored, ok := driver.Get("a")
+	assert.True(t, ok)
+	assertEntry(t, stored, "value a")
+}
+
+func testOverwrite(t *testing.T, driver loader.CacheDriver) {
+	driver.Add("a", item(t, "a", "old"))
+	driver.Add("a", item(t, "a", "new"))
+	stored, ok := driver.Get("a")
+	assert.True(t, ok)
+	assertEntry(t, stored, "new")
+}
+
+func testLoader(t *testing.T, driver loader.CacheDriver) {
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(driver))
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		val, err := l.Load("a")
+		assert.NoError(t, err)
+		assert.Equal(t, "value a", val)
+	}
+	_, info, _ := l.LoadWithInfo("a")
+	assert.True(t, info.Cached, "value must be served from the driver")
+	assert.Equal(t, 1, fetches)
+}
+
+func testTTL(t *testing.T, driver loader.CacheDriver) {
+	var mutex sync.Mutex
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		fetches++
+		return fmt.Sprint("value ", fetches), nil
+	}, 20*time.Millisecond, loader.WithDriver(driver))
+	defer l.Close()
+
+	l.Load("a")
+	time.Sleep(30 * time.Millisecond)
+	val, info, _ := l.LoadWithInfo("a")
+	assert.Equal(t, "value 1", val, "expired value must be served stale")
+	assert.True(t, info.Stale)
+	l.Wait()
+	val, _ = l.Load("a")
+	assert.Equal(t, "value 2", val, "refreshed value must be stored in the driver")
+}
+
+func testConcurrency(t *testing.T, driver loader.CacheDriver) {
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(driver))
+	defer l.Close()
+
+	var group sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprint((i + j) % 50)
+				val, err := l.Load(key)
+				assert.NoError(t, err)
+				assert.Equal(t, "value "+key, val)
+			}
+		}(i)
+	}
+	group.Wait()
+}
+
+func testRemover(t *testing.T, driver loader.CacheDriver) {
+	remover, ok := driver.(loader.Remover)
+	if !ok {
+		t.Skip("driver doesn't implement Remover")
+	}
+	driver.Add("a", item(t, "a", "value a"))
+	remover.Remove("a")
+	_, ok = driver.Get("a")
+	assert.False(t, ok, "removed key must be missing")
+	remover.Remove("missing")
+}
+
+func testRanger(t *testing.T, driver loader.CacheDriver) {
+	ranger, ok := driver.(loader.Ranger)
+	if !ok {
+		t.Skip("driver doesn't implement Ranger")
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		driver.Add(key, item(t, key, "value "+key))
+	}
+	seen := map[interface{}]bool{}
+	ranger.Range(func(key, value interface{}) bool {
+		seen[key] = true
+		assertEntry(t, value, fmt.Sprint("value ", key))
+		return true
+	})
+	assert.Equal(t, map[interface{}]bool{"a": true, "b": true, "c": true}, seen)
+
+	calls := 0
+	ranger.Range(func(key, value interface{}) bool {
+		calls++
+		return false
+	})
+	assert.Equal(t, 1, calls, "Range must stop when fn returns false")
+}
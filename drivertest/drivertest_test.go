@@ -0,0 +1,69 @@
+package drivertest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+type memoryStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memoryStore) Set(key string, data []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func TestInMemoryCache(t *testing.T) {
+	Run(t, loader.InMemoryCache)
+}
+
+func TestShardedInMemoryCache(t *testing.T) {
+	Run(t, func() loader.CacheDriver { return loader.ShardedInMemoryCache(4) })
+}
+
+func TestBoundedInMemoryCache(t *testing.T) {
+	Run(t, func() loader.CacheDriver { return loader.BoundedInMemoryCache(1000) })
+	for name, policy := range map[string]func() loader.EvictionPolicy{
+		"LFU":    loader.LFUEviction,
+		"FIFO":   loader.FIFOEviction,
+		"SIEVE":  loader.SIEVEEviction,
+		"Random": loader.RandomEviction,
+	} {
+		policy := policy
+		t.Run(name, func(t *testing.T) {
+			Run(t, func() loader.CacheDriver {
+				return loader.BoundedInMemoryCache(1000, loader.WithEvictionPolicy(policy()))
+			})
+		})
+	}
+}
+
+func TestRemoteCache(t *testing.T) {
+	Run(t, func() loader.CacheDriver {
+		return loader.RemoteCache[string](&memoryStore{data: map[string][]byte{}})
+	})
+}
+
+func TestInMemoryKeyLocker(t *testing.T) {
+	RunKeyLocker(t, func() loader.KeyLocker[string] { return &loader.InMemoryKeyLocker[string]{} })
+}
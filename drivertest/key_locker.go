@@ -0,0 +1,83 @@
+package drivertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// RunKeyLocker runs the conformance suite against the lockers created by newLocker, each subtest gets a new locker.
+func RunKeyLocker(t *testing.T, newLocker func() loader.KeyLocker[string]) {
+	t.Run("TryLock", func(t *testing.T) { testTryLock(t, newLocker()) })
+	t.Run("LockCtx", func(t *testing.T) { testLockCtx(t, newLocker()) })
+	t.Run("Handoff", func(t *testing.T) { testHandoff(t, newLocker()) })
+	t.Run("MutualExclusion", func(t *testing.T) { testMutualExclusion(t, newLocker()) })
+}
+
+func testTryLock(t *testing.T, locker loader.KeyLocker[string]) {
+	unlock := locker.Lock("a")
+	_, ok := locker.TryLock("a")
+	assert.False(t, ok, "TryLock of locked key must fail")
+	unlockB, ok := locker.TryLock("b")
+	assert.True(t, ok, "keys must be locked independently")
+	unlockB()
+
+	unlock()
+	unlock()
+	unlock, ok = locker.TryLock("a")
+	assert.True(t, ok, "TryLock of unlocked key must succeed, calling unlock twice must be harmless")
+	unlock()
+}
+
+func testLockCtx(t *testing.T, locker loader.KeyLocker[string]) {
+	unlock := locker.Lock("a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := locker.LockCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	unlock()
+
+	unlock, err = locker.LockCtx(context.Background(), "a")
+	assert.NoError(t, err, "cancelled LockCtx must not hold the lock")
+	unlock()
+}
+
+func testHandoff(t *testing.T, locker loader.KeyLocker[string]) {
+	unlock := locker.Lock("a")
+	acquired := make(chan func())
+	go func() {
+		unlock, err := locker.LockCtx(context.Background(), "a")
+		assert.NoError(t, err)
+		acquired <- unlock
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock must not be acquired while it's held")
+	case <-time.After(10 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+}
+
+func testMutualExclusion(t *testing.T, locker loader.KeyLocker[string]) {
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				unlock := locker.Lock("a")
+				counter++
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, counter)
+}
@@ -0,0 +1,164 @@
+package loader
+
+import (
+	"sync"
+	"time"
+)
+
+// EdgeStore is RemoteStore of an eventually consistent key-value store of an edge runtime,
+// like Cloudflare Workers KV or Fastly KV Store. RemoteCache adapts its writes to the Limits.
+type EdgeStore interface {
+	RemoteStore
+	Limits() EdgeLimits
+}
+
+// EdgeLimits are the constraints of EdgeStore
+type EdgeLimits struct {
+	// MinTTL is the shortest TTL of Set, shorter TTLs are raised to it, e.g. 60 seconds for Workers KV
+	MinTTL time.Duration
+	// WriteInterval is the shortest time between writes of one key, e.g. 1 second for Workers KV.
+	// The writes of a key within it are delayed until it passes, only the latest of them is written.
+	WriteInterval time.Duration
+	// Lag is how long a write or delete takes to be visible in every location, e.g. 60 seconds for Workers KV
+	Lag time.Duration
+}
+
+// WithLagTolerance serves the records of EdgeStore as fresh until Lag after they expire,
+// since the refresh of other location may not be visible yet, instead of refreshing them in every location at once.
+// The lag counts as acceptable data age, so values can be up to the TTL plus Lag old.
+// It's a no-op if the store isn't EdgeStore.
+func WithLagTolerance() RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.lagTolerance = true
+	}
+}
+
+// edgeWrites throttles the writes of RemoteCache to EdgeStore.
+// A write within WriteInterval of the previous one is delayed until the interval passes,
+// and replaced by the later writes of the key meanwhile, so the last write is never lost.
+type edgeWrites struct {
+	limits EdgeLimits
+	store  RemoteStore
+	report func(err error)
+
+	mutex   sync.Mutex
+	last    map[string]time.Time
+	pending map[string]*edgeWrite
+}
+
+// edgeWrite is a delayed write, ttl is counted from at
+type edgeWrite struct {
+	data []byte
+	ttl  time.Duration
+	at   time.Time
+}
+
+func newEdgeWrites(store RemoteStore, report func(err error)) *edgeWrites {
+	edge, ok := store.(EdgeStore)
+	if !ok {
+		return nil
+	}
+	return &edgeWrites{limits: edge.Limits(), store: store, report: report, last: map[string]time.Time{}, pending: map[string]*edgeWrite{}}
+}
+
+// set writes data to the store, or delays it if key was written less than WriteInterval ago
+func (w *edgeWrites) set(key string, data []byte, ttl time.Duration) error {
+	ttl = w.ttl(ttl)
+	if w.limits.WriteInterval <= 0 {
+		return w.store.Set(key, data, ttl)
+	}
+	now := time.Now()
+	w.mutex.Lock()
+	if wait := w.wait(key, now); wait > 0 {
+		if _, ok := w.pending[key]; !ok {
+			time.AfterFunc(wait, func() { w.flush(key) })
+		}
+		w.pending[key] = &edgeWrite{data: data, ttl: ttl, at: now}
+		w.mutex.Unlock()
+		return nil
+	}
+	w.written(key, now)
+	w.mutex.Unlock()
+	return w.store.Set(key, data, ttl)
+}
+
+// deleted drops the delayed write of key, the delete counts as a write of the key
+func (w *edgeWrites) deleted(key string) {
+	if w.limits.WriteInterval <= 0 {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.pending, key)
+	w.written(key, time.Now())
+}
+
+// pendingData returns the data of the delayed write of key, so the process reads its own write
+func (w *edgeWrites) pendingData(key string) ([]byte, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if p, ok := w.pending[key]; ok {
+		return p.data, true
+	}
+	return nil, false
+}
+
+// flush writes the delayed write of key
+func (w *edgeWrites) flush(key string) {
+	now := time.Now()
+	w.mutex.Lock()
+	p, ok := w.pending[key]
+	if !ok {
+		w.mutex.Unlock()
+		return
+	}
+	if wait := w.wait(key, now); wait > 0 {
+		// key was deleted meanwhile
+		time.AfterFunc(wait, func() { w.flush(key) })
+		w.mutex.Unlock()
+		return
+	}
+	delete(w.pending, key)
+	w.written(key, now)
+	w.mutex.Unlock()
+
+	ttl := p.ttl
+	if ttl > 0 {
+		if ttl -= now.Sub(p.at); ttl <= 0 {
+			// the value expired while it was delayed
+			return
+		}
+	}
+	if err := w.store.Set(key, p.data, w.ttl(ttl)); err != nil && w.report != nil {
+		w.report(&storeError{err})
+	}
+}
+
+// wait returns how long until key can be written again
+func (w *edgeWrites) wait(key string, now time.Time) time.Duration {
+	if last, ok := w.last[key]; ok {
+		return w.limits.WriteInterval - now.Sub(last)
+	}
+	return 0
+}
+
+// written records the write of key at now
+func (w *edgeWrites) written(key string, now time.Time) {
+	if len(w.last) >= 1024 {
+		// forget the keys that can be written again, so the map doesn't grow with every key
+		for k, last := range w.last {
+			if now.Sub(last) >= w.limits.WriteInterval {
+				delete(w.last, k)
+			}
+		}
+	}
+	w.last[key] = now
+}
+
+// ttl raises ttl to MinTTL, zero means it never expires
+func (w *edgeWrites) ttl(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < w.limits.MinTTL {
+		return w.limits.MinTTL
+	}
+	return ttl
+}
@@ -0,0 +1,89 @@
+package loader
+
+import "time"
+
+// EntryMeta is the metadata of a cached entry
+type EntryMeta struct {
+	// Err is the error of the fetch, it's cached for the error ttl
+	Err error
+	// FetchedAt is the time when the fetch completed
+	FetchedAt time.Time
+	// Expire is the soft expiry, after it the value is served stale while refreshing
+	Expire time.Time
+	// HardExpire is the hard expiry, after it the value is never served, zero means never
+	HardExpire time.Time
+	// Version starts from 1 and is incremented every time the entry is refreshed
+	Version uint64
+	// Tag is the validator of NewRevalidating
+	Tag string
+	// Stamp is the version returned by the fetcher of NewVersioned, zero otherwise
+	Stamp uint64
+}
+
+// Envelope is implemented by the items that Loader adds to CacheDriver.
+// Custom drivers and tools can type-assert the stored interface{} to it instead of depending on the loader internals.
+type Envelope interface {
+	// EntryMeta returns the metadata, ok is false while the first fetch is in progress
+	EntryMeta() (meta EntryMeta, ok bool)
+	// EntryValue returns the cached value, ok is false while the first fetch is in progress
+	EntryValue() (value interface{}, ok bool)
+}
+
+// InspectEntry returns the value and metadata of stored, an item that Loader[Key, Value] added to its driver
+func InspectEntry[Value any](stored interface{}) (value Value, meta EntryMeta, ok bool) {
+	item, isItem := stored.(*cacheItem[Value])
+	if !isItem {
+		return value, meta, false
+	}
+	p := item.payload.Load()
+	if p == nil {
+		return value, meta, false
+	}
+	return p.value, p.meta(), true
+}
+
+// EntryMeta implements Envelope
+func (i *cacheItem[Value]) EntryMeta() (EntryMeta, bool) {
+	p := i.payload.Load()
+	if p == nil {
+		return EntryMeta{}, false
+	}
+	return p.meta(), true
+}
+
+// EntryValue implements Envelope
+func (i *cacheItem[Value]) EntryValue() (interface{}, bool) {
+	p := i.payload.Load()
+	if p == nil {
+		return nil, false
+	}
+	return p.value, true
+}
+
+func (p *payload[Value]) meta() EntryMeta {
+	return EntryMeta{
+		Err:        p.err,
+		FetchedAt:  p.fetchedAt,
+		Expire:     p.expire,
+		HardExpire: p.hardExpire,
+		Version:    p.version,
+		Tag:        p.tag,
+		Stamp:      p.stamp,
+	}
+}
+
+// hardTTL returns how long the item added by Loader can still be served, for drivers that expire items themselves.
+// ttl is zero if it has no hard expiry, or if its first fetch is in progress: the loader re-adds the items of
+// write-through drivers after every fetch.
+func hardTTL(value interface{}) (ttl time.Duration, expired bool) {
+	e, ok := value.(Envelope)
+	if !ok {
+		return 0, false
+	}
+	meta, ok := e.EntryMeta()
+	if !ok || meta.HardExpire.IsZero() {
+		return 0, false
+	}
+	ttl = time.Until(meta.HardExpire)
+	return ttl, ttl <= 0
+}
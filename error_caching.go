@@ -0,0 +1,23 @@
+package loader
+
+import "fmt"
+
+// WithNoErrorCaching never caches failed fetches, so every Load after a failure fetches again.
+// Concurrent Loads of a failing fetch still share its error. A failed refresh keeps serving the stale value
+// and it's retried by the next Load. The driver must implement Remover.
+func WithNoErrorCaching() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.noErrorCaching = true
+	})
+}
+
+// checkNoErrorCaching validates the driver used with WithNoErrorCaching
+func (l *Loader[Key, Value]) checkNoErrorCaching() error {
+	if !l.noErrorCaching {
+		return nil
+	}
+	if _, ok := l.driver.(Remover); !ok {
+		return fmt.Errorf("%w: WithNoErrorCaching requires a driver that implements Remover", ErrInvalidConfig)
+	}
+	return nil
+}
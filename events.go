@@ -0,0 +1,216 @@
+package loader
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the kind of an Event
+type EventType int
+
+const (
+	// EventMiss is sent when a load has to fetch the value
+	EventMiss EventType = iota + 1
+	// EventHit is sent when a load is served a fresh value from cache
+	EventHit
+	// EventStaleHit is sent when a load is served an expired value from cache
+	EventStaleHit
+	// EventRefreshStart is sent when a background refresh calls the fetcher
+	EventRefreshStart
+	// EventRefreshError is sent when a background refresh fails, Err is the error of the fetcher
+	EventRefreshError
+	// EventEvict is sent when the item is removed by Invalidate, WithMaxValueSize or WithAdmissionPolicy,
+	// when it's found hard expired, and when the driver evicts it for capacity or expiry, see EvictionNotifier.
+	// InvalidateAll doesn't send it.
+	EventEvict
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventMiss:
+		return "miss"
+	case EventHit:
+		return "hit"
+	case EventStaleHit:
+		return "stale-hit"
+	case EventRefreshStart:
+		return "refresh-start"
+	case EventRefreshError:
+		return "refresh-error"
+	case EventEvict:
+		return "evict"
+	}
+	return "unknown"
+}
+
+// Event is sent to the channels returned by Events
+type Event[Key comparable] struct {
+	Type EventType
+	Key  Key
+	// Err is set for EventRefreshError
+	Err  error
+	Time time.Time
+}
+
+// eventBufferSize is the buffer of every channel returned by Events
+const eventBufferSize = 1024
+
+// eventBus fans the events of a loader out to its subscribers.
+// emit reads the subscribers without the mutex, they're replaced by a modified copy on every change.
+type eventBus[Key comparable] struct {
+	mutex       sync.Mutex
+	subscribers atomic.Pointer[[]*eventSubscriber[Key]]
+	isClosed    bool
+}
+
+// eventSubscriber is a channel of Events, its read lock is held while sending so it isn't closed meanwhile
+type eventSubscriber[Key comparable] struct {
+	mutex    sync.RWMutex
+	ch       chan Event[Key]
+	isClosed bool
+	done     chan struct{}
+}
+
+// Events returns a channel that receives the events of the loader, every call returns a new channel.
+// Events are dropped instead of blocking loads when the channel is full, see Stats.EventsDropped.
+// The channel is closed when the loader is closed.
+func (l *Loader[Key, Value]) Events() <-chan Event[Key] {
+	return l.EventsCtx(context.Background())
+}
+
+// EventsCtx is Events that unsubscribes when ctx is done, closing the channel
+func (l *Loader[Key, Value]) EventsCtx(ctx context.Context) <-chan Event[Key] {
+	sub := &eventSubscriber[Key]{ch: make(chan Event[Key], eventBufferSize), done: make(chan struct{})}
+	bus := l.eventBus()
+	if !bus.subscribe(sub) {
+		sub.close()
+		return sub.ch
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				bus.unsubscribe(sub)
+			case <-sub.done:
+			}
+		}()
+	}
+	return sub.ch
+}
+
+// eventBus returns the bus of the loader, creating it on the first call of Events
+func (l *Loader[Key, Value]) eventBus() *eventBus[Key] {
+	for {
+		if bus := l.events.Load(); bus != nil {
+			return bus
+		}
+		bus := &eventBus[Key]{}
+		if l.events.CompareAndSwap(nil, bus) {
+			l.lifecycle.mutex.Lock()
+			closed := l.lifecycle.isClosed
+			if !closed {
+				l.onClose = append(l.onClose, bus.close)
+			}
+			l.lifecycle.mutex.Unlock()
+			if closed {
+				bus.close()
+			}
+			return bus
+		}
+	}
+}
+
+// emit sends the event to every channel of Events, it costs a single atomic load when Events is never called
+func (l *Loader[Key, Value]) emit(typ EventType, key Key, err error) {
+	bus := l.events.Load()
+	if bus == nil {
+		return
+	}
+	subscribers := bus.subscribers.Load()
+	if subscribers == nil {
+		return
+	}
+	event := Event[Key]{Type: typ, Key: key, Err: err, Time: time.Now()}
+	for _, sub := range *subscribers {
+		if !sub.send(event) {
+			l.counters.eventsDropped.Add(1)
+		}
+	}
+}
+
+// send reports false if the event is dropped because the channel is full
+func (sub *eventSubscriber[Key]) send(event Event[Key]) bool {
+	sub.mutex.RLock()
+	defer sub.mutex.RUnlock()
+	if sub.isClosed {
+		return true
+	}
+	select {
+	case sub.ch <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sub *eventSubscriber[Key]) close() {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.isClosed {
+		return
+	}
+	sub.isClosed = true
+	close(sub.ch)
+	close(sub.done)
+}
+
+// update replaces the subscribers with a modified copy, it reports false if the bus is closed
+func (bus *eventBus[Key]) update(modify func(subscribers []*eventSubscriber[Key]) []*eventSubscriber[Key]) bool {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.isClosed {
+		return false
+	}
+	var subscribers []*eventSubscriber[Key]
+	if old := bus.subscribers.Load(); old != nil {
+		subscribers = append(subscribers, *old...)
+	}
+	subscribers = modify(subscribers)
+	bus.subscribers.Store(&subscribers)
+	return true
+}
+
+func (bus *eventBus[Key]) subscribe(sub *eventSubscriber[Key]) bool {
+	return bus.update(func(subscribers []*eventSubscriber[Key]) []*eventSubscriber[Key] {
+		return append(subscribers, sub)
+	})
+}
+
+func (bus *eventBus[Key]) unsubscribe(sub *eventSubscriber[Key]) {
+	bus.update(func(subscribers []*eventSubscriber[Key]) []*eventSubscriber[Key] {
+		kept := subscribers[:0]
+		for _, s := range subscribers {
+			if s != sub {
+				kept = append(kept, s)
+			}
+		}
+		return kept
+	})
+	sub.close()
+}
+
+func (bus *eventBus[Key]) close() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	if bus.isClosed {
+		return
+	}
+	bus.isClosed = true
+	if subscribers := bus.subscribers.Swap(nil); subscribers != nil {
+		for _, sub := range *subscribers {
+			sub.close()
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package loader
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+)
+
+// EvictionPolicy chooses the items that BoundedInMemoryCache evicts when its total cost is exceeded.
+// The cache calls it with its mutex held, so implementations don't need to synchronize,
+// but a policy must not be shared by several caches.
+type EvictionPolicy interface {
+	// Add records that key is cached with cost, or that its value is replaced with one of cost
+	Add(key interface{}, cost int64)
+	// Access records a cache hit of key
+	Access(key interface{})
+	// Remove forgets key, it's called for evicted keys too
+	Remove(key interface{})
+	// Victim returns the key to evict next without removing it, it must not return keep.
+	// ok is false if there is no other key.
+	Victim(keep interface{}) (key interface{}, ok bool)
+}
+
+// WithEvictionPolicy sets the EvictionPolicy of BoundedInMemoryCache, the default is LRUEviction
+func WithEvictionPolicy(policy EvictionPolicy) BoundedCacheOption {
+	return func(c *boundedCache) {
+		c.policy = policy
+	}
+}
+
+// evictionSample is how many of the least recently used items are considered on eviction
+const evictionSample = 4
+
+type lruEviction struct {
+	order *list.List
+	items map[interface{}]*list.Element
+}
+
+type lruEntry struct {
+	key  interface{}
+	cost int64
+}
+
+// LRUEviction evicts the least recently used items, the costliest of the few least recently used first
+func LRUEviction() EvictionPolicy {
+	return &lruEviction{order: list.New(), items: map[interface{}]*list.Element{}}
+}
+
+func (p *lruEviction) Add(key interface{}, cost int64) {
+	if el, ok := p.items[key]; ok {
+		el.Value.(*lruEntry).cost = cost
+		p.order.MoveToFront(el)
+		return
+	}
+	p.items[key] = p.order.PushFront(&lruEntry{key: key, cost: cost})
+}
+
+func (p *lruEviction) Access(key interface{}) {
+	if el, ok := p.items[key]; ok {
+		p.order.MoveToFront(el)
+	}
+}
+
+func (p *lruEviction) Remove(key interface{}) {
+	if el, ok := p.items[key]; ok {
+		p.order.Remove(el)
+		delete(p.items, key)
+	}
+}
+
+func (p *lruEviction) Victim(keep interface{}) (interface{}, bool) {
+	var victim *lruEntry
+	sampled := 0
+	for el := p.order.Back(); el != nil && sampled < evictionSample; el = el.Prev() {
+		entry := el.Value.(*lruEntry)
+		if entry.key == keep {
+			continue
+		}
+		if victim == nil || entry.cost > victim.cost {
+			victim = entry
+		}
+		sampled++
+	}
+	if victim == nil {
+		return nil, false
+	}
+	return victim.key, true
+}
+
+type fifoEviction struct {
+	order *list.List
+	items map[interface{}]*list.Element
+}
+
+// FIFOEviction evicts the items that were added first, regardless of how they're used
+func FIFOEviction() EvictionPolicy {
+	return &fifoEviction{order: list.New(), items: map[interface{}]*list.Element{}}
+}
+
+func (p *fifoEviction) Add(key interface{}, cost int64) {
+	if _, ok := p.items[key]; !ok {
+		p.items[key] = p.order.PushBack(key)
+	}
+}
+
+func (p *fifoEviction) Access(key interface{}) {}
+
+func (p *fifoEviction) Remove(key interface{}) {
+	if el, ok := p.items[key]; ok {
+		p.order.Remove(el)
+		delete(p.items, key)
+	}
+}
+
+func (p *fifoEviction) Victim(keep interface{}) (interface{}, bool) {
+	for el := p.order.Front(); el != nil; el = el.Next() {
+		if el.Value != keep {
+			return el.Value, true
+		}
+	}
+	return nil, false
+}
+
+type lfuEviction struct {
+	heap lfuHeap
+	// seq breaks ties of hits, so the older of the least used items is evicted
+	seq uint64
+}
+
+type lfuEntry struct {
+	key   interface{}
+	hits  uint64
+	seq   uint64
+	index int
+}
+
+// lfuHeap is min-heap of the entries by hits
+type lfuHeap struct {
+	entries []*lfuEntry
+	items   map[interface{}]*lfuEntry
+}
+
+func (h *lfuHeap) Len() int { return len(h.entries) }
+
+func (h *lfuHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	return a.hits < b.hits || a.hits == b.hits && a.seq < b.seq
+}
+
+func (h *lfuHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	entry := x.(*lfuEntry)
+	entry.index = len(h.entries)
+	h.entries = append(h.entries, entry)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries[len(h.entries)-1] = nil
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
+
+// LFUEviction evicts the least frequently used items, the older one first when their hits are the same
+func LFUEviction() EvictionPolicy {
+	return &lfuEviction{heap: lfuHeap{items: map[interface{}]*lfuEntry{}}}
+}
+
+func (p *lfuEviction) Add(key interface{}, cost int64) {
+	if _, ok := p.heap.items[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.seq++
+	entry := &lfuEntry{key: key, hits: 1, seq: p.seq}
+	p.heap.items[key] = entry
+	heap.Push(&p.heap, entry)
+}
+
+func (p *lfuEviction) Access(key interface{}) {
+	if entry, ok := p.heap.items[key]; ok {
+		entry.hits++
+		heap.Fix(&p.heap, entry.index)
+	}
+}
+
+func (p *lfuEviction) Remove(key interface{}) {
+	if entry, ok := p.heap.items[key]; ok {
+		heap.Remove(&p.heap, entry.index)
+		delete(p.heap.items, key)
+	}
+}
+
+func (p *lfuEviction) Victim(keep interface{}) (interface{}, bool) {
+	entries := p.heap.entries
+	if len(entries) == 0 || len(entries) == 1 && entries[0].key == keep {
+		return nil, false
+	}
+	if entries[0].key != keep {
+		return entries[0].key, true
+	}
+	// the next least used entry is one of the children of the root
+	victim := 1
+	if len(entries) > 2 && p.heap.Less(2, 1) {
+		victim = 2
+	}
+	return entries[victim].key, true
+}
+
+type sieveEviction struct {
+	// order has the newest item at the front
+	order *list.List
+	items map[interface{}]*list.Element
+	// hand is the next item to check, nil means the oldest
+	hand *list.Element
+}
+
+type sieveEntry struct {
+	key     interface{}
+	visited bool
+}
+
+// SIEVEEviction implements SIEVE: items stay in insertion order, a hit only marks its item as visited,
+// and a hand moving from the oldest item evicts the first one that is not visited, unmarking the ones it passes.
+// Hits don't reorder items, so they're cheaper than LRU, and one-hit-wonders are evicted quickly.
+func SIEVEEviction() EvictionPolicy {
+	return &sieveEviction{order: list.New(), items: map[interface{}]*list.Element{}}
+}
+
+func (p *sieveEviction) Add(key interface{}, cost int64) {
+	if el, ok := p.items[key]; ok {
+		el.Value.(*sieveEntry).visited = true
+		return
+	}
+	p.items[key] = p.order.PushFront(&sieveEntry{key: key})
+}
+
+func (p *sieveEviction) Access(key interface{}) {
+	if el, ok := p.items[key]; ok {
+		el.Value.(*sieveEntry).visited = true
+	}
+}
+
+func (p *sieveEviction) Remove(key interface{}) {
+	el, ok := p.items[key]
+	if !ok {
+		return
+	}
+	if p.hand == el {
+		p.hand = el.Prev()
+	}
+	p.order.Remove(el)
+	delete(p.items, key)
+}
+
+func (p *sieveEviction) Victim(keep interface{}) (interface{}, bool) {
+	if len(p.items) == 0 || len(p.items) == 1 && p.items[keep] != nil {
+		return nil, false
+	}
+	el := p.hand
+	for {
+		if el == nil {
+			el = p.order.Back()
+		}
+		entry := el.Value.(*sieveEntry)
+		if entry.key != keep {
+			if !entry.visited {
+				p.hand = el
+				return entry.key, true
+			}
+			entry.visited = false
+		}
+		el = el.Prev()
+	}
+}
+
+type randomEviction struct {
+	keys  []interface{}
+	index map[interface{}]int
+}
+
+// RandomEviction evicts random items, it keeps no usage data so it's the cheapest on hits
+func RandomEviction() EvictionPolicy {
+	return &randomEviction{index: map[interface{}]int{}}
+}
+
+func (p *randomEviction) Add(key interface{}, cost int64) {
+	if _, ok := p.index[key]; !ok {
+		p.index[key] = len(p.keys)
+		p.keys = append(p.keys, key)
+	}
+}
+
+func (p *randomEviction) Access(key interface{}) {}
+
+func (p *randomEviction) Remove(key interface{}) {
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i]] = i
+	p.keys[last] = nil
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *randomEviction) Victim(keep interface{}) (interface{}, bool) {
+	n := len(p.keys)
+	if n == 0 || n == 1 && p.keys[0] == keep {
+		return nil, false
+	}
+	i := rand.Intn(n)
+	if p.keys[i] == keep {
+		i = (i + 1) % n
+	}
+	return p.keys[i], true
+}
@@ -0,0 +1,110 @@
+package loader
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// EvictionNotifier is implemented by cache drivers that remove items on their own, for capacity or expiry.
+// New calls OnEvict, and the driver calls fn with the key and the value of every item it evicts,
+// but not of the ones removed by Remove or replaced by Add. fn must not be called while the driver holds its lock.
+// cancel is called when the loader is closed.
+type EvictionNotifier interface {
+	OnEvict(fn func(key, value interface{})) (cancel func())
+}
+
+// EvictionListener implements EvictionNotifier for caches that are built before the loader, like the ones of NewOtter and NewTheine:
+// call Evicted from the deletion listener of the cache for the entries it evicts or expires, and pass it to WithEvictionListener.
+// The zero value is ready to use.
+type EvictionListener struct {
+	mutex sync.Mutex
+	fns   atomic.Pointer[[]*evictionFunc]
+}
+
+type evictionFunc struct {
+	fn func(key, value interface{})
+}
+
+// OnEvict implements EvictionNotifier
+func (e *EvictionListener) OnEvict(fn func(key, value interface{})) (cancel func()) {
+	sub := &evictionFunc{fn}
+	e.update(func(fns []*evictionFunc) []*evictionFunc {
+		return append(fns, sub)
+	})
+	return func() {
+		e.update(func(fns []*evictionFunc) []*evictionFunc {
+			kept := fns[:0]
+			for _, f := range fns {
+				if f != sub {
+					kept = append(kept, f)
+				}
+			}
+			return kept
+		})
+	}
+}
+
+// update replaces the functions with a modified copy, so Evicted never takes the mutex
+func (e *EvictionListener) update(modify func(fns []*evictionFunc) []*evictionFunc) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var fns []*evictionFunc
+	if old := e.fns.Load(); old != nil {
+		fns = append(fns, *old...)
+	}
+	fns = modify(fns)
+	e.fns.Store(&fns)
+}
+
+// Evicted notifies the loaders that the item of key has been evicted
+func (e *EvictionListener) Evicted(key, value interface{}) {
+	fns := e.fns.Load()
+	if fns == nil {
+		return
+	}
+	for _, f := range *fns {
+		f.fn(key, value)
+	}
+}
+
+// WithEvictionListener sends EventEvict for the items reported by listener,
+// for drivers that don't implement EvictionNotifier themselves
+func WithEvictionListener(listener *EvictionListener) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.evictionListener = listener
+	})
+}
+
+// evictedItem is an item evicted by a driver, it's notified after the driver releases its lock
+type evictedItem struct {
+	key, value interface{}
+}
+
+// setupEvictions subscribes the loader to the evictions of the driver and of WithEvictionListener
+func (l *Loader[Key, Value]) setupEvictions() {
+	if notifier, ok := l.driver.(EvictionNotifier); ok {
+		l.onClose = append(l.onClose, notifier.OnEvict(l.evicted))
+	}
+	if l.evictionListener != nil {
+		l.onClose = append(l.onClose, l.evictionListener.OnEvict(l.evicted))
+	}
+}
+
+// evicted is called by the driver for the items it evicts, it ignores the items of other loaders sharing the driver
+func (l *Loader[Key, Value]) evicted(dk, value interface{}) {
+	item, ok := value.(*cacheItem[Value])
+	if !ok {
+		return
+	}
+	key, ok := l.loaderKey(dk)
+	if !ok {
+		return
+	}
+	if l.deps != nil {
+		l.deps.evicted(key)
+	}
+	if l.onEvict != nil {
+		l.onEvict(key, item)
+	}
+	l.emit(EventEvict, key, nil)
+}
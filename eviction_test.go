@@ -0,0 +1,66 @@
+package loader
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPolicy(t *testing.T) {
+	cached := func(c CacheDriver) []string {
+		var keys []string
+		for _, key := range []string{"a", "b", "c", "d"} {
+			if _, ok := c.(*boundedCache).items[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	}
+	for name, tc := range map[string]struct {
+		policy EvictionPolicy
+		want   []string
+	}{
+		"LRU":  {LRUEviction(), []string{"a", "c", "d"}},
+		"FIFO": {FIFOEviction(), []string{"b", "c", "d"}},
+		"LFU":  {LFUEviction(), []string{"a", "b", "d"}},
+		// every item is visited, the hand unmarks them all and comes back to the oldest
+		"SIEVE": {SIEVEEviction(), []string{"b", "c", "d"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := BoundedInMemoryCache(3, WithEvictionPolicy(tc.policy))
+			c.Add("a", 1)
+			c.Add("b", 2)
+			c.Add("c", 3)
+			for i := 0; i < 3; i++ {
+				c.Get("b")
+			}
+			c.Get("c")
+			c.Get("a")
+			c.Get("a")
+			c.Add("d", 4)
+			assert.Equal(t, tc.want, cached(c))
+		})
+	}
+
+	c := BoundedInMemoryCache(3, WithEvictionPolicy(SIEVEEviction()))
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+	c.Add("d", 4)
+	assert.Equal(t, []string{"a", "c", "d"}, cached(c), "SIEVE must evict the oldest unvisited item")
+	c.Add("e", 5)
+	assert.Equal(t, []string{"a", "d"}, cached(c), "the hand must continue from the evicted item")
+
+	c = BoundedInMemoryCache(3, WithEvictionPolicy(RandomEviction()))
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint(i)
+		c.Add(key, i)
+		_, ok := c.Get(key)
+		assert.True(t, ok, "the added item must never be evicted")
+	}
+	assert.Equal(t, int64(3), c.(CostReporter).Cost())
+	c.(Remover).Remove("99")
+	assert.Equal(t, int64(2), c.(CostReporter).Cost())
+}
@@ -0,0 +1,114 @@
+package loader
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// expiryIndex is a min-heap of items ordered by due time.
+// It fires due for each item once its due time has passed, using a single timer for the earliest one.
+type expiryIndex[Key comparable, Value any] struct {
+	mutex   sync.Mutex
+	entries expiryEntries[Key, Value]
+	timer   *time.Timer
+	next    time.Time
+	stopped bool
+	due     func(key Key, item *cacheItem[Value], p *payload[Value])
+}
+
+type expiryEntry[Key comparable, Value any] struct {
+	key     Key
+	item    *cacheItem[Value]
+	payload *payload[Value]
+	at      time.Time
+}
+
+func newExpiryIndex[Key comparable, Value any](due func(key Key, item *cacheItem[Value], p *payload[Value])) *expiryIndex[Key, Value] {
+	return &expiryIndex[Key, Value]{due: due}
+}
+
+// schedule adds the item with payload p to the index, to be fired at the given time
+func (x *expiryIndex[Key, Value]) schedule(key Key, item *cacheItem[Value], p *payload[Value], at time.Time) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	heap.Push(&x.entries, &expiryEntry[Key, Value]{key: key, item: item, payload: p, at: at})
+	x.resetTimer()
+}
+
+// resetTimer makes the timer fire at the earliest due time, the caller must hold the mutex
+func (x *expiryIndex[Key, Value]) resetTimer() {
+	if x.stopped || len(x.entries) == 0 {
+		return
+	}
+	at := x.entries[0].at
+	if x.timer != nil && !x.next.IsZero() && !at.Before(x.next) {
+		return
+	}
+	x.next = at
+	if x.timer == nil {
+		x.timer = time.AfterFunc(time.Until(at), x.fire)
+	} else {
+		x.timer.Reset(time.Until(at))
+	}
+}
+
+// fire pops and handles every entry that is due
+func (x *expiryIndex[Key, Value]) fire() {
+	now := time.Now()
+	var due []*expiryEntry[Key, Value]
+
+	x.mutex.Lock()
+	for len(x.entries) > 0 && !x.entries[0].at.After(now) {
+		due = append(due, heap.Pop(&x.entries).(*expiryEntry[Key, Value]))
+	}
+	x.next = time.Time{}
+	x.resetTimer()
+	x.mutex.Unlock()
+
+	for _, e := range due {
+		// skip entries that has been refreshed since they were scheduled
+		if e.item.payload.Load() == e.payload {
+			x.due(e.key, e.item, e.payload)
+		}
+	}
+}
+
+// stop stops the timer, the remaining entries are never fired
+func (x *expiryIndex[Key, Value]) stop() {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.stopped = true
+	if x.timer != nil {
+		x.timer.Stop()
+	}
+}
+
+// len returns the number of scheduled entries
+func (x *expiryIndex[Key, Value]) len() int {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+	return len(x.entries)
+}
+
+// expiryEntries implements heap.Interface, ordered by due time ascending
+type expiryEntries[Key comparable, Value any] []*expiryEntry[Key, Value]
+
+func (e expiryEntries[Key, Value]) Len() int           { return len(e) }
+func (e expiryEntries[Key, Value]) Less(i, j int) bool { return e[i].at.Before(e[j].at) }
+func (e expiryEntries[Key, Value]) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+func (e *expiryEntries[Key, Value]) Push(x any) {
+	*e = append(*e, x.(*expiryEntry[Key, Value]))
+}
+
+func (e *expiryEntries[Key, Value]) Pop() any {
+	old := *e
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	*e = old[:n-1]
+	return entry
+}
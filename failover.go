@@ -0,0 +1,135 @@
+package loader
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithFailover makes RemoteCache fall back to a local driver created by newLocal while the RemoteStore fails.
+// The store is retried every retry interval, and the local driver is dropped once the store works again.
+// Keys removed while the store is down are deleted from it before it's used again.
+// If newLocal is nil, InMemoryCache is used.
+func WithFailover(retry time.Duration, newLocal func() CacheDriver) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		if newLocal == nil {
+			newLocal = InMemoryCache
+		}
+		c.failover = &failover{retry: retry, newLocal: newLocal}
+	}
+}
+
+// WithFailoverHandler sets the function that is called when RemoteCache switches to the local driver
+// (available is false, with the store error) and back to the store (available is true).
+func WithFailoverHandler(onChange func(available bool, err error)) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.onFailover = onChange
+	}
+}
+
+type failover struct {
+	retry    time.Duration
+	newLocal func() CacheDriver
+	onChange func(available bool, err error)
+
+	down    atomic.Bool
+	mutex   sync.Mutex
+	driver  CacheDriver
+	retryAt time.Time
+	// deletes are the keys removed while the store is down, they're deleted from the store before it's used again
+	deletes map[interface{}]struct{}
+}
+
+// local returns the local driver while the store is down and it's not the time to retry yet.
+// retry is true for the call that may try the store.
+func (f *failover) local() (driver CacheDriver, retry bool) {
+	if f == nil || !f.down.Load() {
+		return nil, false
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if time.Now().After(f.retryAt) {
+		// let this call try the store, fail pushes retryAt back if it still fails
+		f.retryAt = time.Now().Add(f.retry)
+		return nil, true
+	}
+	return f.driver, false
+}
+
+// deleteLater records the removal of key while the store is down
+func (f *failover) deleteLater(key interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.deletes == nil {
+		f.deletes = map[interface{}]struct{}{}
+	}
+	f.deletes[key] = struct{}{}
+}
+
+// replay deletes the keys removed while the store was down, it stops at the first error and keeps the rest
+func (f *failover) replay(del func(key interface{}) error) error {
+	f.mutex.Lock()
+	keys := make([]interface{}, 0, len(f.deletes))
+	for key := range f.deletes {
+		keys = append(keys, key)
+	}
+	f.mutex.Unlock()
+
+	for _, key := range keys {
+		if err := del(key); err != nil {
+			return err
+		}
+		f.mutex.Lock()
+		delete(f.deletes, key)
+		f.mutex.Unlock()
+	}
+	return nil
+}
+
+// fail switches to the local driver, it returns the local driver
+func (f *failover) fail(err error) CacheDriver {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.retryAt = time.Now().Add(f.retry)
+	if f.down.Load() {
+		return f.driver
+	}
+	f.driver = f.newLocal()
+	f.down.Store(true)
+	if f.onChange != nil {
+		f.onChange(false, err)
+	}
+	return f.driver
+}
+
+// recovered switches back to the store after it works again
+func (f *failover) recovered() {
+	if f == nil || !f.down.Load() {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if !f.down.Load() || len(f.deletes) > 0 {
+		// keys removed since the last replay, the next retry deletes them first
+		return
+	}
+	f.driver = nil
+	f.down.Store(false)
+	if f.onChange != nil {
+		f.onChange(true, nil)
+	}
+}
+
+// storeError wraps the errors of RemoteStore, so they can be told apart from codec errors
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string { return e.err.Error() }
+func (e *storeError) Unwrap() error { return e.err }
+
+func isStoreError(err error) bool {
+	var se *storeError
+	return errors.As(err, &se)
+}
@@ -0,0 +1,64 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+)
+
+// FetchReason tells the fetcher why it's called, see FetchReasonFromContext
+type FetchReason int
+
+const (
+	// FetchUnknown means the context wasn't created by a loader
+	FetchUnknown FetchReason = iota
+	// FetchColdMiss means the key isn't cached, or its item is hard expired, and a caller waits for the fetch
+	FetchColdMiss
+	// FetchStaleRefresh means the item is expired and refreshed while its stale value is served
+	FetchStaleRefresh
+	// FetchForceRefresh means the item is refreshed before it expires, by WithRefreshAhead or WithPeriodicRefresh
+	FetchForceRefresh
+	// FetchWarmUp means the key is loaded by Warm or WarmUp
+	FetchWarmUp
+)
+
+func (r FetchReason) String() string {
+	switch r {
+	case FetchUnknown:
+		return "unknown"
+	case FetchColdMiss:
+		return "cold miss"
+	case FetchStaleRefresh:
+		return "stale refresh"
+	case FetchForceRefresh:
+		return "force refresh"
+	case FetchWarmUp:
+		return "warm up"
+	default:
+		return fmt.Sprintf("FetchReason(%d)", int(r))
+	}
+}
+
+type fetchReasonKey struct{}
+
+// triggerReasonKey marks the context passed to Load by the loader itself, e.g. by Warm.
+// It's separate from fetchReasonKey, so the reason of a fetch doesn't leak into the loaders it calls.
+type triggerReasonKey struct{}
+
+// FetchReasonFromContext returns why the fetch with ctx happened, so fetchers can log and prioritize accordingly
+func FetchReasonFromContext(ctx context.Context) FetchReason {
+	reason, _ := ctx.Value(fetchReasonKey{}).(FetchReason)
+	return reason
+}
+
+// withTriggerReason overrides the reason of the fetches triggered with ctx
+func withTriggerReason(ctx context.Context, reason FetchReason) context.Context {
+	return context.WithValue(ctx, triggerReasonKey{}, reason)
+}
+
+// fetchReasonContext annotates ctx with reason, unless trigger overrides it
+func fetchReasonContext(ctx, trigger context.Context, reason FetchReason) context.Context {
+	if r, ok := trigger.Value(triggerReasonKey{}).(FetchReason); ok {
+		reason = r
+	}
+	return context.WithValue(ctx, fetchReasonKey{}, reason)
+}
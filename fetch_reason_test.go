@@ -0,0 +1,57 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchReason(t *testing.T) {
+	reasons := make(chan FetchReason, 4)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		reasons <- FetchReasonFromContext(ctx)
+		return key, nil
+	}
+	l := New(fetch, 20*time.Millisecond)
+	defer l.Close()
+
+	l.Load("a")
+	assert.Equal(t, FetchColdMiss, <-reasons)
+
+	time.Sleep(30 * time.Millisecond)
+	l.Load("a")
+	assert.Equal(t, FetchStaleRefresh, <-reasons)
+
+	assert.NoError(t, l.Warm(context.Background(), "b"))
+	assert.Equal(t, FetchWarmUp, <-reasons)
+
+	periodic := New(func(ctx context.Context, key string) (string, error) {
+		// the periodic refreshes keep coming, don't block them once the test is done
+		select {
+		case reasons <- FetchReasonFromContext(ctx):
+		default:
+		}
+		return key, nil
+	}, time.Hour, WithPeriodicRefresh(5*time.Millisecond, 1))
+	defer periodic.Close()
+	periodic.Load("c")
+	assert.Equal(t, FetchColdMiss, <-reasons)
+	assert.Equal(t, FetchForceRefresh, <-reasons)
+
+	assert.Equal(t, FetchUnknown, FetchReasonFromContext(context.Background()))
+	assert.Equal(t, "stale refresh", FetchStaleRefresh.String())
+}
+
+func TestFetchReasonChained(t *testing.T) {
+	reasons := make(chan FetchReason, 2)
+	inner := New(func(ctx context.Context, key string) (string, error) {
+		reasons <- FetchReasonFromContext(ctx)
+		return key, nil
+	}, time.Minute)
+	outer := New(AsFetcher(inner), time.Minute)
+
+	assert.NoError(t, outer.Warm(context.Background(), "a"))
+	assert.Equal(t, FetchColdMiss, <-reasons, "warm up of the outer loader is a cold miss of the inner one")
+}
@@ -0,0 +1,201 @@
+// Package filecache caches values parsed from files, like templates or compiled assets, keyed by path.
+// Entries are evicted when their file changes, reported by a Watcher or by polling.
+// It doesn't depend on fsnotify, *fsnotify.Watcher satisfies Watcher, forward its events with:
+//
+//	w, _ := fsnotify.NewWatcher()
+//	templates := filecache.New(parseTemplate, time.Hour, filecache.WithWatcher(w))
+//	go func() {
+//		for event := range w.Events {
+//			templates.Changed(event.Name)
+//		}
+//	}()
+package filecache
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// Parser turns the content of the file at path into the cached value, e.g. a parsed template
+type Parser[Value any] func(path string, data []byte) (Value, error)
+
+// Watcher starts watching a path after it's loaded, the owner of the watcher calls Cache.Changed when it changes
+type Watcher interface {
+	Add(path string) error
+}
+
+// Option configures Cache
+type Option func(o *options)
+
+type options struct {
+	fsys    fs.FS
+	watcher Watcher
+	poll    time.Duration
+	loader  []loader.Option
+}
+
+// WithFS reads the files from fsys instead of the OS file system
+func WithFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.fsys = fsys
+	}
+}
+
+// WithWatcher adds every loaded path to w, again after every parse so the watch survives files replaced by rename
+func WithWatcher(w Watcher) Option {
+	return func(o *options) {
+		o.watcher = w
+	}
+}
+
+// WithPolling checks the modification time and size of the loaded files every interval,
+// for file systems without change notifications
+func WithPolling(interval time.Duration) Option {
+	return func(o *options) {
+		o.poll = interval
+	}
+}
+
+// WithLoaderOptions passes options to the loader of the cache
+func WithLoaderOptions(loaderOptions ...loader.Option) Option {
+	return func(o *options) {
+		o.loader = append(o.loader, loaderOptions...)
+	}
+}
+
+// Cache caches the values parsed from files by path
+type Cache[Value any] struct {
+	options
+	l *loader.Loader[string, Value]
+
+	mutex   sync.Mutex
+	watched map[string]fs.FileInfo
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates Cache that parses files with parse. Entries expire after ttl like loader.New,
+// which bounds how long a file is served stale if its change is missed.
+func New[Value any](parse Parser[Value], ttl time.Duration, opts ...Option) *Cache[Value] {
+	c := &Cache[Value]{watched: map[string]fs.FileInfo{}}
+	for _, o := range opts {
+		o(&c.options)
+	}
+	c.l = loader.New(func(ctx context.Context, path string) (Value, error) {
+		// stat before reading, so a write that races with the read is seen by the next poll
+		info, _ := c.stat(path)
+		data, err := c.readFile(path)
+		if err != nil {
+			var zero Value
+			return zero, err
+		}
+		value, err := parse(path, data)
+		if err == nil {
+			c.watch(path, info)
+		}
+		return value, err
+	}, ttl, c.options.loader...)
+
+	if c.poll > 0 {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.pollChanges()
+	}
+	return c
+}
+
+// Load returns the value parsed from the file at path
+func (c *Cache[Value]) Load(ctx context.Context, path string) (Value, error) {
+	return c.l.LoadCtx(ctx, path)
+}
+
+// Changed evicts the value of path, the next Load parses the file again
+func (c *Cache[Value]) Changed(path string) {
+	c.l.Invalidate(path)
+}
+
+// Loader returns the loader of the cache, to inspect or invalidate its entries
+func (c *Cache[Value]) Loader() *loader.Loader[string, Value] {
+	return c.l
+}
+
+// Close stops polling and closes the loader
+func (c *Cache[Value]) Close() error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+	return c.l.Close()
+}
+
+// watch records info of path that was read, it's called after every successful parse.
+// The path is added to the watcher every time, since editors that save by renaming a new file
+// replace the watched inode and fsnotify drops its watch.
+func (c *Cache[Value]) watch(path string, info fs.FileInfo) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.watcher != nil {
+		c.watcher.Add(path)
+	}
+	c.watched[path] = info
+}
+
+func (c *Cache[Value]) pollChanges() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			for _, path := range c.changedPaths() {
+				c.Changed(path)
+			}
+		}
+	}
+}
+
+// changedPaths returns the watched paths whose modification time or size changed since they were parsed
+func (c *Cache[Value]) changedPaths() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var changed []string
+	for path, last := range c.watched {
+		info, err := c.stat(path)
+		if sameFile(last, info, err) {
+			continue
+		}
+		changed = append(changed, path)
+		c.watched[path] = info
+	}
+	return changed
+}
+
+func sameFile(last, info fs.FileInfo, err error) bool {
+	if err != nil || last == nil {
+		return err != nil && last == nil
+	}
+	return info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()
+}
+
+func (c *Cache[Value]) readFile(path string) ([]byte, error) {
+	if c.fsys != nil {
+		return fs.ReadFile(c.fsys, path)
+	}
+	return os.ReadFile(path)
+}
+
+func (c *Cache[Value]) stat(path string) (fs.FileInfo, error) {
+	if c.fsys != nil {
+		return fs.Stat(c.fsys, path)
+	}
+	return os.Stat(path)
+}
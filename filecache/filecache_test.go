@@ -0,0 +1,105 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func upper(path string, data []byte) (string, error) {
+	return strings.ToUpper(string(data)), nil
+}
+
+type recordingWatcher struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (w *recordingWatcher) Add(path string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.paths = append(w.paths, path)
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	fsys := fstest.MapFS{"page.tmpl": {Data: []byte("hello")}}
+	w := &recordingWatcher{}
+	c := New(upper, time.Hour, WithFS(fsys), WithWatcher(w))
+	defer c.Close()
+	ctx := context.Background()
+
+	val, err := c.Load(ctx, "page.tmpl")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", val)
+
+	fsys["page.tmpl"] = &fstest.MapFile{Data: []byte("bye")}
+	val, _ = c.Load(ctx, "page.tmpl")
+	assert.Equal(t, "HELLO", val, "unchanged path must be served from cache")
+
+	c.Changed("page.tmpl")
+	val, _ = c.Load(ctx, "page.tmpl")
+	assert.Equal(t, "BYE", val)
+	assert.Equal(t, []string{"page.tmpl", "page.tmpl"}, w.paths, "path must be watched again after every parse, the file may be replaced")
+
+	_, err = c.Load(ctx, "missing.tmpl")
+	assert.Error(t, err)
+	assert.Len(t, w.paths, 2, "missing file must not be watched")
+}
+
+func TestPolling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.css")
+	assert.NoError(t, os.WriteFile(path, []byte("a{}"), 0o644))
+	c := New(upper, time.Hour, WithPolling(5*time.Millisecond))
+	defer c.Close()
+	ctx := context.Background()
+
+	val, err := c.Load(ctx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "A{}", val)
+
+	assert.NoError(t, os.WriteFile(path, []byte("b{}"), 0o644))
+	// the content may be written within the modification time resolution of the file system
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, future, future))
+	assert.Eventually(t, func() bool {
+		val, _ := c.Load(ctx, path)
+		return val == "B{}"
+	}, time.Second, time.Millisecond)
+}
+
+// writingFS replaces the file after it's read, like a write that races with the read
+type writingFS struct {
+	fstest.MapFS
+	once sync.Once
+}
+
+func (fsys *writingFS) ReadFile(name string) ([]byte, error) {
+	data, err := fsys.MapFS.ReadFile(name)
+	fsys.once.Do(func() {
+		fsys.MapFS[name] = &fstest.MapFile{Data: []byte("bye"), ModTime: time.Now().Add(time.Minute)}
+	})
+	return data, err
+}
+
+func TestPollingWriteDuringRead(t *testing.T) {
+	fsys := &writingFS{MapFS: fstest.MapFS{"page.tmpl": {Data: []byte("hello")}}}
+	c := New(upper, time.Hour, WithFS(fsys), WithPolling(5*time.Millisecond))
+	defer c.Close()
+	ctx := context.Background()
+
+	val, err := c.Load(ctx, "page.tmpl")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", val)
+	assert.Eventually(t, func() bool {
+		val, _ := c.Load(ctx, "page.tmpl")
+		return val == "BYE"
+	}, time.Second, time.Millisecond, "write during the read must be seen by the next poll")
+}
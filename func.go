@@ -0,0 +1,33 @@
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// Func returns a cached version of fn, for quick scripts that don't want to carry a Loader around.
+// The loader behind it lives as long as the returned function is referenced, use New to Close it or read its Stats.
+func Func[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, options ...Option) Fetcher[Key, Value] {
+	return New(fn, ttl, options...).LoadCtx
+}
+
+// Cache1 memoizes fn of one argument, it's Func for symmetry with Cache2 and Cache3
+func Cache1[A comparable, Value any](fn func(ctx context.Context, a A) (Value, error), ttl time.Duration, options ...Option) func(ctx context.Context, a A) (Value, error) {
+	return Func(fn, ttl, options...)
+}
+
+// Cache2 memoizes fn of two arguments, keyed by Key2 of the arguments
+func Cache2[A, B comparable, Value any](fn func(ctx context.Context, a A, b B) (Value, error), ttl time.Duration, options ...Option) func(ctx context.Context, a A, b B) (Value, error) {
+	cached := Func(Fetcher2(fn), ttl, options...)
+	return func(ctx context.Context, a A, b B) (Value, error) {
+		return cached(ctx, K2(a, b))
+	}
+}
+
+// Cache3 memoizes fn of three arguments, keyed by Key3 of the arguments
+func Cache3[A, B, C comparable, Value any](fn func(ctx context.Context, a A, b B, c C) (Value, error), ttl time.Duration, options ...Option) func(ctx context.Context, a A, b B, c C) (Value, error) {
+	cached := Func(Fetcher3(fn), ttl, options...)
+	return func(ctx context.Context, a A, b B, c C) (Value, error) {
+		return cached(ctx, K3(a, b, c))
+	}
+}
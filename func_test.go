@@ -0,0 +1,53 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunc(t *testing.T) {
+	calls := 0
+	square := Func(func(ctx context.Context, n int) (string, error) {
+		calls++
+		return fmt.Sprint(n * n), nil
+	}, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		val, err := square(context.Background(), 4)
+		assert.NoError(t, err)
+		assert.Equal(t, "16", val)
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestCacheN(t *testing.T) {
+	calls := 0
+	join := Cache3(func(ctx context.Context, a string, b int, c bool) (string, error) {
+		calls++
+		return fmt.Sprint(a, b, c), nil
+	}, time.Minute)
+
+	ctx := context.Background()
+	val, _ := join(ctx, "x", 1, true)
+	assert.Equal(t, "x1 true", val)
+	join(ctx, "x", 1, true)
+	val, _ = join(ctx, "x", 2, true)
+	assert.Equal(t, "x2 true", val)
+	assert.Equal(t, 2, calls, "calls with the same arguments must be cached")
+
+	add := Cache2(func(ctx context.Context, a, b int) (int, error) {
+		return a + b, nil
+	}, time.Minute)
+	sum, _ := add(ctx, 2, 3)
+	assert.Equal(t, 5, sum)
+
+	double := Cache1(func(ctx context.Context, a int) (int, error) {
+		return a * 2, nil
+	}, time.Minute)
+	n, _ := double(ctx, 21)
+	assert.Equal(t, 42, n)
+}
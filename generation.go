@@ -0,0 +1,117 @@
+package loader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// generation is a fully built dataset, it's immutable once it's swapped in
+type generation[Key comparable, Value any] struct {
+	items   map[Key]*cacheItem[Value]
+	builtAt time.Time
+}
+
+// generations rebuilds the dataset of WithGenerations
+type generations[Key comparable, Value any] struct {
+	build    func(ctx context.Context) (map[Key]Value, error)
+	interval time.Duration
+
+	mutex   sync.Mutex
+	lastErr error
+}
+
+// WithGenerations rebuilds the whole dataset with build every interval (or only by Rebuild if it's zero),
+// and atomically swaps it in as a new generation. Keys of the current generation are served from it;
+// other keys are still fetched key by key. It's useful for reference data that is reloaded in full.
+// If a rebuild fails, the previous generation is kept and Healthy returns the error until the next rebuild succeeds.
+func WithGenerations[Key comparable, Value any](build func(ctx context.Context) (map[Key]Value, error), interval time.Duration) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.generations = &generations[Key, Value]{build: build, interval: interval}
+		if interval > 0 && l.startBackground() {
+			go l.rebuildPeriodically()
+		}
+	}
+}
+
+// Rebuild builds a new generation with the function of WithGenerations and swaps it in
+func (l *Loader[Key, Value]) Rebuild(ctx context.Context) error {
+	if l.generations == nil {
+		return nil
+	}
+	values, err := l.generations.build(ctx)
+	l.generations.mutex.Lock()
+	l.generations.lastErr = err
+	l.generations.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	gen := &generation[Key, Value]{items: make(map[Key]*cacheItem[Value], len(values)), builtAt: now}
+	for key, value := range values {
+		key = l.mapKey(key)
+		value = l.transformValue(key, value)
+		// the item is replaced by the next generation instead of being refreshed
+		p := &payload[Value]{value: value, fetchedAt: now, expire: maxTime}
+		if l.mutationCheck {
+			p.hash = hashValue(value)
+		}
+		item := newCacheItem[Value]()
+		item.store(p)
+		gen.items[key] = item
+	}
+	prev := l.generation.Swap(gen)
+	if l.audit != nil {
+		for key := range gen.items {
+			l.record(ctx, AuditSet, key, nil)
+		}
+		if prev != nil {
+			for key := range prev.items {
+				if _, ok := gen.items[key]; !ok {
+					l.record(ctx, AuditInvalidate, key, nil)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Loader[Key, Value]) rebuildPeriodically() {
+	defer l.background.Done()
+	ticker := time.NewTicker(l.generations.interval)
+	defer ticker.Stop()
+
+	ctx := WithAuditReason(l.lifecycle.ctx, "generation rebuild")
+	l.Rebuild(ctx)
+	for {
+		select {
+		case <-l.lifecycle.ctx.Done():
+			return
+		case <-ticker.C:
+			l.Rebuild(ctx)
+		}
+	}
+}
+
+// generationItem returns the item of key in the current generation
+func (l *Loader[Key, Value]) generationItem(key Key) *cacheItem[Value] {
+	gen := l.generation.Load()
+	if gen == nil {
+		return nil
+	}
+	return gen.items[key]
+}
+
+// rebuildError returns the error of the last rebuild
+func (g *generations[Key, Value]) rebuildError() error {
+	if g == nil {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.lastErr
+}
+
+// maxTime is the expiry of items that never expire
+var maxTime = time.Unix(1<<62, 0)
@@ -0,0 +1,137 @@
+// Package grpccache caches the responses of idempotent unary gRPC methods with loader.
+// It doesn't depend on grpc, install it as a server interceptor with:
+//
+//	grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+//		return cache.Intercept(ctx, info.FullMethod, req, handler)
+//	})
+package grpccache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// ErrNoCall is returned by the loaders of Cache for fetches that aren't started by Intercept,
+// like a Load of the loader returned by Cache.Loader, since there's no handler to call
+var ErrNoCall = errors.New("grpccache: fetch without a call of Intercept")
+
+// KeyFunc returns the cache key of a request, equal requests must have equal keys.
+// It only sees the request message, the incoming metadata like the credentials of the caller isn't part of the key.
+type KeyFunc func(req interface{}) (string, error)
+
+// Option configures Cache
+type Option func(c *Cache)
+
+// WithRequestKey sets the KeyFunc, the default is the hash of the JSON encoding of the request,
+// which works with generated messages
+func WithRequestKey(key KeyFunc) Option {
+	return func(c *Cache) {
+		c.key = key
+	}
+}
+
+// WithLoaderOptions passes options to the loader of every method
+func WithLoaderOptions(options ...loader.Option) Option {
+	return func(c *Cache) {
+		c.options = append(c.options, options...)
+	}
+}
+
+// Cache caches the responses of the configured methods, each method has its own Loader
+type Cache struct {
+	key     KeyFunc
+	options []loader.Option
+	loaders map[string]*loader.Loader[string, interface{}]
+}
+
+// New creates Cache for the methods, mapping their full names like "/pkg.Service/Get" to the TTLs of their responses.
+// The other methods aren't cached, and errors are never cached.
+// A cached response is served to every caller of an equal request regardless of its metadata,
+// so don't cache methods whose responses depend on the caller, like on the authenticated user.
+func New(methods map[string]time.Duration, options ...Option) *Cache {
+	c := &Cache{
+		key:     jsonKey,
+		options: []loader.Option{loader.WithNoErrorCaching(), loader.WithContextValues(callKey{})},
+		loaders: make(map[string]*loader.Loader[string, interface{}], len(methods)),
+	}
+	for _, o := range options {
+		o(c)
+	}
+	for method, ttl := range methods {
+		c.loaders[method] = loader.New(fetch, ttl, c.options...)
+	}
+	return c
+}
+
+// Intercept returns the cached response of req, calling handler on miss and refresh.
+// The response is shared by the callers, they must not modify it.
+func (c *Cache) Intercept(ctx context.Context, method string, req interface{}, handler func(ctx context.Context, req interface{}) (interface{}, error)) (interface{}, error) {
+	l, ok := c.loaders[method]
+	if !ok {
+		return handler(ctx, req)
+	}
+	key, err := c.key(req)
+	if err != nil {
+		return handler(ctx, req)
+	}
+	return l.LoadCtx(context.WithValue(ctx, callKey{}, &call{ctx: ctx, req: req, handler: handler}), key)
+}
+
+// Loader returns the loader of method, to invalidate or inspect its responses
+func (c *Cache) Loader(method string) (*loader.Loader[string, interface{}], bool) {
+	l, ok := c.loaders[method]
+	return l, ok
+}
+
+// Close closes the loaders of every method
+func (c *Cache) Close() error {
+	for _, l := range c.loaders {
+		l.Close()
+	}
+	return nil
+}
+
+type callKey struct{}
+
+// call is the request that triggers the fetch
+type call struct {
+	ctx     context.Context
+	req     interface{}
+	handler func(ctx context.Context, req interface{}) (interface{}, error)
+}
+
+func fetch(ctx context.Context, key string) (interface{}, error) {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return nil, ErrNoCall
+	}
+	return c.handler(callContext{Context: ctx, values: c.ctx}, c.req)
+}
+
+// callContext has the cancellation of the fetch context, and the values of the request like its incoming metadata
+type callContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c callContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+func jsonKey(req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
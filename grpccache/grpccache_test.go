@@ -0,0 +1,61 @@
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type getRequest struct {
+	Id string `json:"id,omitempty"`
+}
+
+type tokenKey struct{}
+
+func TestIntercept(t *testing.T) {
+	calls := map[string]int{}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id := req.(*getRequest).Id
+		calls[id]++
+		if id == "missing" {
+			return nil, errors.New("not found")
+		}
+		return "user " + id + " for " + ctx.Value(tokenKey{}).(string), nil
+	}
+	c := New(map[string]time.Duration{"/users.Users/Get": time.Minute})
+	defer c.Close()
+
+	ctx := context.WithValue(context.Background(), tokenKey{}, "token")
+	for i := 0; i < 2; i++ {
+		res, err := c.Intercept(ctx, "/users.Users/Get", &getRequest{Id: "a"}, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, "user a for token", res, "handler must see the values of the request context")
+	}
+	assert.Equal(t, 1, calls["a"], "equal requests must be served from cache")
+
+	c.Intercept(ctx, "/users.Users/Get", &getRequest{Id: "missing"}, handler)
+	c.Intercept(ctx, "/users.Users/Get", &getRequest{Id: "missing"}, handler)
+	assert.Equal(t, 2, calls["missing"], "errors must not be cached")
+
+	c.Intercept(ctx, "/users.Users/Update", &getRequest{Id: "b"}, handler)
+	c.Intercept(ctx, "/users.Users/Update", &getRequest{Id: "b"}, handler)
+	assert.Equal(t, 2, calls["b"], "other methods must not be cached")
+
+	l, _ := c.Loader("/users.Users/Get")
+	l.InvalidateAll()
+	c.Intercept(ctx, "/users.Users/Get", &getRequest{Id: "a"}, handler)
+	assert.Equal(t, 2, calls["a"])
+}
+
+func TestLoadWithoutIntercept(t *testing.T) {
+	c := New(map[string]time.Duration{"/users.Users/Get": time.Minute})
+	defer c.Close()
+
+	l, ok := c.Loader("/users.Users/Get")
+	assert.True(t, ok)
+	_, err := l.Load("a")
+	assert.ErrorIs(t, err, ErrNoCall, "fetch without Intercept must fail instead of panicking")
+}
@@ -0,0 +1,69 @@
+package loader
+
+import "sync"
+
+// hashMapShards is the shard count of hashMap, a power of two so the shard is picked with a mask
+const hashMapShards = 32
+
+// hashMap is a map split into shards selected by key hash, each with its own lock.
+// Its zero value is ready to use.
+type hashMap[K comparable, V any] struct {
+	once   sync.Once
+	hash   func(K) uint64
+	shards [hashMapShards]hashMapShard[K, V]
+}
+
+type hashMapShard[K comparable, V any] struct {
+	mutex sync.Mutex
+	items map[K]V
+}
+
+// update calls fn with the current value of key while holding its shard lock,
+// the returned value is stored unless keep is false, then the key is deleted
+func (m *hashMap[K, V]) update(key K, fn func(value V, ok bool) (newValue V, keep bool)) V {
+	return m.updateHashed(key, m.hashOf(key), fn)
+}
+
+// updateHashed works like update with the hash of key computed by the caller, it must equal hashKey(key)
+func (m *hashMap[K, V]) updateHashed(key K, hash uint64, fn func(value V, ok bool) (newValue V, keep bool)) V {
+	s := &m.shards[hash&(hashMapShards-1)]
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	value, ok := s.items[key]
+	value, keep := fn(value, ok)
+	if !keep {
+		delete(s.items, key)
+		return value
+	}
+	if s.items == nil {
+		s.items = map[K]V{}
+	}
+	s.items[key] = value
+	return value
+}
+
+func (m *hashMap[K, V]) hashOf(key K) uint64 {
+	m.once.Do(func() {
+		m.hash = keyHasher[K]()
+	})
+	return m.hash(key)
+}
+
+// keyHasher picks the hash function of K once, so the common key types are hashed
+// without going through the type switch of hashKey on every call
+func keyHasher[K comparable]() func(K) uint64 {
+	var zero K
+	switch interface{}(zero).(type) {
+	case string:
+		return func(key K) uint64 { return hashString(interface{}(key).(string)) }
+	case int:
+		return func(key K) uint64 { return mix64(uint64(interface{}(key).(int))) }
+	case int64:
+		return func(key K) uint64 { return mix64(uint64(interface{}(key).(int64))) }
+	case uint64:
+		return func(key K) uint64 { return mix64(interface{}(key).(uint64)) }
+	default:
+		return func(key K) uint64 { return hashKey(key) }
+	}
+}
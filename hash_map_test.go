@@ -0,0 +1,33 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashMap(t *testing.T) {
+	var m hashMap[string, int]
+	inc := func(value int, ok bool) (int, bool) { return value + 1, true }
+
+	assert.Equal(t, 1, m.update("a", inc))
+	assert.Equal(t, 2, m.update("a", inc))
+	assert.Equal(t, 1, m.update("b", inc))
+
+	m.update("a", func(value int, ok bool) (int, bool) {
+		assert.True(t, ok)
+		return value, false
+	})
+	m.update("a", func(value int, ok bool) (int, bool) {
+		assert.False(t, ok, "deleted key should be gone")
+		return 0, false
+	})
+}
+
+func TestKeyHasher(t *testing.T) {
+	type userID string
+	assert.Equal(t, hashKey("x"), keyHasher[string]()("x"))
+	assert.Equal(t, hashKey(42), keyHasher[int]()(42))
+	assert.Equal(t, hashKey(userID("x")), keyHasher[userID]()("x"))
+	assert.NotEqual(t, keyHasher[int]()(1), keyHasher[int]()(2))
+}
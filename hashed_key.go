@@ -0,0 +1,105 @@
+package loader
+
+import "context"
+
+// HashedDriver is optionally implemented by CacheDriver that shards keys by HashKey.
+// The loader hashes each key once per load and passes the hash to both the driver and the key locker.
+type HashedDriver interface {
+	// AddHashed works like Add, hash is HashKey(key)
+	AddHashed(key interface{}, hash uint64, value interface{})
+	// GetHashed works like Get, hash is HashKey(key)
+	GetHashed(key interface{}, hash uint64) (interface{}, bool)
+}
+
+// HashedKeyLocker is optionally implemented by KeyLocker that shards keys by HashKey
+type HashedKeyLocker[Key comparable] interface {
+	// LockCtxHashed works like LockCtx, hash is HashKey(key)
+	LockCtxHashed(ctx context.Context, key Key, hash uint64) (unlock func(), err error)
+}
+
+// hashedKey is the driver key of a load with its hash, the hash is only computed if the driver or the locker uses it
+type hashedKey struct {
+	dk     interface{}
+	hash   uint64
+	hashed bool
+}
+
+func (l *Loader[Key, Value]) hashedKey(key Key) hashedKey {
+	dk := l.driverKey(key)
+	if l.hashedDriver == nil && l.hashedLock == nil {
+		return hashedKey{dk: dk}
+	}
+	return hashedKey{dk: dk, hash: hashKey(dk), hashed: true}
+}
+
+func (l *Loader[Key, Value]) driverGetHashed(hk hashedKey) (interface{}, bool) {
+	if hk.hashed && l.hashedDriver != nil {
+		return l.hashedDriver.GetHashed(hk.dk, hk.hash)
+	}
+	return l.driverGet(hk.dk)
+}
+
+// driverPeek looks the key up again after a miss, without counting another access for drivers that implement peeker
+func (l *Loader[Key, Value]) driverPeek(hk hashedKey) (interface{}, bool) {
+	if p, ok := l.driver.(peeker); ok {
+		return p.peek(hk.dk)
+	}
+	return l.driverGetHashed(hk)
+}
+
+func (l *Loader[Key, Value]) driverAddHashed(hk hashedKey, value interface{}) {
+	if hk.hashed && l.hashedDriver != nil {
+		l.hashedDriver.AddHashed(hk.dk, hk.hash, value)
+		return
+	}
+	l.driver.Add(hk.dk, value)
+}
+
+// heldKeyLock is the key lock taken by claim, it skips the unlock closure of the default InMemoryKeyLocker
+type heldKeyLock[Key comparable] struct {
+	mem  *InMemoryKeyLocker[Key]
+	key  Key
+	hash uint64
+	item *inMemoryKeyLockerItem
+	fn   func()
+}
+
+func (h heldKeyLock[Key]) unlock() {
+	if h.item != nil {
+		h.mem.unlockItem(h.key, h.hash, h.item)
+		return
+	}
+	h.fn()
+}
+
+func (l *Loader[Key, Value]) lockKey(ctx context.Context, key Key, hk hashedKey) (heldKeyLock[Key], error) {
+	if l.memLock != nil {
+		hash := hk.hash
+		if !hk.hashed || l.hashedLock == nil {
+			hash = l.memLock.locks.hashOf(key)
+		}
+		item, err := l.memLock.lockItem(ctx, key, hash)
+		return heldKeyLock[Key]{mem: l.memLock, key: key, hash: hash, item: item}, err
+	}
+
+	var fn func()
+	var err error
+	if hk.hashed && l.hashedLock != nil {
+		fn, err = l.hashedLock.LockCtxHashed(ctx, key, hk.hash)
+	} else {
+		fn, err = l.lock.LockCtx(ctx, key)
+	}
+	return heldKeyLock[Key]{fn: fn}, err
+}
+
+// setupHashedKey enables the hash reuse when the driver and the locker support it.
+// The locker only gets the hash when it's the hash of key itself, not of a namespaced driver key.
+func (l *Loader[Key, Value]) setupHashedKey() {
+	if hd, ok := l.driver.(HashedDriver); ok && l.driverTimeout <= 0 {
+		l.hashedDriver = hd
+	}
+	if hl, ok := l.lock.(HashedKeyLocker[Key]); ok && l.namespace == "" {
+		l.hashedLock = hl
+	}
+	l.memLock, _ = l.lock.(*InMemoryKeyLocker[Key])
+}
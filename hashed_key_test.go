@@ -0,0 +1,66 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hashCheckingDriver struct {
+	t *testing.T
+	*inMemoryCache
+	hashed int
+}
+
+func (d *hashCheckingDriver) AddHashed(key interface{}, hash uint64, value interface{}) {
+	assert.Equal(d.t, HashKey(key), hash)
+	d.hashed++
+	d.inMemoryCache.AddHashed(key, hash, value)
+}
+
+func (d *hashCheckingDriver) GetHashed(key interface{}, hash uint64) (interface{}, bool) {
+	assert.Equal(d.t, HashKey(key), hash)
+	d.hashed++
+	return d.inMemoryCache.GetHashed(key, hash)
+}
+
+type hashCheckingLocker struct {
+	t *testing.T
+	InMemoryKeyLocker[string]
+	hashed int
+}
+
+func (l *hashCheckingLocker) LockCtxHashed(ctx context.Context, key string, hash uint64) (func(), error) {
+	assert.Equal(l.t, HashKey(key), hash)
+	l.hashed++
+	return l.InMemoryKeyLocker.LockCtxHashed(ctx, key, hash)
+}
+
+func TestHashedKey(t *testing.T) {
+	driver := &hashCheckingDriver{t: t, inMemoryCache: newInMemoryCache()}
+	locker := &hashCheckingLocker{t: t}
+	l := New(identity[string], time.Minute, WithDriver(driver), WithKeyLocker[string](locker))
+
+	value, err := l.Load("some-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "some-key", value)
+	// miss: get on fast path, get after lock, add of the pending item
+	assert.Equal(t, 3, driver.hashed)
+	assert.Equal(t, 1, locker.hashed)
+
+	_, err = l.Load("some-key")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, driver.hashed)
+
+	t.Run("namespaced key skips the locker hash", func(t *testing.T) {
+		locker := &hashCheckingLocker{t: t}
+		l := New(identity[string], time.Minute, WithDriver(driver), WithKeyLocker[string](locker), WithNamespace("ns"))
+		_, err := l.Load("other-key")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, locker.hashed)
+		_, ok := driver.Get(namespacedKey{namespace: "ns", key: "other-key"})
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Pinger is implemented by cache drivers and RemoteStore that can check whether they're reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ErrUnhealthy is wrapped by the errors of Loader.Healthy
+var ErrUnhealthy = errors.New("loader: unhealthy")
+
+// WithRefreshErrorThreshold makes Loader.Healthy fail when more than maxRate (between 0 and 1)
+// of the last window background refreshes returned error.
+func WithRefreshErrorThreshold(maxRate float64, window int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.maxRefreshErrorRate = maxRate
+		cfg.refreshErrorWindow = window
+	})
+}
+
+// refreshHealth keeps the results of the last background refreshes in a ring
+type refreshHealth struct {
+	mutex    sync.Mutex
+	failed   []bool
+	next     int
+	count    int
+	failures int
+}
+
+func newRefreshHealth(window int) *refreshHealth {
+	return &refreshHealth{failed: make([]bool, window)}
+}
+
+func (h *refreshHealth) record(failed bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.count == len(h.failed) {
+		if h.failed[h.next] {
+			h.failures--
+		}
+	} else {
+		h.count++
+	}
+	h.failed[h.next] = failed
+	if failed {
+		h.failures++
+	}
+	h.next = (h.next + 1) % len(h.failed)
+}
+
+func (h *refreshHealth) errorRate() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	return float64(h.failures) / float64(h.count)
+}
+
+// Healthy returns error wrapping ErrUnhealthy if the driver implements Pinger and it's not reachable,
+// if the last rebuild of WithGenerations failed, or if the rate of failed background refreshes exceeds WithRefreshErrorThreshold.
+// It's meant to be used by readiness probes.
+func (l *Loader[Key, Value]) Healthy(ctx context.Context) error {
+	if p, ok := l.driver.(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("%w: driver: %v", ErrUnhealthy, err)
+		}
+	}
+	if err := l.generations.rebuildError(); err != nil {
+		return fmt.Errorf("%w: rebuild: %v", ErrUnhealthy, err)
+	}
+	if l.health != nil {
+		if rate := l.health.errorRate(); rate > l.maxRefreshErrorRate {
+			return fmt.Errorf("%w: %.0f%% of background refreshes failed", ErrUnhealthy, rate*100)
+		}
+	}
+	return nil
+}
+
+// HealthChecker is implemented by Loader and Registry
+type HealthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// HealthHandler serves the result of checker.Healthy for readiness probes.
+// It responds 200 when healthy, or 503 with the error otherwise.
+func HealthHandler(checker HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checker.Healthy(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
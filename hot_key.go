@@ -0,0 +1,57 @@
+package loader
+
+import (
+	"sync"
+	"time"
+)
+
+// hotKeyShards is the shard count of hotKeyDetector, a power of two so the shard is picked with a mask
+const hotKeyShards = 32
+
+// hotKeyDetector counts requests per key in fixed windows
+// and calls the callback once per window when a key reaches the threshold.
+// The counts are split into shards by key hash, so loads of different keys rarely contend for a lock.
+type hotKeyDetector[Key comparable] struct {
+	threshold int
+	window    time.Duration
+	callback  func(key Key)
+
+	hash   func(Key) uint64
+	shards [hotKeyShards]hotKeyShard[Key]
+}
+
+// hotKeyShard counts the keys of one shard, its window starts with the first request after the previous one ends
+type hotKeyShard[Key comparable] struct {
+	mutex       sync.Mutex
+	windowStart time.Time
+	counts      map[Key]int
+}
+
+// WithHotKeyDetector calls callback when a key is requested at least threshold times within window.
+// The callback is called at most once per key per window, from the goroutine calling Load.
+func WithHotKeyDetector[Key comparable](threshold int, window time.Duration, callback func(key Key)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.hotKey = &hotKeyDetector[Key]{
+			threshold: threshold,
+			window:    window,
+			callback:  callback,
+			hash:      keyHasher[Key](),
+		}
+	})
+}
+
+func (d *hotKeyDetector[Key]) record(key Key, now time.Time) {
+	s := &d.shards[d.hash(key)&(hotKeyShards-1)]
+	s.mutex.Lock()
+	if s.counts == nil || now.Sub(s.windowStart) >= d.window {
+		s.windowStart = now
+		s.counts = map[Key]int{}
+	}
+	s.counts[key]++
+	hot := s.counts[key] == d.threshold
+	s.mutex.Unlock()
+
+	if hot {
+		d.callback(key)
+	}
+}
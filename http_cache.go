@@ -0,0 +1,253 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPResponse is a whole HTTP response cached by HTTPMiddleware
+type HTTPResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// ttl and staleWhileRevalidate are computed by the HTTPTTLFunc of the middleware that fetched the response
+	ttl                  time.Duration
+	staleWhileRevalidate time.Duration
+}
+
+// HTTPKeyFunc returns the cache key of a GET request, the request isn't cached if it returns ""
+type HTTPKeyFunc func(r *http.Request) string
+
+// HTTPTTLFunc returns how long resp is fresh, and how long after that it's served stale while it's refreshed in background.
+// resp isn't cached if ttl <= 0.
+type HTTPTTLFunc func(resp *HTTPResponse) (ttl, staleWhileRevalidate time.Duration)
+
+// NewHTTPLoader creates the loader of HTTPMiddleware. The TTLs come from the HTTPTTLFunc of the middleware,
+// responses that aren't cacheable are never stored nor shared with concurrent requests of the same key.
+func NewHTTPLoader(options ...Option) *Loader[string, *HTTPResponse] {
+	options = append([]Option{
+		WithNoErrorCaching(),
+		WithContextValues(httpCallKey{}),
+		WithTTLFunc(func(key string, resp *HTTPResponse, err error) time.Duration {
+			if err != nil {
+				return 0
+			}
+			return resp.ttl
+		}),
+	}, options...)
+	return New(fetchHTTP, 0, options...)
+}
+
+// HTTPMiddleware caches the whole responses (status, headers and body) of GET requests in l, which is created by NewHTTPLoader.
+// keyFn defaults to the request URL, and ttlFn to CacheControlTTL.
+// When the response is expired, it's served stale and refreshed in background for its stale-while-revalidate period,
+// after that the request waits for the fresh response.
+func HTTPMiddleware(l *Loader[string, *HTTPResponse], keyFn HTTPKeyFunc, ttlFn HTTPTTLFunc) func(next http.Handler) http.Handler {
+	if keyFn == nil {
+		keyFn = func(r *http.Request) string { return r.URL.String() }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ""
+			if r.Method == http.MethodGet {
+				key = keyFn(r)
+			}
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			resp, info, err := LoadHTTPResponse(r.Context(), l, key, ttlFn, func(ctx context.Context) *HTTPResponse {
+				rec := &responseRecorder{resp: &HTTPResponse{StatusCode: http.StatusOK, Header: http.Header{}}}
+				next.ServeHTTP(rec, r.WithContext(requestContext{Context: ctx, values: r.Context()}))
+				return rec.resp
+			})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			resp.write(w, info)
+		})
+	}
+}
+
+// LoadHTTPResponse returns the response of key from l like HTTPMiddleware, for servers that don't use net/http.
+// render produces the response on miss and refresh, its ctx is cancelled when the fetch is.
+// Refreshes run after LoadHTTPResponse returns, so render must not use request state that the server recycles,
+// e.g. with fasthttp, copy the request first:
+//
+//	func cached(l *loader.Loader[string, *loader.HTTPResponse], next fasthttp.RequestHandler) fasthttp.RequestHandler {
+//		return func(ctx *fasthttp.RequestCtx) {
+//			if !ctx.IsGet() {
+//				next(ctx)
+//				return
+//			}
+//			var req fasthttp.Request
+//			ctx.Request.CopyTo(&req)
+//			resp, _, err := loader.LoadHTTPResponse(ctx, l, string(ctx.RequestURI()), nil, func(context.Context) *loader.HTTPResponse {
+//				var rc fasthttp.RequestCtx
+//				rc.Init(&req, nil, nil)
+//				next(&rc)
+//				header := http.Header{}
+//				rc.Response.Header.VisitAll(func(k, v []byte) { header.Add(string(k), string(v)) })
+//				return &loader.HTTPResponse{StatusCode: rc.Response.StatusCode(), Header: header, Body: rc.Response.Body()}
+//			})
+//			if err != nil {
+//				ctx.Error(err.Error(), fasthttp.StatusBadGateway)
+//				return
+//			}
+//			for name, values := range resp.Header {
+//				for _, v := range values {
+//					ctx.Response.Header.Add(name, v)
+//				}
+//			}
+//			ctx.SetStatusCode(resp.StatusCode)
+//			ctx.SetBody(resp.Body)
+//		}
+//	}
+//
+// ttlFn defaults to CacheControlTTL. Uncacheable responses are returned with zero Info.
+func LoadHTTPResponse(ctx context.Context, l *Loader[string, *HTTPResponse], key string, ttlFn HTTPTTLFunc, render func(ctx context.Context) *HTTPResponse) (*HTTPResponse, Info, error) {
+	if ttlFn == nil {
+		ttlFn = CacheControlTTL
+	}
+	call := &httpCall{render: render, ttlFn: ttlFn}
+	callCtx := context.WithValue(ctx, httpCallKey{}, call)
+	resp, info, err := l.LoadWithInfoCtx(callCtx, key)
+	if err == nil && info.Stale && time.Now().After(info.Expire.Add(resp.staleWhileRevalidate)) {
+		// past stale-while-revalidate, wait for the fresh response
+		l.Invalidate(key)
+		resp, info, err = l.LoadWithInfoCtx(callCtx, key)
+	}
+
+	var uncacheable *uncacheableResponse
+	if errors.As(err, &uncacheable) {
+		if uncacheable.call == call {
+			return uncacheable.resp, Info{}, nil
+		}
+		// the response was rendered for the request that this one waited for, e.g. with its cookies
+		return render(ctx), Info{}, nil
+	}
+	return resp, info, err
+}
+
+// CacheControlTTL is the default HTTPTTLFunc, it reads s-maxage or max-age and stale-while-revalidate of the Cache-Control header.
+// Only 200 responses without no-store, no-cache or private are cached.
+// Responses with Vary aren't cached either, because the default HTTPKeyFunc ignores the request headers;
+// to cache them, use HTTPKeyFunc that includes the varying headers in the key and a custom HTTPTTLFunc.
+// Responses with Set-Cookie are never cached, whatever the HTTPTTLFunc returns.
+func CacheControlTTL(resp *HTTPResponse) (ttl, staleWhileRevalidate time.Duration) {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Vary") != "" {
+		return 0, 0
+	}
+	maxAge, sMaxAge := -1, -1
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.Atoi(value)
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0, 0
+		case "max-age":
+			if err == nil {
+				maxAge = seconds
+			}
+		case "s-maxage":
+			if err == nil {
+				sMaxAge = seconds
+			}
+		case "stale-while-revalidate":
+			if err == nil {
+				staleWhileRevalidate = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge <= 0 {
+		return 0, 0
+	}
+	return time.Duration(maxAge) * time.Second, staleWhileRevalidate
+}
+
+func (resp *HTTPResponse) write(w http.ResponseWriter, info Info) {
+	for name, values := range resp.Header {
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	if info.Cached {
+		w.Header().Set("Age", strconv.Itoa(int(time.Since(info.FetchedAt)/time.Second)))
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+type httpCallKey struct{}
+
+// httpCall is the request that triggers the fetch
+type httpCall struct {
+	render func(ctx context.Context) *HTTPResponse
+	ttlFn  HTTPTTLFunc
+}
+
+// uncacheableResponse is returned by fetchHTTP so the response reaches its caller without being cached,
+// call is the request that rendered it, the other requests waiting for the same key render their own
+type uncacheableResponse struct {
+	resp *HTTPResponse
+	call *httpCall
+}
+
+func (e *uncacheableResponse) Error() string {
+	return "loader: uncacheable response with status " + strconv.Itoa(e.resp.StatusCode)
+}
+
+func fetchHTTP(ctx context.Context, key string) (*HTTPResponse, error) {
+	call := ctx.Value(httpCallKey{}).(*httpCall)
+	resp := call.render(ctx)
+	resp.ttl, resp.staleWhileRevalidate = call.ttlFn(resp)
+	if resp.ttl <= 0 || len(resp.Header.Values("Set-Cookie")) > 0 {
+		return nil, &uncacheableResponse{resp: resp, call: call}
+	}
+	return resp, nil
+}
+
+// requestContext has the cancellation of the fetch context, and the values of the request that triggered it
+type requestContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c requestContext) Value(key interface{}) interface{} {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// responseRecorder buffers the response of the handler
+type responseRecorder struct {
+	resp        *HTTPResponse
+	wroteHeader bool
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.resp.Header
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.wroteHeader {
+		return
+	}
+	r.resp.StatusCode = statusCode
+	r.wroteHeader = true
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.resp.Body = append(r.resp.Body, data...)
+	return len(data), nil
+}
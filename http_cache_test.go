@@ -0,0 +1,168 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", r.URL.Query().Get("cc"))
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.WriteHeader(status)
+		fmt.Fprint(w, "response ", n)
+	})
+	l := NewHTTPLoader()
+	defer l.Close()
+	server := httptest.NewServer(HTTPMiddleware(l, nil, nil)(handler))
+	defer server.Close()
+
+	get := func(query string) (*http.Response, string) {
+		resp, err := http.Get(server.URL + "/?" + query)
+		if !assert.NoError(t, err) {
+			return nil, ""
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp, string(body)
+	}
+
+	resp, body := get("status=202&cc=max-age=60")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "response 1", body)
+	_, body = get("status=202&cc=max-age=60")
+	assert.Equal(t, "response 2", body, "non-200 response must not be cached")
+
+	resp, body = get("status=200&cc=max-age=60")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "response 3", body)
+	resp, body = get("status=200&cc=max-age=60")
+	assert.Equal(t, "response 3", body, "cacheable response must be served from cache")
+	assert.Equal(t, "max-age=60", resp.Header.Get("Cache-Control"))
+	assert.NotEmpty(t, resp.Header.Get("Age"))
+
+	_, body = get("status=200&cc=no-store")
+	assert.Equal(t, "response 4", body)
+	_, body = get("status=200&cc=no-store")
+	assert.Equal(t, "response 5", body)
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/?status=200&cc=max-age=60", nil)
+	post, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	post.Body.Close()
+	assert.Equal(t, int32(6), atomic.LoadInt32(&calls), "POST must not be served from cache")
+}
+
+func TestHTTPMiddlewareStaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, atomic.AddInt32(&calls, 1))
+	})
+	l := NewHTTPLoader()
+	defer l.Close()
+	swr := time.Hour
+	cache := HTTPMiddleware(l, func(r *http.Request) string { return "key" }, func(resp *HTTPResponse) (time.Duration, time.Duration) {
+		return 20 * time.Millisecond, swr
+	})(handler)
+	get := func() string {
+		rec := httptest.NewRecorder()
+		cache.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "1", get())
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, "1", get(), "expired response must be served stale within stale-while-revalidate")
+	assert.Eventually(t, func() bool { return get() == "2" }, time.Second, time.Millisecond)
+
+	swr = 0
+	l.Invalidate("key")
+	assert.Equal(t, "3", get())
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, "4", get(), "past stale-while-revalidate the request must wait for the fresh response")
+}
+
+func TestLoadHTTPResponse(t *testing.T) {
+	l := NewHTTPLoader()
+	defer l.Close()
+	renders := 0
+	render := func(ctx context.Context) *HTTPResponse {
+		renders++
+		return &HTTPResponse{StatusCode: http.StatusOK, Header: http.Header{"Cache-Control": {"max-age=60"}}, Body: []byte("body")}
+	}
+
+	resp, info, err := LoadHTTPResponse(context.Background(), l, "/a", nil, render)
+	assert.NoError(t, err)
+	assert.Equal(t, "body", string(resp.Body))
+	assert.False(t, info.Cached)
+
+	_, info, _ = LoadHTTPResponse(context.Background(), l, "/a", nil, render)
+	assert.True(t, info.Cached)
+	assert.Equal(t, 1, renders)
+
+	resp, info, err = LoadHTTPResponse(context.Background(), l, "/b", nil, func(ctx context.Context) *HTTPResponse {
+		return &HTTPResponse{StatusCode: http.StatusNotFound}
+	})
+	assert.NoError(t, err, "uncacheable response is not an error")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, Info{}, info)
+}
+
+func TestHTTPMiddlewareUncacheableNotShared(t *testing.T) {
+	for name, header := range map[string]http.Header{
+		"private":    {"Cache-Control": {"private, max-age=60"}},
+		"set-cookie": {"Cache-Control": {"max-age=60"}, "Set-Cookie": {"session=1"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			entered, release := make(chan struct{}), make(chan struct{})
+			var calls int32
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					close(entered)
+					<-release
+				}
+				for k, v := range header {
+					w.Header()[k] = v
+				}
+				fmt.Fprint(w, "me: ", r.Header.Get("X-User"))
+			})
+			l := NewHTTPLoader()
+			defer l.Close()
+			cache := HTTPMiddleware(l, nil, nil)(handler)
+			get := func(user string) string {
+				req := httptest.NewRequest(http.MethodGet, "/me", nil)
+				req.Header.Set("X-User", user)
+				rec := httptest.NewRecorder()
+				cache.ServeHTTP(rec, req)
+				return rec.Body.String()
+			}
+
+			bodies := make(chan [2]string, 4)
+			go func() { bodies <- [2]string{"a", get("a")} }()
+			<-entered
+			for _, user := range []string{"b", "c", "d"} {
+				go func(user string) { bodies <- [2]string{user, get(user)} }(user)
+			}
+			// the requests of b, c and d wait for the fetch of a
+			assert.Eventually(t, func() bool { return l.Stats().Hits == 3 }, time.Second, time.Millisecond)
+			close(release)
+			for i := 0; i < 4; i++ {
+				b := <-bodies
+				assert.Equal(t, "me: "+b[0], b[1])
+			}
+			assert.Equal(t, "me: e", get("e"), "uncacheable response must not be cached")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRangeNotSupported is returned by Range when the cache driver doesn't implement Ranger
+var ErrRangeNotSupported = errors.New("cache driver doesn't support range")
+
+// Info contains the metadata of a cached item
+type Info struct {
+	// State is the state of the item when it's loaded
+	State State
+	// Cached is true when the value is served from cache instead of fetched by this call
+	Cached bool
+	// Stale is true when the value is expired and waiting to be refreshed
+	Stale bool
+	// Fallback is true when the stale value is returned because the fetch exceeded the caller's deadline
+	Fallback bool
+	// Placeholder is true when the placeholder of WithColdStartTimeout is returned
+	Placeholder bool
+
+	// FetchedAt is the time when the value was fetched
+	FetchedAt time.Time
+	// Expire is the time when the value becomes stale
+	Expire time.Time
+	// HardExpire is the time when the value is no longer served, zero if WithHardTTL is not set
+	HardExpire time.Time
+
+	// Hits counts how many times the item has been served from cache
+	Hits uint64
+	// LastAccess is the time of the last cache hit, zero if it has never been hit
+	LastAccess time.Time
+}
+
+// Range calls fn for each successfully loaded item in the cache.
+// If fn returns false, Range stops the iteration.
+// Items that are still being loaded for the first time are skipped.
+func (l *Loader[Key, Value]) Range(fn func(key Key, value Value, info Info) bool) error {
+	ranger, ok := l.driver.(Ranger)
+	if !ok {
+		return ErrRangeNotSupported
+	}
+
+	now := time.Now()
+	ranger.Range(func(k, v interface{}) bool {
+		key, ok := l.loaderKey(k)
+		if !ok {
+			return true
+		}
+		item, ok := v.(*cacheItem[Value])
+		if !ok {
+			return true
+		}
+
+		p := item.payload.Load()
+		if p == nil || p.err != nil {
+			return true
+		}
+		return fn(key, p.value, item.info(p, now, true))
+	})
+	return nil
+}
+
+// touch records a cache hit
+func (i *cacheItem[Value]) touch(now time.Time) {
+	i.hits.Add(1)
+	i.lastAccess.Store(now.UnixNano())
+}
+
+// info returns the item metadata with p as its payload
+func (i *cacheItem[Value]) info(p *payload[Value], now time.Time, cached bool) Info {
+	info := Info{
+		State:      i.currentState(p, now),
+		Cached:     cached,
+		Stale:      p.expire.Before(now),
+		FetchedAt:  p.fetchedAt,
+		Expire:     p.expire,
+		HardExpire: p.hardExpire,
+		Hits:       i.hits.Load(),
+	}
+	if lastAccess := i.lastAccess.Load(); lastAccess > 0 {
+		info.LastAccess = time.Unix(0, lastAccess)
+	}
+	return info
+}
@@ -0,0 +1,107 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithInlineRefresh refreshes expired items without starting goroutines,
+// for runtimes where background work is undesirable such as WASM or some serverless platforms.
+// The Load that finds an item expired still returns the stale value right away.
+// The refresh is queued and run by the next Load of the loader before it returns, or by RunRefreshes,
+// for example once the response has been sent.
+// It can't be combined with WithRefreshWorkers, WithRefreshAhead or WithPeriodicRefresh, which run their own goroutines.
+func WithInlineRefresh() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.inlineRefresh = true
+	})
+}
+
+// checkInlineRefresh rejects the options that refresh from goroutines
+func (l *Loader[Key, Value]) checkInlineRefresh() error {
+	if !l.inlineRefresh {
+		return nil
+	}
+	for _, o := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"WithRefreshWorkers", l.refreshWorkers > 0},
+		{"WithRefreshAhead", l.refreshAhead > 0},
+		{"WithPeriodicRefresh", l.periodicRefresh > 0},
+	} {
+		if o.enabled {
+			return fmt.Errorf("%w: WithInlineRefresh can't be combined with %s", ErrInvalidConfig, o.name)
+		}
+	}
+	return nil
+}
+
+// inlineRefreshes holds the refreshes scheduled with WithInlineRefresh, in the order they were scheduled
+type inlineRefreshes[Key comparable, Value any] struct {
+	mutex sync.Mutex
+	tasks []refreshTask[Key, Value]
+}
+
+func (q *inlineRefreshes[Key, Value]) push(ctx context.Context, key Key, item *cacheItem[Value]) {
+	q.mutex.Lock()
+	q.tasks = append(q.tasks, refreshTask[Key, Value]{ctx: ctx, key: key, item: item})
+	q.mutex.Unlock()
+}
+
+func (q *inlineRefreshes[Key, Value]) pop() (refreshTask[Key, Value], bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.tasks) == 0 {
+		return refreshTask[Key, Value]{}, false
+	}
+	task := q.tasks[0]
+	q.tasks[0] = refreshTask[Key, Value]{}
+	q.tasks = q.tasks[1:]
+	return task, true
+}
+
+// takeInlineRefresh takes the oldest queued refresh for the current Load to run before it returns.
+// It's taken before the Load looks up its own key, so a caller never pays for the refresh it schedules.
+func (l *Loader[Key, Value]) takeInlineRefresh() (refreshTask[Key, Value], bool) {
+	if l.inline == nil {
+		return refreshTask[Key, Value]{}, false
+	}
+	return l.inline.pop()
+}
+
+// RunRefreshes runs the refreshes queued by WithInlineRefresh in the calling goroutine and returns how many it ran
+func (l *Loader[Key, Value]) RunRefreshes() int {
+	n := 0
+	for {
+		task, ok := l.takeInlineRefresh()
+		if !ok {
+			return n
+		}
+		l.refetch(task.ctx, task.key, task.item)
+		n++
+	}
+}
+
+// dropInlineRefreshes releases the queued refreshes when the loader is closed, so Wait doesn't block on them
+func (l *Loader[Key, Value]) dropInlineRefreshes() {
+	for {
+		task, ok := l.inline.pop()
+		if !ok {
+			return
+		}
+		task.item.endRefresh()
+		l.background.Done()
+	}
+}
+
+// refetchContext derives the context of a refresh, inline refreshes run within a Load
+// so they don't need the goroutine that cancels background refreshes on Close
+func (l *Loader[Key, Value]) refetchContext(trigger context.Context, key Key) (context.Context, context.CancelFunc) {
+	if l.inline != nil {
+		return context.WithCancel(l.fetchContext(trigger, key, FetchStaleRefresh))
+	}
+	return l.backgroundContext(l.fetchContext(trigger, key, FetchStaleRefresh))
+}
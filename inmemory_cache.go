@@ -1,19 +1,22 @@
 package loader
 
-import "sync"
+// inMemoryShards is the shard count of InMemoryCache, enough to keep lock contention low without wasting memory on small caches
+const inMemoryShards = 64
 
 type inMemoryCache struct {
-	sync.Map
+	shardedCache
 }
 
+// InMemoryCache creates the default in-memory cache driver.
+// Keys are spread by hash across a fixed number of shards, see ShardedInMemoryCache to pick the count.
 func InMemoryCache() CacheDriver {
-	return &inMemoryCache{}
+	return newInMemoryCache()
 }
 
-func (c *inMemoryCache) Add(key interface{}, value interface{}) {
-	c.Store(key, value)
-}
-
-func (c *inMemoryCache) Get(key interface{}) (interface{}, bool) {
-	return c.Load(key)
+func newInMemoryCache() *inMemoryCache {
+	c := &inMemoryCache{shardedCache{shards: make([]cacheShard, inMemoryShards)}}
+	for i := range c.shards {
+		c.shards[i].items = map[interface{}]interface{}{}
+	}
+	return c
 }
@@ -0,0 +1,133 @@
+package loader
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRemoveNotSupported is returned when the cache driver doesn't implement Remover
+var ErrRemoveNotSupported = errors.New("cache driver doesn't support remove")
+
+// Remover is implemented by cache drivers that can remove items.
+// It is required by Loader.Invalidate and Loader.InvalidateAll.
+type Remover interface {
+	Remove(key interface{})
+}
+
+// namespacedKey is the driver key of loaders created with WithNamespace
+type namespacedKey struct {
+	namespace string
+	key       interface{}
+}
+
+// driverKey returns the key stored in the cache driver
+func (l *Loader[Key, Value]) driverKey(key Key) interface{} {
+	if l.namespace == "" {
+		return key
+	}
+	return namespacedKey{namespace: l.namespace, key: key}
+}
+
+// loaderKey converts the key stored in the cache driver back, it returns false if the key doesn't belong to this loader
+func (l *Loader[Key, Value]) loaderKey(dk interface{}) (Key, bool) {
+	if l.namespace == "" {
+		key, ok := dk.(Key)
+		return key, ok
+	}
+	nk, ok := dk.(namespacedKey)
+	if !ok || nk.namespace != l.namespace {
+		var zero Key
+		return zero, false
+	}
+	key, ok := nk.key.(Key)
+	return key, ok
+}
+
+// Invalidate removes the item from the cache, so the next Load fetches it again.
+// The items that depend on it by WithDependencies are invalidated too.
+func (l *Loader[Key, Value]) Invalidate(key Key) error {
+	return l.InvalidateCtx(context.Background(), key)
+}
+
+// InvalidateCtx is Invalidate with the context that carries WithAuditReason
+func (l *Loader[Key, Value]) InvalidateCtx(ctx context.Context, key Key) error {
+	remover, ok := l.driver.(Remover)
+	if !ok {
+		return ErrRemoveNotSupported
+	}
+	var visited map[Key]bool
+	if l.deps != nil {
+		visited = map[Key]bool{}
+	}
+	l.invalidate(ctx, remover, l.mapKey(key), visited)
+	return nil
+}
+
+// invalidate removes the item and cascades to its dependents, visited guards against dependency cycles
+func (l *Loader[Key, Value]) invalidate(ctx context.Context, remover Remover, key Key, visited map[Key]bool) {
+	if l.deps != nil {
+		visited[key] = true
+		defer func() {
+			for _, dependent := range l.deps.invalidated(key) {
+				if !visited[dependent] {
+					l.invalidate(ctx, remover, dependent, visited)
+				}
+			}
+		}()
+	}
+
+	dk := l.driverKey(key)
+	if l.debouncer != nil {
+		l.debouncer.invalidated(key)
+		// an item whose fetch is still being debounced will fetch after this invalidation anyway
+		if iface, ok := l.driver.Get(dk); ok {
+			if item, ok := iface.(*cacheItem[Value]); ok && item.debouncing.Load() {
+				return
+			}
+		}
+	}
+	remover.Remove(dk)
+	l.pending.forget(key)
+	l.emit(EventEvict, key, nil)
+	l.record(ctx, AuditInvalidate, key, nil)
+}
+
+// InvalidateAll removes every item of this loader from the cache.
+// When the driver is shared, use WithNamespace so only the items of this loader are removed.
+func (l *Loader[Key, Value]) InvalidateAll() error {
+	return l.InvalidateAllCtx(context.Background())
+}
+
+// InvalidateAllCtx is InvalidateAll with the context that carries WithAuditReason
+func (l *Loader[Key, Value]) InvalidateAllCtx(ctx context.Context) error {
+	remover, ok := l.driver.(Remover)
+	if !ok {
+		return ErrRemoveNotSupported
+	}
+	ranger, ok := l.driver.(Ranger)
+	if !ok {
+		return ErrRangeNotSupported
+	}
+
+	var keys []interface{}
+	ranger.Range(func(dk, value interface{}) bool {
+		if _, ok := l.loaderKey(dk); ok {
+			if _, ok := value.(*cacheItem[Value]); ok {
+				keys = append(keys, dk)
+			}
+		}
+		return true
+	})
+	for _, dk := range keys {
+		remover.Remove(dk)
+		if l.audit != nil {
+			key, _ := l.loaderKey(dk)
+			l.record(ctx, AuditInvalidate, key, nil)
+		}
+	}
+	l.pending.forgetAll()
+	if l.deps != nil {
+		l.deps.clear()
+	}
+	return nil
+}
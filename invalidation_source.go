@@ -0,0 +1,111 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// InvalidationSource delivers the keys to invalidate, e.g. from change data capture events,
+// so every process subscribed to it evicts the affected items. See kafkasource for a Kafka consumer.
+type InvalidationSource[Key comparable] interface {
+	// Subscribe calls invalidate for every key until ctx is done or the source fails
+	Subscribe(ctx context.Context, invalidate func(key Key)) error
+}
+
+// ChanSource is InvalidationSource that reads the keys from a channel, Subscribe returns when it's closed
+type ChanSource[Key comparable] <-chan Key
+
+// Subscribe implements InvalidationSource
+func (ch ChanSource[Key]) Subscribe(ctx context.Context, invalidate func(key Key)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case key, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			invalidate(key)
+		}
+	}
+}
+
+// DecodingSource is InvalidationSource that receives messages with Read and decodes the keys they invalidate with Decode,
+// the building block of kafkasource and pgsource. Subscribe returns the error of Read.
+// A message that fails to decode is reported to OnError, if it's set, and skipped, so one bad message doesn't stop the invalidations.
+type DecodingSource[Key comparable, Message any] struct {
+	Read    func(ctx context.Context) (Message, error)
+	Decode  func(m Message) ([]Key, error)
+	OnError func(err error)
+}
+
+// Subscribe implements InvalidationSource
+func (s DecodingSource[Key, Message]) Subscribe(ctx context.Context, invalidate func(key Key)) error {
+	for {
+		m, err := s.Read(ctx)
+		if err != nil {
+			return err
+		}
+		keys, err := s.Decode(m)
+		if err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+			continue
+		}
+		for _, key := range keys {
+			invalidate(key)
+		}
+	}
+}
+
+// WithInvalidationSource invalidates the keys delivered by source until the loader is closed.
+// When Subscribe fails, it's called again after retry, or the loader stops listening if retry is zero.
+// The driver must implement Remover.
+func WithInvalidationSource[Key comparable](source InvalidationSource[Key], retry time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.invalidationSource = source
+		cfg.invalidationRetry = retry
+	})
+}
+
+// checkInvalidationSource validates the driver used with WithInvalidationSource
+func (l *Loader[Key, Value]) checkInvalidationSource() error {
+	if l.invalidationSource == nil {
+		return nil
+	}
+	if _, ok := l.driver.(Remover); !ok {
+		return fmt.Errorf("%w: WithInvalidationSource requires a driver that implements Remover", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// startInvalidationSource starts the subscription of WithInvalidationSource
+func (l *Loader[Key, Value]) startInvalidationSource(source InvalidationSource[Key]) {
+	if source != nil && l.startBackground() {
+		go l.subscribeInvalidations(source)
+	}
+}
+
+func (l *Loader[Key, Value]) subscribeInvalidations(source InvalidationSource[Key]) {
+	defer l.background.Done()
+	ctx := l.lifecycle.ctx
+	invalidationCtx := WithAuditReason(ctx, "invalidation source")
+	for {
+		err := source.Subscribe(ctx, func(key Key) {
+			l.InvalidateCtx(invalidationCtx, key)
+		})
+		if err == nil || ctx.Err() != nil || l.invalidationRetry <= 0 {
+			return
+		}
+
+		timer := time.NewTimer(l.invalidationRetry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
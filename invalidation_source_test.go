@@ -0,0 +1,89 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flakySource struct {
+	subscribes int32
+	keys       chan string
+}
+
+func (s *flakySource) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	if atomic.AddInt32(&s.subscribes, 1) == 1 {
+		return errors.New("connection reset")
+	}
+	return ChanSource[string](s.keys).Subscribe(ctx, invalidate)
+}
+
+func TestInvalidationSource(t *testing.T) {
+	var fetches int32
+	fetch := func(ctx context.Context, key string) (int32, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}
+	source := &flakySource{keys: make(chan string)}
+	l := New(fetch, time.Hour, WithInvalidationSource[string](source, time.Millisecond))
+
+	val, _ := l.Load("a")
+	assert.Equal(t, int32(1), val)
+	source.keys <- "a"
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("a")
+		return val == 2
+	}, time.Second, time.Millisecond, "key from the source must be invalidated")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&source.subscribes), "failed subscription must be retried")
+
+	l.Close()
+	stopped := make(chan struct{})
+	go func() {
+		l.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Close must stop the subscription")
+	}
+
+	_, err := NewE(fetch, time.Hour, WithDriver(droppingDriver{}), WithInvalidationSource[string](source, 0))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestDecodingSource(t *testing.T) {
+	messages := []string{"a,b", "", "c"}
+	closed := errors.New("closed")
+	var decodeErrors int
+	source := DecodingSource[string, string]{
+		Read: func(ctx context.Context) (string, error) {
+			if len(messages) == 0 {
+				return "", closed
+			}
+			m := messages[0]
+			messages = messages[1:]
+			return m, nil
+		},
+		Decode: func(m string) ([]string, error) {
+			if m == "" {
+				return nil, errors.New("empty message")
+			}
+			return strings.Split(m, ","), nil
+		},
+		OnError: func(err error) { decodeErrors++ },
+	}
+	var keys []string
+	err := source.Subscribe(context.Background(), func(key string) { keys = append(keys, key) })
+	assert.ErrorIs(t, err, closed)
+	assert.Equal(t, []string{"a", "b", "c"}, keys, "a message that fails to decode must be skipped")
+	assert.Equal(t, 1, decodeErrors)
+
+	messages = []string{""}
+	source.OnError = nil
+	assert.ErrorIs(t, source.Subscribe(context.Background(), func(key string) {}), closed)
+}
@@ -0,0 +1,77 @@
+// Package kafkasource invalidates loader items from the change events of a Kafka topic.
+// It doesn't depend on a Kafka client, adapt a consumer to ReadFunc, e.g. a kafka-go Reader of a consumer group:
+//
+//	r := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: "users.changes", GroupID: hostname})
+//	source := kafkasource.New(func(ctx context.Context) (kafkasource.Message, error) {
+//		m, err := r.ReadMessage(ctx)
+//		return kafkasource.Message{Key: m.Key, Value: m.Value}, err
+//	}, kafkasource.KeyString)
+//	users := loader.New(fetchUser, time.Hour, loader.WithInvalidationSource[string](source, time.Second))
+//
+// Use a distinct group ID per process, so every process receives every event.
+package kafkasource
+
+import (
+	"context"
+	"errors"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// Message is a consumed Kafka record
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// ReadFunc blocks until the next message is consumed, or fails when ctx is done
+type ReadFunc func(ctx context.Context) (Message, error)
+
+// DecodeFunc returns the keys invalidated by a message, e.g. the primary key of a CDC event.
+// A message that fails to decode is skipped, see WithErrorHandler.
+type DecodeFunc[Key comparable] func(m Message) ([]Key, error)
+
+// KeyString is DecodeFunc that invalidates the record key
+func KeyString(m Message) ([]string, error) {
+	if len(m.Key) == 0 {
+		return nil, errors.New("kafkasource: message without key")
+	}
+	return []string{string(m.Key)}, nil
+}
+
+// Source is loader.InvalidationSource reading messages from Kafka
+type Source[Key comparable] struct {
+	source loader.DecodingSource[Key, Message]
+}
+
+var _ loader.InvalidationSource[string] = &Source[string]{}
+
+// Option configures Source
+type Option func(o *options)
+
+type options struct {
+	onError func(err error)
+}
+
+// WithErrorHandler reports the messages that fail to decode, nil is ignored
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.onError = fn
+		}
+	}
+}
+
+// New creates Source consuming messages with read and decoding their keys with decode
+func New[Key comparable](read ReadFunc, decode DecodeFunc[Key], opts ...Option) *Source[Key] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Source[Key]{source: loader.DecodingSource[Key, Message]{Read: read, Decode: decode, OnError: o.onError}}
+}
+
+// Subscribe implements loader.InvalidationSource, it returns the error of read
+func (s *Source[Key]) Subscribe(ctx context.Context, invalidate func(key Key)) error {
+	return s.source.Subscribe(ctx, invalidate)
+}
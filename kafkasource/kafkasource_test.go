@@ -0,0 +1,69 @@
+package kafkasource
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	messages := make(chan Message)
+	read := func(ctx context.Context) (Message, error) {
+		select {
+		case m := <-messages:
+			return m, nil
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+	var decodeErrors int32
+	source := New(read, KeyString, WithErrorHandler(func(err error) {
+		atomic.AddInt32(&decodeErrors, 1)
+	}))
+
+	var fetches int32
+	l := loader.New(func(ctx context.Context, key string) (int32, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}, time.Hour, loader.WithInvalidationSource[string](source, time.Millisecond))
+	defer l.Close()
+
+	val, _ := l.Load("user-1")
+	assert.Equal(t, int32(1), val)
+
+	messages <- Message{Value: []byte("no key")}
+	messages <- Message{Key: []byte("user-1")}
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("user-1")
+		return val == 2
+	}, time.Second, time.Millisecond, "changed key must be invalidated")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&decodeErrors))
+}
+
+func TestSourceReadError(t *testing.T) {
+	failure := errors.New("broker unavailable")
+	source := New(func(ctx context.Context) (Message, error) {
+		return Message{}, failure
+	}, KeyString)
+	err := source.Subscribe(context.Background(), func(key string) {})
+	assert.ErrorIs(t, err, failure)
+}
+
+func TestSourceNilErrorHandler(t *testing.T) {
+	messages := []Message{{Value: []byte("no key")}}
+	failure := errors.New("no more messages")
+	source := New(func(ctx context.Context) (Message, error) {
+		if len(messages) == 0 {
+			return Message{}, failure
+		}
+		m := messages[0]
+		messages = messages[1:]
+		return m, nil
+	}, KeyString, WithErrorHandler(nil))
+	err := source.Subscribe(context.Background(), func(key string) {})
+	assert.ErrorIs(t, err, failure, "nil error handler must be ignored")
+}
@@ -1,74 +1,144 @@
 package loader
 
 import (
+	"context"
 	"sync"
 )
 
+// KeyLocker serializes the fetches of the same key, see WithKeyLocker.
+// drivertest.RunKeyLocker checks that an implementation is well-behaved.
 type KeyLocker[Key comparable] interface {
+	// Lock blocks until the lock of key is acquired
 	Lock(key Key) (unlock func())
+	// TryLock acquires the lock of key only if it's free, ok is false otherwise
+	TryLock(key Key) (unlock func(), ok bool)
+	// LockCtx works like Lock, but returns ctx error if ctx is done before the lock is acquired
+	LockCtx(ctx context.Context, key Key) (unlock func(), err error)
 }
 
 func newInMemoryKeyLocker[Key comparable]() KeyLocker[Key] {
-	return &InMemoryKeyLocker[Key]{
-		locks: map[Key]*inMemoryKeyLockerItem{},
-	}
+	return &InMemoryKeyLocker[Key]{}
+}
+
+// WithKeyLocker replaces the in-memory KeyLocker of the loader, for example with one that locks across processes
+func WithKeyLocker[Key comparable](locker KeyLocker[Key]) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.locker = locker
+	})
 }
 
+// InMemoryKeyLocker is the default KeyLocker, its zero value is ready to use.
+// Keys are sharded by hash, so locking distinct keys rarely contends.
 type InMemoryKeyLocker[Key comparable] struct {
-	root  sync.Mutex
-	locks map[Key]*inMemoryKeyLockerItem
+	locks hashMap[Key, *inMemoryKeyLockerItem]
+}
+
+// keyLockerItems recycles the items of InMemoryKeyLocker, every miss locks a key that's usually not locked yet
+var keyLockerItems = sync.Pool{
+	New: func() interface{} {
+		return &inMemoryKeyLockerItem{sem: make(chan struct{}, 1)}
+	},
 }
 
 type inMemoryKeyLockerItem struct {
 	ref int32
-	m   sync.Mutex
+	// sem holds a token while the key is locked, unlike sync.Mutex it can be waited with select
+	sem chan struct{}
 }
 
 // Lock implements KeyLocker
 func (l *InMemoryKeyLocker[Key]) Lock(key Key) func() {
-	item := l.getItem(key)
-	item.m.Lock()
+	hash := l.locks.hashOf(key)
+	item := l.getItem(key, hash)
+	item.sem <- struct{}{}
+	return l.unlocker(key, hash, item)
+}
+
+// TryLock implements KeyLocker
+func (l *InMemoryKeyLocker[Key]) TryLock(key Key) (func(), bool) {
+	hash := l.locks.hashOf(key)
+	item := l.getItem(key, hash)
+	select {
+	case item.sem <- struct{}{}:
+		return l.unlocker(key, hash, item), true
+	default:
+		l.releaseItem(key, hash)
+		return nil, false
+	}
+}
+
+// LockCtx implements KeyLocker
+func (l *InMemoryKeyLocker[Key]) LockCtx(ctx context.Context, key Key) (func(), error) {
+	return l.LockCtxHashed(ctx, key, l.locks.hashOf(key))
+}
+
+// LockCtxHashed implements HashedKeyLocker
+func (l *InMemoryKeyLocker[Key]) LockCtxHashed(ctx context.Context, key Key, hash uint64) (func(), error) {
+	item, err := l.lockItem(ctx, key, hash)
+	if err != nil {
+		return nil, err
+	}
+	return l.unlocker(key, hash, item), nil
+}
 
+// lockItem acquires the lock of key without allocating the unlock closure, the caller must call unlockItem
+func (l *InMemoryKeyLocker[Key]) lockItem(ctx context.Context, key Key, hash uint64) (*inMemoryKeyLockerItem, error) {
+	item := l.getItem(key, hash)
+	select {
+	case item.sem <- struct{}{}:
+		return item, nil
+	case <-ctx.Done():
+		l.releaseItem(key, hash)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *InMemoryKeyLocker[Key]) unlockItem(key Key, hash uint64, item *inMemoryKeyLockerItem) {
+	<-item.sem
+	l.releaseItem(key, hash)
+}
+
+func (l *InMemoryKeyLocker[Key]) unlocker(key Key, hash uint64, item *inMemoryKeyLockerItem) func() {
 	unlocked := false
 	return func() {
 		if unlocked {
 			return
 		}
-		item.m.Unlock()
-		l.releaseItem(key)
+		l.unlockItem(key, hash, item)
 
 		unlocked = true
 	}
 }
 
-func (l *InMemoryKeyLocker[Key]) getItem(key Key) *inMemoryKeyLockerItem {
-	l.root.Lock()
-	defer l.root.Unlock()
-
-	item, ok := l.locks[key]
-	if !ok {
-		item = &inMemoryKeyLockerItem{}
-		l.locks[key] = item
-	}
-
-	item.ref += 1
-	return item
+func (l *InMemoryKeyLocker[Key]) getItem(key Key, hash uint64) *inMemoryKeyLockerItem {
+	return l.locks.updateHashed(key, hash, func(item *inMemoryKeyLockerItem, ok bool) (*inMemoryKeyLockerItem, bool) {
+		if !ok {
+			item = keyLockerItems.Get().(*inMemoryKeyLockerItem)
+		}
+		item.ref += 1
+		return item, true
+	})
 }
 
-func (l *InMemoryKeyLocker[Key]) releaseItem(key Key) {
-	l.root.Lock()
-	defer l.root.Unlock()
-
-	item, ok := l.locks[key]
-	if !ok {
-		return
-	}
-
-	item.ref -= 1
-	if item.ref <= 0 {
-		delete(l.locks, key)
+func (l *InMemoryKeyLocker[Key]) releaseItem(key Key, hash uint64) {
+	var unused *inMemoryKeyLockerItem
+	l.locks.updateHashed(key, hash, func(item *inMemoryKeyLockerItem, ok bool) (*inMemoryKeyLockerItem, bool) {
+		if !ok {
+			return nil, false
+		}
+		item.ref -= 1
+		if item.ref > 0 {
+			return item, true
+		}
+		unused = item
+		return nil, false
+	})
+	// nobody else references the item once it's removed from the map
+	if unused != nil {
+		keyLockerItems.Put(unused)
 	}
 }
 
 var _ KeyLocker[int] = &InMemoryKeyLocker[int]{}
 var _ KeyLocker[string] = &InMemoryKeyLocker[string]{}
+var _ HashedKeyLocker[string] = &InMemoryKeyLocker[string]{}
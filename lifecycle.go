@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle owns the background goroutines of a Loader
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex      sync.RWMutex
+	isClosed   bool
+	background sync.WaitGroup
+	onClose    []func()
+}
+
+func newLifecycle() lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// startBackground registers a background goroutine, it returns false if the loader is closed.
+// The goroutine must call background.Done when it finishes.
+func (lc *lifecycle) startBackground() bool {
+	lc.mutex.RLock()
+	defer lc.mutex.RUnlock()
+
+	if lc.isClosed {
+		return false
+	}
+	lc.background.Add(1)
+	return true
+}
+
+func (lc *lifecycle) closed() bool {
+	lc.mutex.RLock()
+	defer lc.mutex.RUnlock()
+	return lc.isClosed
+}
+
+// backgroundContext derives ctx that is also cancelled when the loader is closed
+func (lc *lifecycle) backgroundContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-lc.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Close cancels in-flight background refreshes and stops scheduling new ones.
+// The loader still serves cached items and fetches missing ones after it's closed.
+func (lc *lifecycle) Close() error {
+	lc.mutex.Lock()
+	if lc.isClosed {
+		lc.mutex.Unlock()
+		return nil
+	}
+	lc.isClosed = true
+	onClose := lc.onClose
+	lc.mutex.Unlock()
+
+	lc.cancel()
+	for _, fn := range onClose {
+		fn()
+	}
+	return nil
+}
+
+// Wait blocks until all background refreshes are finished.
+// Call it after Close, or when no Load is running.
+func (lc *lifecycle) Wait() {
+	lc.background.Wait()
+}
@@ -0,0 +1,47 @@
+package loader
+
+import (
+	"context"
+	"sync"
+)
+
+// LoadAll loads keys in parallel with at most concurrency loads at a time (all of them if concurrency <= 0).
+// values is in the order of keys. errs is nil if every key succeeds, otherwise it's aligned with keys,
+// a failed key doesn't stop the others.
+func (l *Loader[Key, Value]) LoadAll(ctx context.Context, keys []Key, concurrency int) (values []Value, errs []error) {
+	if concurrency <= 0 || concurrency > len(keys) {
+		concurrency = len(keys)
+	}
+	values = make([]Value, len(keys))
+	keyErrs := make([]error, len(keys))
+	failed := false
+	var mutex sync.Mutex
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				value, err := l.LoadCtx(ctx, keys[i])
+				values[i], keyErrs[i] = value, err
+				if err != nil {
+					mutex.Lock()
+					failed = true
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range keys {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if failed {
+		return values, keyErrs
+	}
+	return values, nil
+}
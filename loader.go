@@ -3,7 +3,6 @@ package loader
 import (
 	"context"
 	"fmt"
-	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -15,6 +14,12 @@ type CacheDriver interface {
 	Get(key interface{}) (interface{}, bool)
 }
 
+// Ranger is implemented by cache drivers that can iterate over their items.
+// It is required by Loader.Range.
+type Ranger interface {
+	Range(fn func(key, value interface{}) bool)
+}
+
 // Fetcher loads the value based on key
 type Fetcher[Key comparable, Value any] func(ctx context.Context, key Key) (Value, error)
 
@@ -24,101 +29,695 @@ type Loader[Key comparable, Value any] struct {
 	fn  Fetcher[Key, Value]
 	def Value
 
-	lock KeyLocker[Key]
+	lock    KeyLocker[Key]
+	pending *pendingCalls[Key, Value]
+	refresh *refreshQueue[Key, Value]
+	hotKey  *hotKeyDetector[Key]
+	expiry  *expiryIndex[Key, Value]
+	cost    func(value Value) int64
+
+	keyMapper func(key Key) Key
+	keyCf     KeyContextFactory[Key]
+	coldStart *coldStart[Value]
+	debouncer *invalidationDebouncer[Key]
+	warmKeys  func(ctx context.Context) ([]Key, error)
+	fetchAll  func(ctx context.Context) (map[Key]Value, error)
+	tenants   *tenancy[Key]
+	health    *refreshHealth
+	deps      *dependencyGraph[Key, Value]
+	// onEvict is called for the items evicted by the driver, see EvictionNotifier
+	onEvict func(key Key, item *cacheItem[Value])
+
+	generations *generations[Key, Value]
+	generation  atomic.Pointer[generation[Key, Value]]
+	// batch is the batcher of loaders created by NewBatch, LoadMany fetches its misses with one call
+	batch *batcher[Key, Value]
+	// hashedDriver and hashedLock are set if the key hash can be reused between them, see HashedDriver
+	hashedDriver HashedDriver
+	hashedLock   HashedKeyLocker[Key]
+	// inline queues the refreshes of WithInlineRefresh
+	inline *inlineRefreshes[Key, Value]
+	// readRepair is set by WithReadRepair
+	readRepair *readRepair[Key, Value]
+	// events is created by the first call of Events
+	events atomic.Pointer[eventBus[Key]]
+	// memLock is set if lock is the default InMemoryKeyLocker, see lockKey
+	memLock *InMemoryKeyLocker[Key]
+	// writeThrough is true if the driver must be re-added after every fetch, see RemoteCache
+	writeThrough bool
+	ttlFunc      func(key Key, value Value, err error) time.Duration
+	cloner       func(value Value) Value
+	transform    func(key Key, value Value) Value
+
+	counters counters
+	// startedAt is the time when the loader is created, see WithStartupSmear
+	startedAt time.Time
+	lifecycle
 }
 
-// New creates new Loader
+// New creates new Loader.
+// ttl is the soft TTL: after it passes, the stale value is served while it's refreshed in background.
+// Use WithHardTTL to stop serving values that are too old.
+// It panics if the options don't match the types or the driver of the loader, use NewE to validate the options instead.
 func New[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	l, err := newLoader(fn, newConfig(ttl, options))
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+func newConfig(ttl time.Duration, options []Option) *config {
 	cfg := &config{
 		ttl:    ttl,
 		errTtl: ttl,
-		driver: &inMemoryCache{},
+		driver: newInMemoryCache(),
+		sink:   NopStatsSink{},
 		cf:     defaultContextFactory,
 	}
 	for _, o := range options {
-		o(cfg)
+		o.apply(cfg)
+	}
+	return cfg
+}
+
+func newLoader[Key comparable, Value any](fn Fetcher[Key, Value], cfg *config) (*Loader[Key, Value], error) {
+	var err error
+	l := &Loader[Key, Value]{
+		config:    cfg,
+		fn:        chain(fn, cfg.middlewares, &err),
+		pending:   newPendingCalls[Key, Value](),
+		startedAt: time.Now(),
+		lifecycle: newLifecycle(),
+	}
+	l.hotKey = typedOption[*hotKeyDetector[Key]](cfg.hotKey, "WithHotKeyDetector", &err)
+	l.cost = typedOption[func(Value) int64](cfg.cost, "WithCost", &err)
+	l.keyMapper = typedOption[func(Key) Key](cfg.keyMapper, "WithKeyMapper", &err)
+	l.warmKeys = typedOption[func(context.Context) ([]Key, error)](cfg.warmKeys, "WithWarmKeys", &err)
+	l.coldStart = typedOption[*coldStart[Value]](cfg.coldStart, "WithColdStartTimeout", &err)
+	l.cloner = typedOption[func(Value) Value](cfg.cloner, "WithCloner", &err)
+	l.keyCf = typedOption[KeyContextFactory[Key]](cfg.keyCf, "WithKeyContextFactory", &err)
+	if l.keyCf == nil {
+		l.keyCf = forKey[Key](cfg.cf)
+	}
+	tenantOf := typedOption[func(Key) string](cfg.tenantOf, "WithTenants", &err)
+	invalidationSource := typedOption[InvalidationSource[Key]](cfg.invalidationSource, "WithInvalidationSource", &err)
+	l.lock = typedOption[KeyLocker[Key]](cfg.locker, "WithKeyLocker", &err)
+	if l.lock == nil {
+		l.lock = newInMemoryKeyLocker[Key]()
+	}
+	if cfg.lockDebug > 0 {
+		l.lock = newDebugKeyLocker(l.lock, cfg.lockDebug, cfg.onLockError)
+	}
+	typed := make([]OptionT[Key, Value], len(cfg.typed))
+	for i, o := range cfg.typed {
+		typed[i] = typedOption[OptionT[Key, Value]](o, "OptionT", &err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l.setupTenants(tenantOf)
+	_, l.writeThrough = l.driver.(writeThroughDriver)
+	l.setupHashedKey()
+	l.setupEvictions()
+	for _, check := range []func() error{l.checkTenants, l.checkMaxValueSize, l.checkZeroValues, l.checkNoErrorCaching, l.checkPeriodicRefresh, l.checkInlineRefresh, l.checkInvalidationSource, l.checkAdmissionPolicy} {
+		if err := check(); err != nil {
+			return nil, err
+		}
 	}
-	return &Loader[Key, Value]{
-		config: cfg,
-		fn:     fn,
-		lock:   newInMemoryKeyLocker[Key](), // TODO: make it configurable
+
+	if cfg.refreshWorkers > 0 {
+		l.refresh = newRefreshQueue(cfg.refreshWorkers, l.refetch)
+	}
+	if cfg.inlineRefresh {
+		l.inline = &inlineRefreshes[Key, Value]{}
+		l.onClose = append(l.onClose, l.dropInlineRefreshes)
+	}
+	if cfg.invalidationDebounce > 0 {
+		l.debouncer = newInvalidationDebouncer[Key](cfg.invalidationDebounce)
+	}
+	if cfg.refreshAhead > 0 {
+		l.expiry = newExpiryIndex(l.refreshBeforeExpire)
+		l.onClose = append(l.onClose, l.expiry.stop)
+	}
+	l.startPeriodicRefresh()
+	l.startInvalidationSource(invalidationSource)
+	if cfg.refreshErrorWindow > 0 {
+		l.health = newRefreshHealth(cfg.refreshErrorWindow)
+	}
+	if cfg.register {
+		DefaultRegistry.Register(cfg.name, l)
+		l.onClose = append(l.onClose, func() { DefaultRegistry.unregister(cfg.name, l) })
 	}
+	for _, o := range typed {
+		o(l)
+	}
+	return l, nil
+}
+
+// typedOption asserts the value of generic option, it sets err if the type doesn't match the loader
+func typedOption[T any](v interface{}, name string, err *error) T {
+	t, ok := v.(T)
+	if v != nil && !ok && *err == nil {
+		*err = fmt.Errorf("%w: %s type %T doesn't match loader type %T", ErrInvalidConfig, name, v, t)
+	}
+	return t
+}
+
+// Name returns the name set by WithName or RegistryLoader
+func (l *Loader[Key, Value]) Name() string {
+	return l.name
 }
 
 // Load the item.
 // If it doesn't exist on cache, Loader will call LoadFunc once even when other go routine access the same key.
 // If the item is expired, it will return old value while loading new one.
 func (l *Loader[Key, Value]) Load(key Key) (Value, error) {
-	unlock := l.lock.Lock(key)
-	defer unlock()
+	return l.LoadCtx(context.Background(), key)
+}
 
-	iface, ok := l.driver.Get(key)
-	if ok {
-		unlock()
+// LoadCtx works like Load, ctx is used as the source of the values configured with WithContextValues.
+func (l *Loader[Key, Value]) LoadCtx(ctx context.Context, key Key) (Value, error) {
+	value, _, err := l.LoadWithInfoCtx(ctx, key)
+	return value, err
+}
 
-		if iface == nil {
-			return l.def, fmt.Errorf("cache driver returns ok but the value is nil")
-		}
+// Get is the name of Load in the pre-generics version.
+//
+// Deprecated: use Load, Get is kept so call sites don't need to be rewritten when migrating.
+func (l *Loader[Key, Value]) Get(key Key) (Value, error) {
+	return l.Load(key)
+}
 
-		item, ok := iface.(*cacheItem[Value])
-		if !ok {
-			return l.def, fmt.Errorf("cache driver returns invalid value %v", iface)
-		}
+// GetCtx is the context aware Get.
+//
+// Deprecated: use LoadCtx.
+func (l *Loader[Key, Value]) GetCtx(ctx context.Context, key Key) (Value, error) {
+	return l.LoadCtx(ctx, key)
+}
 
-		item.mutex.RLock()
-		defer item.mutex.RUnlock()
+// LoadWithInfo works like Load, but also returns the metadata of the cached item.
+func (l *Loader[Key, Value]) LoadWithInfo(key Key) (Value, Info, error) {
+	return l.LoadWithInfoCtx(context.Background(), key)
+}
+
+// LoadWithInfoCtx works like LoadWithInfo, with ctx used like in LoadCtx.
+func (l *Loader[Key, Value]) LoadWithInfoCtx(ctx context.Context, key Key) (Value, Info, error) {
+	value, info, err := l.load(ctx, key)
+	return l.cloneValue(value), info, err
+}
 
-		// if the item is expired and it's not doing refetch
-		if item.expire.Before(time.Now()) && atomic.CompareAndSwapInt32(&item.isFetching, 0, 1) {
-			go l.refetch(key, item)
+func (l *Loader[Key, Value]) load(ctx context.Context, key Key) (Value, Info, error) {
+	if task, ok := l.takeInlineRefresh(); ok {
+		defer l.refetch(task.ctx, task.key, task.item)
+	}
+	key = l.mapKey(key)
+	if l.hotKey != nil {
+		l.hotKey.record(key, time.Now())
+	}
+
+	iface, stale, miss, err := l.claim(ctx, key)
+	if err != nil {
+		return l.def, Info{}, err
+	}
+	if !miss {
+		return l.hit(ctx, key, iface)
+	}
+	return l.fetchForeground(ctx, key, iface.(*cacheItem[Value]), stale)
+}
+
+// claim returns the cached item of key, or a new pending item that the caller must fetch when miss is true.
+// stale is the hard expired payload replaced by the new item. It fails if ctx is done while waiting for the key lock.
+func (l *Loader[Key, Value]) claim(ctx context.Context, key Key) (iface interface{}, stale *payload[Value], miss bool, err error) {
+	if item := l.generationItem(key); item != nil {
+		return item, nil, false, nil
+	}
+
+	hk := l.hashedKey(key)
+
+	// fast path, most loads are served from cache without taking the key lock
+	if iface, ok := l.driverGetHashed(hk); ok && !l.dropCorrupt(hk.dk, iface) && l.hardExpired(iface) == nil {
+		return iface, nil, false, nil
+	}
+
+	// latecomers of a cold fetch wait for it without taking the key lock
+	if item := l.pending.get(key); item != nil {
+		return item, nil, false, nil
+	}
+
+	held, err := l.lockKey(ctx, key, hk)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer held.unlock()
+
+	// other goroutine may have started fetching the item while we're waiting for the lock
+	if item := l.pending.get(key); item != nil {
+		return item, nil, false, nil
+	}
+	if iface, ok := l.driverPeek(hk); ok && !l.dropCorrupt(hk.dk, iface) {
+		if stale = l.hardExpired(iface); stale == nil {
+			return iface, nil, false, nil
 		}
-		return item.value, item.err
+		l.emit(EventEvict, key, nil)
 	}
 
-	item := &cacheItem[Value]{isFetching: 0}
-	item.mutex.Lock()
-	defer item.mutex.Unlock()
+	l.countMiss(key)
+	item := newCacheItem[Value]()
+	if l.debouncer != nil {
+		// set before the item is published, so an invalidation racing with the claim sees it
+		item.debouncing.Store(true)
+	}
+	l.pending.add(key, item, stale)
+	l.driverAddHashed(hk, item)
+	return item, stale, true, nil
+}
 
-	l.driver.Add(key, item)
-	unlock()
+// fetchItem fetches the value in foreground and stores it in item
+func (l *Loader[Key, Value]) fetchItem(ctx context.Context, key Key, item *cacheItem[Value], stale *payload[Value]) *payload[Value] {
+	if l.debouncer != nil {
+		// debouncing is set by claim
+		l.debouncer.wait(key)
+		item.debouncing.Store(false)
+	}
+	value, rv, err := l.fetch(ctx, key, item, stale)
+	return l.storeResult(ctx, key, item, value, rv, err)
+}
 
-	value, err := l.fn(l.cf(), key)
+// storeResult stores the first fetch result of the pending item, ctx is the context of the Load
+func (l *Loader[Key, Value]) storeResult(ctx context.Context, key Key, item *cacheItem[Value], value Value, rv *revalidation[Value], err error) *payload[Value] {
+	defer l.pending.done(key, item)
 	if err != nil {
-		item.err = err
-		item.updateExpire(l.errTtl)
-		return l.def, err
+		p := item.store(l.newPayload(key, l.def, err))
+		if l.noErrorCaching {
+			l.uncache(key, item)
+			return p
+		}
+		l.stored(key, item, p)
+		l.record(ctx, AuditSet, key, err)
+		return p
+	}
+	p, outcome := l.storeValue(key, item, value, rv)
+	if outcome == valueCached {
+		l.record(ctx, AuditSet, key, nil)
+	}
+	return p
+}
+
+// fetchForeground fetches the item for the caller.
+// The caller stops waiting when the cold start timeout passes (returning the placeholder),
+// or when ctx is done with WithDeadlineFallback (returning stale if there is one, or ctx error otherwise).
+// In both cases the fetch continues in background and its result is cached.
+func (l *Loader[Key, Value]) fetchForeground(ctx context.Context, key Key, item *cacheItem[Value], stale *payload[Value]) (Value, Info, error) {
+	var timeout <-chan time.Time
+	if l.coldStart != nil && stale == nil {
+		timer := time.NewTimer(l.coldStart.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	// ctx of Load is not passed to the fetcher, so the fetch can be shared and cached, only the waiting stops
+	deadline := ctx.Done()
+	if timeout == nil && deadline == nil {
+		p := l.fetchItem(ctx, key, item, stale)
+		return p.value, item.info(p, time.Now(), false), p.err
+	}
+
+	done := make(chan *payload[Value], 1)
+	go func() {
+		done <- l.fetchItem(ctx, key, item, stale)
+	}()
+
+	select {
+	case p := <-done:
+		return p.value, item.info(p, time.Now(), false), p.err
+	case <-timeout:
+		return l.coldStart.placeholder, Info{Placeholder: true}, nil
+	case <-deadline:
+		return l.cancelled(ctx, item, stale)
 	}
-	item.value = value
-	item.updateExpire(l.ttl)
-	return value, nil
 }
 
-func (l *Loader[Key, Value]) refetch(key Key, item *cacheItem[Value]) {
-	defer atomic.StoreInt32(&item.isFetching, 0)
+// cancelled is returned when ctx is done before the first fetch of item completes.
+// It returns the stale value with WithDeadlineFallback if there is one, or the context error otherwise.
+func (l *Loader[Key, Value]) cancelled(ctx context.Context, item *cacheItem[Value], stale *payload[Value]) (Value, Info, error) {
+	if !l.deadlineFallback || stale == nil {
+		return l.def, Info{}, ctx.Err()
+	}
+	info := item.info(stale, time.Now(), true)
+	info.Stale, info.Fallback = true, true
+	return stale.value, info, stale.err
+}
+
+// hardExpired returns the payload of the item if it has passed its hard TTL, so it must be treated as a miss
+func (l *Loader[Key, Value]) hardExpired(iface interface{}) *payload[Value] {
+	if l.hardTTL <= 0 {
+		return nil
+	}
+	item, ok := iface.(*cacheItem[Value])
+	if !ok {
+		return nil
+	}
+	if p := item.payload.Load(); p != nil && !p.hardExpire.IsZero() && p.hardExpire.Before(time.Now()) {
+		return p
+	}
+	return nil
+}
 
-	value, err := l.fn(l.cf(), key)
+// mapKey normalizes the key using the mapper from WithKeyMapper
+func (l *Loader[Key, Value]) mapKey(key Key) Key {
+	if l.keyMapper == nil {
+		return key
+	}
+	return l.keyMapper(key)
+}
 
-	item.mutex.Lock()
-	defer item.mutex.Unlock()
+// hit serves the item found in the cache driver, scheduling refetch if it's expired
+func (l *Loader[Key, Value]) hit(ctx context.Context, key Key, iface interface{}) (Value, Info, error) {
+	if err := corruptEntry[Value](iface); err != nil {
+		return l.def, Info{}, err
+	}
+	item := iface.(*cacheItem[Value])
 
-	item.value, item.err = value, err
+	l.countHit(key)
+	now := time.Now()
+	item.touch(now)
+	p, err := item.load(ctx)
 	if err != nil {
-		item.updateExpire(l.errTtl)
+		return l.cancelled(ctx, item, l.pending.stale(key, item))
+	}
+	if l.mutationCheck && p.err == nil {
+		l.checkMutation(key, p)
+	}
+	if l.readRepair != nil {
+		l.checkReadRepair(key, p)
+	}
+	if p.expire.Before(now) {
+		l.emit(EventStaleHit, key, nil)
 	} else {
-		item.updateExpire(l.ttl)
+		l.emit(EventHit, key, nil)
+	}
+
+	// if the item is expired, it's allowed to be refreshed, and it's not doing refetch
+	if p.expire.Before(now) && p.canRefresh(now, l.minRefreshInterval) {
+		if item.beginRefresh() {
+			l.scheduleRefetch(ctx, key, item)
+		} else {
+			l.counters.refreshesCoalesced.Add(1)
+		}
+	}
+	return p.value, item.info(p, now, true), p.err
+}
+
+func (l *Loader[Key, Value]) scheduleRefetch(ctx context.Context, key Key, item *cacheItem[Value]) {
+	if !l.startBackground() {
+		item.endRefresh()
+		return
+	}
+	if l.refresh != nil {
+		l.refresh.push(ctx, key, item)
+		return
+	}
+	if l.inline != nil {
+		l.inline.push(ctx, key, item)
+		if l.closed() {
+			// Close may have dropped the queue before the push
+			l.dropInlineRefreshes()
+		}
+		return
+	}
+	if !l.acquireRefetchSlot() {
+		l.background.Done()
+		item.endRefresh()
+		return
+	}
+	go l.refetchGoroutine(ctx, key, item)
+}
+
+// refetch refreshes the item in background, trigger is the context of the Load that triggered it
+func (l *Loader[Key, Value]) refetch(trigger context.Context, key Key, item *cacheItem[Value]) {
+	defer l.background.Done()
+	defer item.endRefresh()
+
+	ctx, cancel := l.refetchContext(trigger, key)
+	defer cancel()
+
+	// when throttled, keep serving the stale value instead of caching the limiter error
+	if err := l.wait(ctx); err != nil {
+		return
+	}
+	// other process refreshes the key, the driver is shared
+	release, ok := l.acquireLease(key, item)
+	if !ok {
+		return
+	}
+	defer release()
+	l.sink.IncRefresh()
+	l.emit(EventRefreshStart, key, nil)
+	ctx, rv := l.revalidationContext(expiryHintContext(ctx, item), item.payload.Load())
+	value, err := l.callFetcher(ctx, key)
+	if l.closed() {
+		// the fetch may be cancelled by Close, keep the stale value
+		return
+	}
+	if l.health != nil {
+		l.health.record(err != nil)
+	}
+	if err != nil {
+		l.emit(EventRefreshError, key, err)
+		l.record(trigger, AuditRefresh, key, err)
+		if l.noErrorCaching {
+			// keep serving the stale value, the next Load retries
+			return
+		}
+		l.stored(key, item, item.store(l.newPayload(key, value, err)))
+		return
+	}
+	switch _, outcome := l.storeValue(key, item, value, rv); outcome {
+	case valueCached:
+		l.record(trigger, AuditRefresh, key, nil)
+	case valueUncached:
+		// the stale item is removed
+		l.record(trigger, AuditInvalidate, key, nil)
 	}
 }
 
+// storeOutcome is what storeValue did with the fetched value
+type storeOutcome int
+
+const (
+	valueCached storeOutcome = iota
+	// valueDiscarded keeps the cached value, the fetched one has an older stamp
+	valueDiscarded
+	// valueUncached removes the item from the cache, the value is oversized, zero, or rejected
+	valueUncached
+)
+
+// storeValue stores the result of successful fetch in item, rv is the revalidation of NewRevalidating if any
+func (l *Loader[Key, Value]) storeValue(key Key, item *cacheItem[Value], value Value, rv *revalidation[Value]) (*payload[Value], storeOutcome) {
+	if rv != nil && rv.stamped && l.olderStamp(item, rv.stamp) {
+		return item.payload.Load(), valueDiscarded
+	}
+	// a value that is not modified has been transformed when it's first fetched
+	if rv == nil || !rv.notModified {
+		value = l.transformValue(key, value)
+	}
+	p := l.newPayload(key, value, nil)
+	if rv != nil {
+		p.tag = rv.tag
+		p.stamp = rv.stamp
+	}
+	item.capExpiry(p)
+	item.store(p)
+	if l.oversized(value) {
+		l.counters.oversized.Add(1)
+		l.sink.IncOversized()
+		l.uncache(key, item)
+		return p, valueUncached
+	}
+	if l.uncachedZero(value) {
+		l.uncache(key, item)
+		return p, valueUncached
+	}
+	if l.rejected(key, value) {
+		l.counters.rejected.Add(1)
+		l.uncache(key, item)
+		return p, valueUncached
+	}
+	l.stored(key, item, p)
+	return p, valueCached
+}
+
+// stored is called after a fetch result is stored in item
+func (l *Loader[Key, Value]) stored(key Key, item *cacheItem[Value], p *payload[Value]) {
+	costed := l.cost != nil && p.err == nil
+	if costed {
+		item.itemCost.Store(l.cost(p.value))
+	}
+	if l.writeThrough || costed {
+		// re-add the item, so the driver can write the new payload and account the new cost
+		l.driver.Add(l.driverKey(key), item)
+	}
+	if p.err != nil {
+		// errors have no dependencies, the ones of the stale value are kept until it's replaced
+		return
+	}
+	if l.deps != nil {
+		l.deps.set(key, p.value)
+	}
+	if l.expiry != nil {
+		l.expiry.schedule(key, item, p, p.expire.Add(-l.refreshAhead))
+	}
+}
+
+// refreshBeforeExpire is called by the expiry index when item is about to expire
+func (l *Loader[Key, Value]) refreshBeforeExpire(key Key, item *cacheItem[Value], p *payload[Value]) {
+	// skip items that are no longer in the cache or haven't been used since the fetch
+	if iface, ok := l.driver.Get(l.driverKey(key)); !ok || iface != item {
+		return
+	}
+	if item.lastAccess.Load() < p.fetchedAt.UnixNano() {
+		return
+	}
+	if item.beginRefresh() {
+		l.scheduleRefetch(withTriggerReason(context.Background(), FetchForceRefresh), key, item)
+	}
+}
+
+// fetch calls the Fetcher, waiting for the rate limiter if there is one.
+// stale is the hard expired payload replaced by item, it's revalidated by NewRevalidating.
+func (l *Loader[Key, Value]) fetch(trigger context.Context, key Key, item *cacheItem[Value], stale *payload[Value]) (Value, *revalidation[Value], error) {
+	prev := item.payload.Load()
+	if prev == nil {
+		prev = stale
+	}
+	ctx, rv := l.revalidationContext(expiryHintContext(l.fetchContext(trigger, key, FetchColdMiss), item), prev)
+	if err := l.wait(ctx); err != nil {
+		return l.def, rv, err
+	}
+	value, err := l.callFetcher(ctx, key)
+	return value, rv, err
+}
+
+// fetchContext creates context for the fetcher of key annotated with reason, copying the values of WithContextValues from trigger
+func (l *Loader[Key, Value]) fetchContext(trigger context.Context, key Key, reason FetchReason) context.Context {
+	ctx := fetchReasonContext(l.keyCf(key), trigger, reason)
+	for _, k := range l.contextValues {
+		if value := trigger.Value(k); value != nil {
+			ctx = context.WithValue(ctx, k, value)
+		}
+	}
+	return ctx
+}
+
+// wait blocks until the rate limiter allows a fetch
+func (l *Loader[Key, Value]) wait(ctx context.Context) error {
+	if l.limiter == nil {
+		return nil
+	}
+	return l.limiter.Wait(ctx)
+}
+
 type cacheItem[Value any] struct {
-	value  Value
-	err    error
+	payload atomic.Pointer[payload[Value]]
+	// ready is closed once the first payload is stored
+	ready chan struct{}
+
+	// state is one of itemLoading, itemIdle, or itemRefreshing
+	state atomic.Int32
+
+	// hits counts how many times the item is served from cache
+	hits atomic.Uint64
+	// lastAccess is the unix nano time of the last cache hit
+	lastAccess atomic.Int64
+	// itemCost is computed by the cost function of WithCost
+	itemCost atomic.Int64
+	// debouncing is true while the first fetch waits for WithInvalidationDebounce
+	debouncing atomic.Bool
+	// expiryHint is the unix nano time of ExpireAt called by the current fetch, zero if it's not called
+	expiryHint atomic.Int64
+}
+
+// payload is the immutable content of cacheItem, it's swapped wholesale on refresh
+type payload[Value any] struct {
+	value Value
+	err   error
+	// expire is the soft expiry, after it the value is served stale while refreshing
 	expire time.Time
+	// hardExpire is the hard expiry, after it the value is never served, zero means never
+	hardExpire time.Time
+
+	// fetchedAt is the time when the fetch completed
+	fetchedAt time.Time
+	// hash is the hash of value when WithMutationCheck is enabled
+	hash uint64
+	// tag is the validator of NewRevalidating
+	tag string
+	// version is the number of payloads stored in the item, including this one
+	version uint64
+	// stamp is the version returned by FetcherVersioned, zero if there is none
+	stamp uint64
+}
+
+func newCacheItem[Value any]() *cacheItem[Value] {
+	return &cacheItem[Value]{ready: make(chan struct{})}
+}
+
+// load returns the current payload, waiting for the first fetch if needed.
+// It returns ctx error if ctx is done before the first fetch completes.
+func (i *cacheItem[Value]) load(ctx context.Context) (*payload[Value], error) {
+	if p := i.payload.Load(); p != nil {
+		return p, nil
+	}
+	select {
+	case <-i.ready:
+		return i.payload.Load(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newPayload creates the payload of a fetch result
+func (l *Loader[Key, Value]) newPayload(key Key, value Value, err error) *payload[Value] {
+	now := time.Now()
+	ttl := l.entryTTL(key, value, err)
+	if err == nil {
+		ttl = l.smear(now, ttl)
+	}
+	p := &payload[Value]{value: value, err: err, fetchedAt: now, expire: now.Add(ttl)}
+	if l.hardTTL > 0 && err == nil {
+		p.hardExpire = now.Add(l.hardTTL)
+	}
+	if l.mutationCheck && err == nil {
+		p.hash = hashValue(value)
+	}
+	return p
+}
+
+// store replaces the payload, the first store wakes up goroutines waiting in load
+func (i *cacheItem[Value]) store(p *payload[Value]) *payload[Value] {
+	if p.version == 0 {
+		p.version = 1
+		if prev := i.payload.Load(); prev != nil {
+			p.version = prev.version + 1
+		}
+	}
+	if i.payload.Swap(p) == nil {
+		i.state.Store(itemIdle)
+		close(i.ready)
+	}
+	return p
+}
 
-	mutex      sync.RWMutex
-	isFetching int32
+// cost implements costly
+func (i *cacheItem[Value]) cost() int64 {
+	if c := i.itemCost.Load(); c > 0 {
+		return c
+	}
+	return 1
 }
 
-func (i *cacheItem[Value]) updateExpire(ttl time.Duration) {
-	newExpire := time.Now().Add(ttl)
-	i.expire = newExpire
+// canRefresh reports whether at least minInterval has passed since the fetch
+func (p *payload[Value]) canRefresh(now time.Time, minInterval time.Duration) bool {
+	return minInterval <= 0 || now.Sub(p.fetchedAt) >= minInterval
 }
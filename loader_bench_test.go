@@ -0,0 +1,98 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// The hit path must stay within 0-1 allocs/op.
+// The only allowed allocation is boxing the key into interface{} for the cache driver.
+
+func benchmarkLoadHit[Key comparable](b *testing.B, l *Loader[Key, Key], key Key) {
+	l.Load(key)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Load(key)
+	}
+}
+
+func identity[Key comparable](ctx context.Context, key Key) (Key, error) {
+	return key, nil
+}
+
+func BenchmarkLoadHit(b *testing.B) {
+	b.Run("string", func(b *testing.B) {
+		benchmarkLoadHit(b, New(identity[string], time.Hour), "some-key")
+	})
+	b.Run("int", func(b *testing.B) {
+		benchmarkLoadHit(b, New(identity[int], time.Hour), 123456)
+	})
+	b.Run("lru", func(b *testing.B) {
+		benchmarkLoadHit(b, NewLRU(identity[string], time.Hour, 100), "some-key")
+	})
+	b.Run("sharded", func(b *testing.B) {
+		benchmarkLoadHit(b, New(identity[string], time.Hour, WithDriver(ShardedInMemoryCache(16))), "some-key")
+	})
+	b.Run("parallel", func(b *testing.B) {
+		l := New(identity[string], time.Hour)
+		l.Load("some-key")
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				l.Load("some-key")
+			}
+		})
+	})
+}
+
+func TestLoadHitAllocs(t *testing.T) {
+	l := New(identity[string], time.Hour)
+	l.Load("some-key")
+	allocs := testing.AllocsPerRun(100, func() {
+		l.Load("some-key")
+	})
+	if allocs > 1 {
+		t.Errorf("cache hit must allocate at most once, got %v allocs/op", allocs)
+	}
+}
+
+// Locking a key from the loader must not allocate, the lock items are pooled.
+// LockCtx still allocates the unlock closure it returns.
+func BenchmarkKeyLocker(b *testing.B) {
+	ctx := context.Background()
+	b.Run("LockCtx", func(b *testing.B) {
+		var locker InMemoryKeyLocker[string]
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			unlock, _ := locker.LockCtx(ctx, "some-key")
+			unlock()
+		}
+	})
+	b.Run("loader", func(b *testing.B) {
+		l := New(identity[string], time.Hour)
+		hk := l.hashedKey("some-key")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			held, _ := l.lockKey(ctx, "some-key", hk)
+			held.unlock()
+		}
+	})
+}
+
+func TestKeyLockerAllocs(t *testing.T) {
+	ctx := context.Background()
+	l := New(identity[string], time.Hour)
+	hk := l.hashedKey("some-key")
+	allocs := testing.AllocsPerRun(100, func() {
+		held, _ := l.lockKey(ctx, "some-key", hk)
+		held.unlock()
+	})
+	if allocs > 0 {
+		t.Errorf("locking a key must not allocate, got %v allocs/op", allocs)
+	}
+}
@@ -1,8 +1,13 @@
 package loader
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -119,3 +124,1856 @@ func TestExpire(t *testing.T) {
 	assert.Equal(t, "2 x", val, "Use updated value")
 	assert.Equal(t, int32(2), counter, "fetch called twice")
 }
+
+func TestMinRefreshInterval(t *testing.T) {
+	var counter int32
+	fetched := make(chan struct{}, 1)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		n := atomic.AddInt32(&counter, 1)
+		fetched <- struct{}{}
+		return fmt.Sprintf("%d %s", n, key), nil
+	}
+	l := New(fetch, 10*time.Millisecond, WithMinRefreshInterval(200*time.Millisecond))
+	val, _ := l.Load("x")
+	<-fetched
+	assert.Equal(t, "1 x", val, "First call")
+
+	time.Sleep(50 * time.Millisecond)
+	val, _ = l.Load("x")
+	assert.Equal(t, "1 x", val, "Expired but refresh is not allowed yet")
+	select {
+	case <-fetched:
+		t.Fatal("fetch must not be called before min interval")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	l.Load("x")
+	select {
+	case <-fetched:
+	case <-time.After(time.Second):
+		t.Fatal("refresh did not happen after min interval")
+	}
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("x")
+		return val == "2 x"
+	}, time.Second, time.Millisecond, "Refreshed after min interval")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter), "fetch must be called exactly twice")
+}
+
+type countingLimiter struct {
+	calls  int32
+	err    error
+	waited chan struct{}
+}
+
+func (c *countingLimiter) Wait(ctx context.Context) error {
+	atomic.AddInt32(&c.calls, 1)
+	err := c.err
+	select {
+	case c.waited <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+func TestRateLimiter(t *testing.T) {
+	fetched := make(chan struct{}, 1)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		fetched <- struct{}{}
+		return key, nil
+	}
+	limiter := &countingLimiter{waited: make(chan struct{}, 1)}
+	l := New(fetch, 20*time.Millisecond, WithRateLimiter(limiter))
+	val, err := l.Load("x")
+	<-fetched
+	<-limiter.waited
+	assert.NoError(t, err)
+	assert.Equal(t, "x", val)
+	l.Load("x")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&limiter.calls), "limiter must be called once per fetch")
+
+	// background refresh goes through the limiter too
+	time.Sleep(30 * time.Millisecond)
+	l.Load("x")
+	select {
+	case <-fetched:
+	case <-time.After(time.Second):
+		t.Fatal("background refresh did not happen")
+	}
+	<-limiter.waited
+	assert.Equal(t, int32(2), atomic.LoadInt32(&limiter.calls), "limiter must be called on background refresh")
+
+	// throttled background refresh keeps the stale value
+	limiter.err = fmt.Errorf("rate limited")
+	time.Sleep(30 * time.Millisecond)
+	val, err = l.Load("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", val)
+	select {
+	case <-limiter.waited:
+	case <-time.After(time.Second):
+		t.Fatal("background refresh did not wait for the limiter")
+	}
+	val, err = l.Load("x")
+	assert.NoError(t, err, "limiter error must not be cached")
+	assert.Equal(t, "x", val, "stale value must be kept")
+
+	// foreground miss returns the limiter error
+	_, err = l.Load("y")
+	assert.Equal(t, limiter.err, err, "limiter error must be returned")
+}
+
+func TestRefreshWorkersPriority(t *testing.T) {
+	var refreshing int32
+	var order []string
+	started := make(chan struct{})
+	block := make(chan struct{})
+	refreshed := make(chan string, 3)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		if atomic.LoadInt32(&refreshing) == 0 {
+			return key, nil
+		}
+		if key == "blocker" {
+			close(started)
+			<-block
+		}
+		refreshed <- key
+		return key, nil
+	}
+	l := New(fetch, 50*time.Millisecond, WithRefreshWorkers(1))
+	for _, key := range []string{"blocker", "cold", "hot"} {
+		l.Load(key)
+	}
+	for i := 0; i < 5; i++ {
+		l.Load("hot")
+	}
+	// wait until all items are expired
+	time.Sleep(60 * time.Millisecond)
+	atomic.StoreInt32(&refreshing, 1)
+
+	// occupy the only worker, then queue the others
+	l.Load("blocker")
+	<-started
+	l.Load("cold")
+	l.Load("hot")
+	close(block)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case key := <-refreshed:
+			order = append(order, key)
+		case <-time.After(time.Second):
+			t.Fatal("refresh did not complete")
+		}
+	}
+	assert.Equal(t, []string{"blocker", "hot", "cold"}, order, "hot key must be refreshed first")
+}
+
+func TestLoadWithInfo(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	l := NewLRU(fetch, time.Minute, 10)
+	val, info, err := l.LoadWithInfo("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", val)
+	assert.False(t, info.Cached, "first load must be fetched")
+	assert.Zero(t, info.Hits)
+
+	l.Load("x")
+	val, info, _ = l.LoadWithInfo("x")
+	assert.Equal(t, "x", val)
+	assert.True(t, info.Cached, "second load must be cached")
+	assert.False(t, info.Stale)
+	assert.Equal(t, uint64(2), info.Hits)
+	assert.WithinDuration(t, time.Now(), info.LastAccess, time.Second)
+
+	l.Load("y")
+	hits := map[string]uint64{}
+	err = l.Range(func(key string, value string, info Info) bool {
+		hits[key] = info.Hits
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"x": 2, "y": 0}, hits)
+}
+
+func TestHotKeyDetector(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	var hot []string
+	l := New(fetch, time.Minute, WithHotKeyDetector(3, time.Minute, func(key string) {
+		hot = append(hot, key)
+	}))
+	for i := 0; i < 5; i++ {
+		l.Load("x")
+	}
+	l.Load("y")
+	assert.Equal(t, []string{"x"}, hot, "callback must be called once for the hot key")
+}
+
+func TestHotKeyDetectorWindow(t *testing.T) {
+	var mutex sync.Mutex
+	hot := map[int]int{}
+	d := WithHotKeyDetector(2, time.Minute, func(key int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		hot[key]++
+	})
+	cfg := &config{}
+	d.apply(cfg)
+	detector := cfg.hotKey.(*hotKeyDetector[int])
+
+	for _, now := range []time.Time{time.Now(), time.Now().Add(time.Minute)} {
+		var wg sync.WaitGroup
+		for key := 0; key < 100; key++ {
+			wg.Add(1)
+			go func(key int) {
+				defer wg.Done()
+				detector.record(key, now)
+				detector.record(key, now)
+			}(key)
+		}
+		wg.Wait()
+	}
+	for key := 0; key < 100; key++ {
+		assert.Equal(t, 2, hot[key], "key must be hot once in each window")
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var counter int32
+	fetched := make(chan string, 4)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		n := atomic.AddInt32(&counter, 1)
+		fetched <- key
+		return fmt.Sprintf("%d %s", n, key), nil
+	}
+	l := New(fetch, 100*time.Millisecond, WithRefreshAhead(80*time.Millisecond))
+	l.Load("x")
+	l.Load("y")
+	<-fetched
+	<-fetched
+
+	// only x is accessed after fetch
+	l.Load("x")
+	select {
+	case key := <-fetched:
+		assert.Equal(t, "x", key, "accessed key must be refreshed ahead")
+	case <-time.After(time.Second):
+		t.Fatal("refresh ahead did not happen")
+	}
+	assert.Eventually(t, func() bool {
+		_, info, _ := l.LoadWithInfo("x")
+		return !info.Stale && info.Hits >= 1 && l.expiry.len() == 1
+	}, time.Second, time.Millisecond)
+	select {
+	case key := <-fetched:
+		assert.NotEqual(t, "y", key, "unused key must not be refreshed ahead")
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestCostBoundedCache(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	strlen := func(value string) int64 { return int64(len(value)) }
+	l := New(fetch, time.Minute, WithDriver(BoundedInMemoryCache(10)), WithCost(strlen))
+	for _, key := range []string{"aaaa", "bbbb", "cc"} {
+		l.Load(key)
+	}
+	assert.Equal(t, int64(10), l.Stats().Cost)
+
+	l.Load("dddd")
+	assert.Equal(t, int64(10), l.Stats().Cost, "oldest item must be evicted")
+	_, info, _ := l.LoadWithInfo("bbbb")
+	assert.True(t, info.Cached)
+	_, info, _ = l.LoadWithInfo("aaaa")
+	assert.False(t, info.Cached, "evicted item must be fetched again")
+
+	stats := l.Stats()
+	assert.Equal(t, uint64(5), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Hits)
+}
+
+func TestNamespace(t *testing.T) {
+	driver := InMemoryCache()
+	users := New(func(ctx context.Context, key int) (string, error) {
+		return fmt.Sprint("user ", key), nil
+	}, time.Minute, WithDriver(driver), WithNamespace("users"))
+	groups := New(func(ctx context.Context, key int) (string, error) {
+		return fmt.Sprint("group ", key), nil
+	}, time.Minute, WithDriver(driver), WithNamespace("groups"))
+
+	val, _ := users.Load(1)
+	assert.Equal(t, "user 1", val)
+	val, _ = groups.Load(1)
+	assert.Equal(t, "group 1", val, "same key in other namespace must not collide")
+
+	assert.NoError(t, users.InvalidateAll())
+	_, info, _ := users.LoadWithInfo(1)
+	assert.False(t, info.Cached, "invalidated item must be fetched again")
+	_, info, _ = groups.LoadWithInfo(1)
+	assert.True(t, info.Cached, "other namespace must not be invalidated")
+
+	assert.NoError(t, groups.Invalidate(1))
+	_, info, _ = groups.LoadWithInfo(1)
+	assert.False(t, info.Cached)
+}
+
+func TestKeyMapper(t *testing.T) {
+	var counter int32
+	fetch := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&counter, 1)
+		return key, nil
+	}
+	l := New(fetch, time.Minute, WithKeyMapper(strings.ToLower))
+	val, _ := l.Load("Key")
+	assert.Equal(t, "key", val, "fetcher must receive normalized key")
+	val, _ = l.Load("KEY")
+	assert.Equal(t, "key", val)
+	assert.Equal(t, int32(1), counter, "normalized keys must share the cache item")
+
+	l.Invalidate("kEy")
+	l.Load("key")
+	assert.Equal(t, int32(2), counter, "invalidation must use normalized key")
+}
+
+func TestCompositeKey(t *testing.T) {
+	fetch := func(ctx context.Context, tenant string, id int) (string, error) {
+		return fmt.Sprintf("%s/%d", tenant, id), nil
+	}
+	l := New(Fetcher2(fetch), time.Minute)
+	val, _ := l.Load(K2("acme", 1))
+	assert.Equal(t, "acme/1", val)
+	val, _ = l.Load(K2("other", 1))
+	assert.Equal(t, "other/1", val)
+	_, info, _ := l.LoadWithInfo(K2("acme", 1))
+	assert.True(t, info.Cached)
+	assert.Equal(t, "a:b:3", K3("a", "b", 3).String())
+}
+
+type tenantKey struct{}
+
+func TestContextValues(t *testing.T) {
+	fetched := make(chan string, 2)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		fetched <- tenant
+		return tenant + "/" + key, nil
+	}
+	l := New(fetch, 20*time.Millisecond, WithContextValues(tenantKey{}))
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	val, _ := l.LoadCtx(ctx, "x")
+	assert.Equal(t, "acme/x", val)
+	assert.Equal(t, "acme", <-fetched)
+
+	time.Sleep(30 * time.Millisecond)
+	l.LoadCtx(ctx, "x")
+	select {
+	case tenant := <-fetched:
+		assert.Equal(t, "acme", tenant, "background refresh must receive the context values")
+	case <-time.After(time.Second):
+		t.Fatal("background refresh did not happen")
+	}
+}
+
+func TestClose(t *testing.T) {
+	var counter int32
+	started := make(chan struct{}, 1)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		if atomic.AddInt32(&counter, 1) == 1 {
+			return key, nil
+		}
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	l := New(fetch, 10*time.Millisecond)
+	l.Load("x")
+	time.Sleep(20 * time.Millisecond)
+	l.Load("x")
+	<-started
+
+	assert.NoError(t, l.Close())
+	l.Wait()
+	val, err := l.Load("x")
+	assert.NoError(t, err, "cancelled refresh must not be cached")
+	assert.Equal(t, "x", val)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter), "closed loader must not refresh in background")
+}
+
+func TestDeadlineFallback(t *testing.T) {
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, key string) (string, error) {
+		<-release
+		return key, nil
+	}
+	l := New(fetch, time.Minute, WithDeadlineFallback())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := l.LoadCtx(ctx, "x")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "without stale value, the deadline error must be returned")
+
+	close(release)
+	val, info, err := l.LoadWithInfo("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", val, "fetch must continue in background")
+	assert.True(t, info.Cached)
+}
+
+func TestColdStartTimeout(t *testing.T) {
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, key string) (string, error) {
+		<-release
+		return key, nil
+	}
+	l := New(fetch, time.Minute, WithColdStartTimeout(20*time.Millisecond, "loading"))
+	val, info, err := l.LoadWithInfo("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "loading", val, "placeholder must be returned on slow cold start")
+	assert.True(t, info.Placeholder)
+
+	close(release)
+	val, info, _ = l.LoadWithInfo("x")
+	assert.Equal(t, "x", val, "fetch must continue in background")
+	assert.True(t, info.Cached)
+}
+
+func TestItemState(t *testing.T) {
+	var counter int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, key string) (string, error) {
+		switch atomic.AddInt32(&counter, 1) {
+		case 1:
+			return key, nil
+		case 2:
+			<-release
+			panic("boom")
+		default:
+			return key, nil
+		}
+	}
+	l := New(fetch, 20*time.Millisecond)
+	_, info, _ := l.LoadWithInfo("x")
+	assert.Equal(t, StateFresh, info.State)
+
+	time.Sleep(30 * time.Millisecond)
+	_, info, _ = l.LoadWithInfo("x")
+	assert.Equal(t, StateRefreshing, info.State)
+
+	// the panicking refresh is converted into error and the item is refreshable again
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, info, _ := l.LoadWithInfo("x")
+		return info.State == StateError
+	}, time.Second, time.Millisecond)
+	_, _, err := l.LoadWithInfo("x")
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+
+	time.Sleep(30 * time.Millisecond)
+	l.Load("x")
+	assert.Eventually(t, func() bool {
+		val, info, _ := l.LoadWithInfo("x")
+		return val == "x" && info.State == StateFresh
+	}, time.Second, time.Millisecond, "item must recover after the panic")
+}
+
+func TestHardTTL(t *testing.T) {
+	var counter int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, key string) (string, error) {
+		n := atomic.AddInt32(&counter, 1)
+		if n == 3 {
+			<-release
+		}
+		return fmt.Sprintf("%d %s", n, key), nil
+	}
+	// min refresh interval disables background refresh, so only the hard TTL triggers fetch
+	l := New(fetch, 10*time.Millisecond, WithHardTTL(30*time.Millisecond),
+		WithMinRefreshInterval(time.Hour), WithDeadlineFallback())
+	l.Load("x")
+
+	time.Sleep(20 * time.Millisecond)
+	val, info, _ := l.LoadWithInfo("x")
+	assert.Equal(t, "1 x", val, "stale value is served before hard TTL")
+	assert.True(t, info.Stale)
+
+	time.Sleep(20 * time.Millisecond)
+	val, info, _ = l.LoadWithInfo("x")
+	assert.Equal(t, "2 x", val, "value past hard TTL must be fetched in foreground")
+	assert.False(t, info.Cached)
+
+	// when the foreground fetch exceeds the deadline, the stale value is the fallback
+	time.Sleep(40 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	val, info, err := l.LoadWithInfoCtx(ctx, "x")
+	assert.NoError(t, err)
+	assert.Equal(t, "2 x", val)
+	assert.True(t, info.Fallback)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("x")
+		return val == "3 x"
+	}, time.Second, time.Millisecond)
+}
+
+func TestInvalidationDebounce(t *testing.T) {
+	var counter int32
+	fetch := func(ctx context.Context, key string) (string, error) {
+		n := atomic.AddInt32(&counter, 1)
+		return fmt.Sprintf("%d %s", n, key), nil
+	}
+	l := New(fetch, time.Minute, WithInvalidationDebounce(30*time.Millisecond))
+	l.Load("x")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		l.Invalidate("x")
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				val, _ := l.Load("x")
+				assert.Equal(t, "2 x", val)
+			}()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&counter), "burst of invalidations must be repopulated by one fetch")
+
+	// an invalidation between the claim of a miss and its fetch must see the item debouncing
+	iface, _, miss, err := l.claim(context.Background(), "y")
+	assert.NoError(t, err)
+	assert.True(t, miss)
+	assert.True(t, iface.(*cacheItem[string]).debouncing.Load(), "item must be debouncing once it's published")
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	var attempts int32
+	fetch := func(ctx context.Context, key string) (string, error) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return "", fmt.Errorf("temporary error")
+		}
+		return key, nil
+	}
+	logging := func(name string) Middleware[string, string] {
+		return func(next Fetcher[string, string]) Fetcher[string, string] {
+			return func(ctx context.Context, key string) (string, error) {
+				calls = append(calls, name)
+				return next(ctx, key)
+			}
+		}
+	}
+	l := New(fetch, time.Minute, WithMiddleware(logging("outer"), Retry[string, string](3, time.Millisecond), logging("inner")))
+	val, err := l.Load("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", val)
+	assert.Equal(t, []string{"outer", "inner", "inner", "inner"}, calls)
+}
+
+func TestTypedOptions(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		if key == "bad" {
+			return "", fmt.Errorf("bad key")
+		}
+		return key, nil
+	}
+	l := New(fetch, time.Minute,
+		WithDefault[string]("default"),
+		WithTransform(func(key string, value string) string { return strings.ToUpper(value) }),
+		WithTTLFunc(func(key string, value string, err error) time.Duration {
+			if key == "short" {
+				return time.Nanosecond
+			}
+			return time.Minute
+		}),
+	)
+	val, err := l.Load("bad")
+	assert.Error(t, err)
+	assert.Equal(t, "default", val)
+
+	val, _ = l.Load("x")
+	assert.Equal(t, "X", val)
+	_, info, _ := l.LoadWithInfo("short")
+	assert.WithinDuration(t, info.FetchedAt, info.Expire, time.Millisecond)
+
+	assert.Panics(t, func() {
+		New(fetch, time.Minute, WithDefault[string](1))
+	}, "mismatched typed option must panic")
+}
+
+type clonable struct {
+	tags []string
+}
+
+func (c *clonable) Clone() *clonable {
+	return &clonable{tags: append([]string(nil), c.tags...)}
+}
+
+func TestCloner(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (*clonable, error) {
+		return &clonable{tags: []string{key}}, nil
+	}
+	for name, option := range map[string]Option{
+		"cloner": WithCloner(func(v *clonable) *clonable { return v.Clone() }),
+		"method": WithCloneMethod(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := New(fetch, time.Minute, option)
+			val, _ := l.Load("x")
+			val.tags[0] = "mutated"
+			val, _ = l.Load("x")
+			assert.Equal(t, []string{"x"}, val.tags, "mutating returned value must not affect the cache")
+		})
+	}
+}
+
+func TestMutationCheck(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (map[string][]int, error) {
+		return map[string][]int{"a": {1, 2}, "b": {3}}, nil
+	}
+	var errs []error
+	l := New(fetch, time.Minute, WithMutationCheck(func(err error) {
+		errs = append(errs, err)
+	}))
+	val, _ := l.Load("x")
+	l.Load("x")
+	assert.Empty(t, errs, "unmodified value must pass the check")
+
+	val["a"][0] = 100
+	l.Load("x")
+	var mutationErr *MutationError
+	if assert.Len(t, errs, 1) && assert.ErrorAs(t, errs[0], &mutationErr) {
+		assert.Equal(t, "x", mutationErr.Key)
+	}
+
+	panicking := New(fetch, time.Minute, WithMutationCheck(nil))
+	val, _ = panicking.Load("x")
+	val["b"] = nil
+	assert.Panics(t, func() { panicking.Load("x") })
+}
+
+func TestTenants(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	tenantOf := func(key string) string { return strings.SplitN(key, "/", 2)[0] }
+	l := New(fetch, time.Minute, WithTenants(tenantOf, 2))
+
+	l.Load("quiet/1")
+	for i := 0; i < 10; i++ {
+		l.Load(fmt.Sprint("noisy/", i))
+	}
+	_, info, _ := l.LoadWithInfo("quiet/1")
+	assert.True(t, info.Cached, "noisy tenant must not evict other tenant")
+	assert.Equal(t, int64(3), l.Stats().Cost)
+
+	stats := l.TenantStats()
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Cost: 1}, stats["quiet"])
+	assert.Equal(t, Stats{Hits: 0, Misses: 10, Cost: 2}, stats["noisy"])
+
+	_, err := NewE(fetch, time.Minute, WithTenants(tenantOf, 2), WithDriver(InMemoryCache()))
+	assert.ErrorIs(t, err, ErrInvalidConfig, "WithTenants must not silently drop the driver")
+	_, err = NewLRUE(fetch, time.Minute, 10, WithTenants(tenantOf, 2))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestMaxValueSize(t *testing.T) {
+	fetches := 0
+	fetch := func(ctx context.Context, key int) (string, error) {
+		fetches++
+		return strings.Repeat("x", key), nil
+	}
+	strlen := func(value string) int64 { return int64(len(value)) }
+	l := New(fetch, time.Minute, WithCost(strlen), WithMaxValueSize(5))
+
+	val, _ := l.Load(10)
+	assert.Equal(t, strings.Repeat("x", 10), val, "oversized value must be returned")
+	val, info, _ := l.LoadWithInfo(10)
+	assert.Equal(t, strings.Repeat("x", 10), val)
+	assert.False(t, info.Cached, "oversized value must not be cached")
+	l.Load(3)
+	_, info, _ = l.LoadWithInfo(3)
+	assert.True(t, info.Cached)
+	assert.Equal(t, 3, fetches)
+	assert.Equal(t, uint64(2), l.Stats().Oversized)
+
+	assert.Panics(t, func() { New(fetch, time.Minute, WithMaxValueSize(5)) })
+}
+
+func TestAdmissionPolicy(t *testing.T) {
+	fetches := 0
+	fetch := func(ctx context.Context, key int) (string, error) {
+		fetches++
+		return strings.Repeat("x", key), nil
+	}
+	strlen := func(value string) int64 { return int64(len(value)) }
+
+	l := New(fetch, time.Minute, WithCost(strlen), WithAdmissionPolicy(SizeThreshold(5)))
+	val, _ := l.Load(10)
+	assert.Equal(t, strings.Repeat("x", 10), val, "rejected value must be returned")
+	_, info, _ := l.LoadWithInfo(10)
+	assert.False(t, info.Cached, "rejected value must not be cached")
+	l.Load(3)
+	_, info, _ = l.LoadWithInfo(3)
+	assert.True(t, info.Cached)
+	assert.Equal(t, 3, fetches)
+	assert.Equal(t, uint64(2), l.Stats().Rejected)
+
+	l = New(fetch, time.Minute, WithAdmissionPolicy(TinyLFU(100, 3)))
+	for i := 1; i <= 3; i++ {
+		_, info, _ = l.LoadWithInfo(1)
+		assert.False(t, info.Cached, "key must not be cached until it's fetched 3 times")
+	}
+	_, info, _ = l.LoadWithInfo(1)
+	assert.True(t, info.Cached)
+
+	l = New(fetch, time.Minute, WithAdmissionPolicy(AdmitAlways))
+	l.Load(1)
+	_, info, _ = l.LoadWithInfo(1)
+	assert.True(t, info.Cached)
+	assert.Zero(t, l.Stats().Rejected)
+}
+
+func TestZeroValuesAndNotFound(t *testing.T) {
+	fetches := map[int]int{}
+	fetch := func(ctx context.Context, key int) (string, error) {
+		fetches[key]++
+		switch key {
+		case 0:
+			return "", nil
+		case 404:
+			return "", fmt.Errorf("user %d: %w", key, ErrNotFound)
+		}
+		return "", fmt.Errorf("origin is down")
+	}
+	l := New(fetch, time.Minute, WithCacheZeroValues(false), WithErrorTTL(time.Millisecond), WithNotFoundTTL(time.Minute))
+
+	l.Load(0)
+	_, info, err := l.LoadWithInfo(0)
+	assert.NoError(t, err)
+	assert.False(t, info.Cached, "zero value must not be cached")
+	assert.Equal(t, 2, fetches[0])
+
+	l.Load(404)
+	l.Load(500)
+	time.Sleep(5 * time.Millisecond)
+	_, err = l.Load(404)
+	assert.ErrorIs(t, err, ErrNotFound)
+	l.Load(500)
+	l.Wait()
+	assert.Equal(t, 1, fetches[404], "not found must be cached with its own ttl")
+	assert.Equal(t, 2, fetches[500], "other errors must be cached with the error ttl")
+}
+
+func TestHealthy(t *testing.T) {
+	var fail atomic.Bool
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if fail.Load() {
+			return "", fmt.Errorf("origin is down")
+		}
+		return key, nil
+	}, time.Millisecond, WithRefreshErrorThreshold(0.5, 4))
+
+	l.Load("a")
+	assert.NoError(t, l.Healthy(context.Background()))
+
+	fail.Store(true)
+	time.Sleep(2 * time.Millisecond)
+	l.Load("a")
+	l.Wait()
+	assert.ErrorIs(t, l.Healthy(context.Background()), ErrUnhealthy)
+}
+
+func TestEntryEnvelope(t *testing.T) {
+	driver := InMemoryCache()
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}, 50*time.Millisecond, WithDriver(driver))
+
+	l.Load("a")
+	stored, _ := driver.Get(l.driverKey("a"))
+	envelope, ok := stored.(Envelope)
+	assert.True(t, ok, "stored item must implement Envelope")
+	meta, ok := envelope.EntryMeta()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), meta.Version)
+	assert.NoError(t, meta.Err)
+	assert.WithinDuration(t, meta.FetchedAt.Add(50*time.Millisecond), meta.Expire, 0)
+	value, _ := envelope.EntryValue()
+	assert.Equal(t, "value a", value)
+
+	time.Sleep(60 * time.Millisecond)
+	l.Load("a")
+	l.Wait()
+	val, meta, ok := InspectEntry[string](stored)
+	assert.True(t, ok)
+	assert.Equal(t, "value a", val)
+	assert.Equal(t, uint64(2), meta.Version, "version must be incremented by refresh")
+
+	_, _, ok = InspectEntry[int](stored)
+	assert.False(t, ok, "value type must match")
+}
+
+type slowDriver struct {
+	CacheDriver
+	delay chan struct{}
+}
+
+func (d *slowDriver) Get(key interface{}) (interface{}, bool) {
+	<-d.delay
+	return d.CacheDriver.Get(key)
+}
+
+func TestDriverTimeout(t *testing.T) {
+	driver := &slowDriver{CacheDriver: InMemoryCache(), delay: make(chan struct{})}
+	defer close(driver.delay)
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithDriver(driver), WithDriverTimeout(time.Millisecond))
+
+	val, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", val)
+	assert.Equal(t, uint64(2), l.Stats().DriverTimeouts)
+	assert.Equal(t, uint64(1), l.Stats().Misses)
+}
+
+type droppingDriver struct{}
+
+func (droppingDriver) Add(key interface{}, value interface{})  {}
+func (droppingDriver) Get(key interface{}) (interface{}, bool) { return nil, false }
+
+func TestPendingFetchWithoutDriver(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var fetches int32
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			close(started)
+			<-release
+		}
+		return key, nil
+	}, time.Minute, WithDriver(droppingDriver{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, _ := l.Load("a")
+			assert.Equal(t, "a", val)
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+	// the second load counts as a hit once it joins the pending fetch
+	assert.Eventually(t, func() bool { return l.Stats().Hits == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "concurrent loads must share the fetch even if the driver drops it")
+
+	l.Load("a")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "finished fetch must not be shared")
+}
+
+func TestPendingFetchWaitCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	l := New(func(ctx context.Context, key string) (string, error) {
+		close(started)
+		<-release
+		return key, nil
+	}, time.Minute)
+
+	go l.Load("a")
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() {
+		_, err := l.LoadCtx(ctx, "a")
+		waited <- err
+	}()
+	assert.Eventually(t, func() bool { return l.Stats().Hits == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-waited, context.Canceled, "waiter must stop waiting when its context is done")
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("a")
+		return val == "a"
+	}, time.Second, time.Millisecond)
+}
+
+func TestWaitCancelFallback(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		return fmt.Sprint(key, atomic.LoadInt32(&fetches)), nil
+	}, time.Millisecond, WithHardTTL(time.Millisecond), WithDeadlineFallback())
+	l.Load("a")
+	time.Sleep(2 * time.Millisecond)
+
+	// the leader replaces the hard expired item and hangs
+	go l.Load("a")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	val, info, err := l.LoadWithInfoCtx(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", val, "waiter must get the stale value when its context is done")
+	assert.True(t, info.Fallback)
+	close(release)
+
+	// without WithDeadlineFallback, the leader stops waiting with the context error
+	hang := make(chan struct{})
+	defer close(hang)
+	l = New(func(ctx context.Context, key string) (string, error) {
+		<-hang
+		return key, nil
+	}, time.Minute)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.LoadCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGenerations(t *testing.T) {
+	var fetches int32
+	version := "v1"
+	var buildErr error
+	build := func(ctx context.Context) (map[string]string, error) {
+		if buildErr != nil {
+			return nil, buildErr
+		}
+		return map[string]string{"a": "a " + version, "b": "b " + version}, nil
+	}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return key + " fetched", nil
+	}, time.Minute, WithGenerations(build, 0))
+
+	assert.NoError(t, l.Rebuild(context.Background()))
+	val, _ := l.Load("a")
+	assert.Equal(t, "a v1", val)
+	val, _ = l.Load("c")
+	assert.Equal(t, "c fetched", val, "keys out of the generation must be fetched")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	version = "v2"
+	assert.NoError(t, l.Rebuild(context.Background()))
+	val, _ = l.Load("a")
+	assert.Equal(t, "a v2", val)
+
+	buildErr = fmt.Errorf("config server is down")
+	assert.Error(t, l.Rebuild(context.Background()))
+	val, _ = l.Load("b")
+	assert.Equal(t, "b v2", val, "failed rebuild must keep the previous generation")
+	assert.ErrorIs(t, l.Healthy(context.Background()), ErrUnhealthy)
+}
+
+func TestFetchAll(t *testing.T) {
+	var fetches, preloads int32
+	l := New(func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return key + " fetched", nil
+	}, time.Minute, WithFetchAll(func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&preloads, 1)
+		return map[string]string{"a": "a preloaded", "b": "b preloaded"}, nil
+	}, 0))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&preloads) == 1 }, time.Second, time.Millisecond, "cache must be preloaded at startup")
+	l.Wait()
+
+	val, info, _ := l.LoadWithInfo("a")
+	assert.Equal(t, "a preloaded", val)
+	assert.True(t, info.Cached)
+	val, _ = l.Load("z")
+	assert.Equal(t, "z fetched", val, "misses must use the fetcher")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	assert.NoError(t, l.WarmUp(context.Background()))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&preloads))
+}
+
+func TestPeriodicRefresh(t *testing.T) {
+	var fetches int32
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return fmt.Sprint(key, atomic.AddInt32(&fetches, 1)), nil
+	}, time.Hour, WithPeriodicRefresh(5*time.Millisecond, 2))
+	defer l.Close()
+
+	val, _ := l.Load("a")
+	assert.Equal(t, "a1", val)
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("a")
+		return val != "a1"
+	}, time.Second, time.Millisecond, "fresh item must be refreshed periodically")
+
+	assert.Panics(t, func() {
+		New(func(ctx context.Context, key string) (string, error) { return key, nil }, time.Hour,
+			WithDriver(droppingDriver{}), WithPeriodicRefresh(time.Minute, 1))
+	})
+}
+
+func TestInlineRefresh(t *testing.T) {
+	var fetches int32
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return fmt.Sprint(key, atomic.AddInt32(&fetches, 1)), nil
+	}
+	l := New(fetch, 20*time.Millisecond, WithInlineRefresh())
+
+	val, _ := l.Load("a")
+	assert.Equal(t, "a1", val)
+	time.Sleep(30 * time.Millisecond)
+
+	val, _ = l.Load("a")
+	assert.Equal(t, "a1", val, "the caller that finds the item expired must get the stale value")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "refresh must not run in background")
+
+	val, _ = l.Load("b")
+	assert.Equal(t, "b2", val)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches), "the next Load must run the queued refresh before it returns")
+	val, _ = l.Load("a")
+	assert.Equal(t, "a3", val)
+
+	time.Sleep(30 * time.Millisecond)
+	l.Load("a")
+	assert.Equal(t, 1, l.RunRefreshes())
+	assert.Equal(t, 0, l.RunRefreshes())
+	val, _ = l.Load("a")
+	assert.Equal(t, "a4", val)
+
+	time.Sleep(30 * time.Millisecond)
+	l.Load("a")
+	l.Close()
+	waited := make(chan struct{})
+	go func() {
+		l.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("Wait must not block on refreshes queued before Close")
+	}
+
+	_, err := NewE(fetch, time.Minute, WithInlineRefresh(), WithRefreshWorkers(2))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestMaxRefetchGoroutines(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	fetched := map[string]bool{}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		mutex.Lock()
+		refresh := fetched[key]
+		fetched[key] = true
+		mutex.Unlock()
+		if refresh {
+			started <- key
+			<-release
+		}
+		return key, nil
+	}, 20*time.Millisecond, WithMaxRefetchGoroutines(1))
+	defer l.Close()
+
+	l.Load("a")
+	l.Load("b")
+	time.Sleep(30 * time.Millisecond)
+
+	l.Load("a")
+	assert.Equal(t, "a", <-started)
+	assert.Equal(t, int64(1), l.Stats().RefetchGoroutines)
+
+	l.Load("a")
+	assert.Equal(t, uint64(1), l.Stats().RefreshesCoalesced)
+
+	val, _ := l.Load("b")
+	assert.Equal(t, "b", val, "stale value must be served beyond the cap")
+	assert.Equal(t, uint64(1), l.Stats().RefreshesSkipped)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return l.Stats().RefetchGoroutines == 0
+	}, time.Second, time.Millisecond)
+	l.Load("b")
+	assert.Equal(t, "b", <-started, "refresh must be retried once capacity frees")
+}
+
+func TestKeyContextFactory(t *testing.T) {
+	type ctxKey struct{}
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return fmt.Sprint(ctx.Value(ctxKey{})), nil
+	}
+
+	l := New(fetch, time.Minute, WithContextFactory(func() context.Context {
+		return context.WithValue(context.Background(), ctxKey{}, "static")
+	}))
+	val, _ := l.Load("a")
+	assert.Equal(t, "static", val, "ContextFactory without key must keep working")
+
+	l = New(fetch, time.Minute, WithKeyContextFactory(func(key string) context.Context {
+		return context.WithValue(context.Background(), ctxKey{}, "shard-"+key)
+	}))
+	val, _ = l.Load("a")
+	assert.Equal(t, "shard-a", val)
+
+	_, err := NewE(fetch, time.Minute, WithKeyContextFactory(func(key int) context.Context {
+		return context.Background()
+	}))
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestDependencies(t *testing.T) {
+	// each key depends on its parent path, "a/b/c" depends on "a/b"
+	parent := func(key string, value string) []string {
+		if i := strings.LastIndexByte(key, '/'); i > 0 {
+			return []string{key[:i]}
+		}
+		return nil
+	}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithDependencies(parent))
+	for _, key := range []string{"a", "a/b", "a/b/c", "x/y"} {
+		l.Load(key)
+	}
+
+	assert.NoError(t, l.Invalidate("a/b"))
+	cached := func(key string) bool {
+		_, info, _ := l.LoadWithInfo(key)
+		return info.Cached
+	}
+	assert.True(t, cached("a"), "dependency must not be invalidated")
+	assert.False(t, cached("a/b"))
+	assert.False(t, cached("a/b/c"), "dependent must be invalidated")
+	assert.True(t, cached("x/y"))
+
+	// the dependencies are recorded again by the new fetch
+	assert.NoError(t, l.Invalidate("a"))
+	assert.False(t, cached("a/b/c"), "invalidation must cascade through dependents")
+}
+
+func TestDependenciesEvicted(t *testing.T) {
+	fetchErr := errors.New("fetch failed")
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if key == "broken" {
+			return "", fetchErr
+		}
+		return key, nil
+	}, time.Minute, WithDriver(BoundedInMemoryCache(2)), WithDependencies(func(key string, value string) []string {
+		return []string{"parent"}
+	}))
+	defer l.Close()
+
+	l.Load("a")
+	l.Load("broken")
+	l.deps.mutex.Lock()
+	assert.Len(t, l.deps.dependencies, 1, "errors must not have dependencies")
+	l.deps.mutex.Unlock()
+
+	// adding more keys than the cache holds evicts the older ones
+	for _, key := range []string{"b", "c", "d"} {
+		l.Load(key)
+	}
+	l.deps.mutex.Lock()
+	defer l.deps.mutex.Unlock()
+	assert.Len(t, l.deps.dependencies, 2, "the edges of evicted keys must be removed")
+	assert.Len(t, l.deps.dependents["parent"], 2)
+}
+
+func TestRevalidatingFetcher(t *testing.T) {
+	var prevTags []string
+	var mutex sync.Mutex
+	fetched := make(chan struct{}, 10)
+	version := 1
+	l := NewRevalidating(func(ctx context.Context, key string, prev *Entry[string]) (*Entry[string], error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		defer func() { fetched <- struct{}{} }()
+		tag := fmt.Sprint("v", version)
+		if prev != nil {
+			prevTags = append(prevTags, prev.Tag)
+			if prev.Tag == tag {
+				return nil, ErrNotModified
+			}
+		}
+		return &Entry[string]{Value: key + " " + tag, Tag: tag}, nil
+	}, 50*time.Millisecond, WithTransform(func(key string, value string) string {
+		return strings.ToUpper(value)
+	}))
+
+	val, info, _ := l.LoadWithInfo("a")
+	<-fetched
+	assert.Equal(t, "A V1", val)
+	firstFetch := info.FetchedAt
+
+	time.Sleep(60 * time.Millisecond)
+	l.Load("a")
+	<-fetched
+	l.Wait()
+	val, info, _ = l.LoadWithInfo("a")
+	assert.Equal(t, "A V1", val, "not modified value must be kept without transforming it again")
+	assert.Equal(t, StateFresh, info.State, "not modified must renew the TTL")
+	assert.True(t, info.FetchedAt.After(firstFetch))
+
+	mutex.Lock()
+	version = 2
+	mutex.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	l.Load("a")
+	<-fetched
+	l.Wait()
+	val, _ = l.Load("a")
+	assert.Equal(t, "A V2", val)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"v1", "v1"}, prevTags)
+}
+
+func TestRevalidatingFetcherHardExpired(t *testing.T) {
+	var prevs []*Entry[string]
+	l := NewRevalidating(func(ctx context.Context, key string, prev *Entry[string]) (*Entry[string], error) {
+		prevs = append(prevs, prev)
+		if key == "nil" {
+			return nil, nil
+		}
+		if prev != nil {
+			return nil, ErrNotModified
+		}
+		return &Entry[string]{Value: "value", Tag: "v1"}, nil
+	}, 10*time.Millisecond, WithHardTTL(20*time.Millisecond))
+
+	val, err := l.Load("nil")
+	assert.NoError(t, err, "nil entry must not panic")
+	assert.Equal(t, "", val)
+
+	l.Load("a")
+	time.Sleep(30 * time.Millisecond)
+	val, _ = l.Load("a")
+	assert.Equal(t, "value", val)
+	if assert.Len(t, prevs, 3) && assert.NotNil(t, prevs[2], "hard expired entry must be revalidated") {
+		assert.Equal(t, "v1", prevs[2].Tag)
+	}
+}
+
+func TestDeltaFetcher(t *testing.T) {
+	var mutex sync.Mutex
+	events := []string{"a", "b"}
+	var fulls, deltas int32
+	full := func(ctx context.Context, key string, prev *Entry[[]string]) (*Entry[[]string], error) {
+		atomic.AddInt32(&fulls, 1)
+		mutex.Lock()
+		defer mutex.Unlock()
+		return &Entry[[]string]{Value: append([]string(nil), events...), Tag: fmt.Sprint(len(events))}, nil
+	}
+	delta := func(ctx context.Context, key string, prev *Entry[[]string]) ([]string, string, error) {
+		atomic.AddInt32(&deltas, 1)
+		mutex.Lock()
+		defer mutex.Unlock()
+		var since int
+		fmt.Sscan(prev.Tag, &since)
+		if since == len(events) {
+			return nil, "", ErrNotModified
+		}
+		return events[since:], fmt.Sprint(len(events)), nil
+	}
+	merge := func(prev []string, delta []string) []string {
+		return append(append([]string(nil), prev...), delta...)
+	}
+	l := NewDelta(full, delta, merge, 50*time.Millisecond)
+
+	val, _ := l.Load("log")
+	assert.Equal(t, []string{"a", "b"}, val)
+
+	mutex.Lock()
+	events = append(events, "c")
+	mutex.Unlock()
+	time.Sleep(60 * time.Millisecond)
+	l.Load("log")
+	l.Wait()
+	val, _ = l.Load("log")
+	assert.Equal(t, []string{"a", "b", "c"}, val)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fulls), "refresh must fetch the delta only")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&deltas))
+}
+
+func TestGetAlias(t *testing.T) {
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}, time.Minute)
+
+	val, err := l.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", val)
+	val, err = l.GetCtx(context.Background(), "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "value b", val)
+	assert.Equal(t, uint64(2), l.Stats().Misses)
+}
+
+func TestNewE(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	l, err := NewE(fetch, time.Minute, WithHardTTL(time.Hour))
+	assert.NoError(t, err)
+	val, _ := l.Load("a")
+	assert.Equal(t, "a", val)
+
+	invalid := map[string]func() error{
+		"nil fetcher":    func() error { _, err := NewE[string, string](nil, time.Minute); return err },
+		"negative ttl":   func() error { _, err := NewE(fetch, -time.Minute); return err },
+		"nil driver":     func() error { _, err := NewE(fetch, time.Minute, WithDriver(nil)); return err },
+		"short hard ttl": func() error { _, err := NewE(fetch, time.Minute, WithHardTTL(time.Second)); return err },
+		"type mismatch":  func() error { _, err := NewE(fetch, time.Minute, WithCloner(func(v int) int { return v })); return err },
+		"no remover": func() error {
+			_, err := NewE(fetch, time.Minute, WithDriver(droppingDriver{}), WithCacheZeroValues(false))
+			return err
+		},
+		"lru size": func() error { _, err := NewLRUE(fetch, time.Minute, 0); return err },
+	}
+	for name, create := range invalid {
+		assert.ErrorIs(t, create(), ErrInvalidConfig, name)
+	}
+
+	assert.Panics(t, func() { New(fetch, time.Minute, WithCloner(func(v int) int { return v })) })
+	assert.NotPanics(t, func() { New(fetch, -time.Minute) }, "New must keep accepting the configs it used to")
+}
+
+func TestNoErrorCaching(t *testing.T) {
+	var fail atomic.Bool
+	var fetches atomic.Int32
+	l := New(func(ctx context.Context, key string) (string, error) {
+		fetches.Add(1)
+		if fail.Load() {
+			return "", fmt.Errorf("origin is down")
+		}
+		return "value " + key, nil
+	}, 50*time.Millisecond, WithNoErrorCaching())
+
+	fail.Store(true)
+	_, err := l.Load("a")
+	assert.Error(t, err)
+	_, err = l.Load("a")
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), fetches.Load(), "failed fetch must not be cached")
+
+	fail.Store(false)
+	val, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", val)
+
+	fail.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	l.Load("a")
+	l.Wait()
+	val, info, err := l.LoadWithInfo("a")
+	l.Wait()
+	assert.NoError(t, err, "failed refresh must keep the stale value")
+	assert.Equal(t, "value a", val)
+	assert.True(t, info.Stale)
+	assert.Equal(t, int32(5), fetches.Load(), "failed refresh must be retried by the next Load")
+}
+
+func TestStartupSmear(t *testing.T) {
+	l := New(func(ctx context.Context, key int) (int, error) {
+		return key, nil
+	}, time.Hour, WithStartupSmear(time.Minute))
+
+	expires := map[time.Time]bool{}
+	for key := 0; key < 20; key++ {
+		_, info, _ := l.LoadWithInfo(key)
+		ttl := info.Expire.Sub(info.FetchedAt)
+		assert.True(t, ttl > time.Hour-time.Minute && ttl <= time.Hour, "ttl %v must be shortened by up to the window", ttl)
+		expires[info.Expire] = true
+	}
+	assert.Greater(t, len(expires), 10, "expiries must be smeared")
+
+	l.startedAt = time.Now().Add(-time.Minute)
+	_, info, _ := l.LoadWithInfo(100)
+	assert.Equal(t, time.Hour, info.Expire.Sub(info.FetchedAt), "values fetched after the window must use the full ttl")
+}
+
+func TestKeyLockerCtx(t *testing.T) {
+	locker := &InMemoryKeyLocker[string]{}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithKeyLocker[string](locker))
+
+	unlock := locker.Lock("a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := l.LoadCtx(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Load must stop waiting for the key lock when ctx is done")
+	unlock()
+
+	val, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", val)
+}
+
+func TestLockDebug(t *testing.T) {
+	errs := make(chan error, 10)
+	report := func(err error) { errs <- err }
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+
+	l := New(fetch, time.Minute, WithLockDebug(10*time.Millisecond, report))
+	l.Load("a")
+	unlock := l.lock.Lock("a")
+	var lockErr *LockError
+	select {
+	case err := <-errs:
+		assert.ErrorAs(t, err, &lockErr)
+		assert.Equal(t, "a", lockErr.Key)
+		assert.False(t, lockErr.Leaked)
+		assert.GreaterOrEqual(t, lockErr.Held, 10*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("lock held beyond the threshold must be reported")
+	}
+	unlock()
+
+	l = New(fetch, time.Minute, WithLockDebug(time.Minute, report))
+	func() { l.lock.Lock("b") }()
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		select {
+		case err := <-errs:
+			return errors.As(err, &lockErr) && lockErr.Leaked && lockErr.Key == "b"
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond, "garbage collected unlock must be reported")
+}
+
+func TestCorruptEntryRecovery(t *testing.T) {
+	driver := InMemoryCache()
+	driver.Add("a", "written by other app")
+	driver.Add("b", nil)
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}
+
+	l := New(fetch, time.Minute, WithDriver(driver))
+	_, err := l.Load("a")
+	assert.ErrorIs(t, err, ErrCorruptEntry, "corrupt entry must fail without recovery")
+
+	var reported []error
+	l = New(fetch, time.Minute, WithDriver(driver), WithCorruptEntryRecovery(func(err error) {
+		reported = append(reported, err)
+	}))
+	for _, key := range []string{"a", "b"} {
+		val, err := l.Load(key)
+		assert.NoError(t, err)
+		assert.Equal(t, "value "+key, val)
+	}
+	_, info, _ := l.LoadWithInfo("a")
+	assert.True(t, info.Cached, "refetched value must replace the corrupt entry")
+	assert.Len(t, reported, 2)
+	for _, err := range reported {
+		assert.ErrorIs(t, err, ErrCorruptEntry)
+	}
+}
+
+func TestNewVersioned(t *testing.T) {
+	type fetched struct {
+		value string
+		stamp uint64
+	}
+	results := make(chan fetched, 3)
+	results <- fetched{"v2", 2}
+	results <- fetched{"v1", 1}
+	results <- fetched{"v3", 3}
+	l := NewVersioned(func(ctx context.Context, key string) (string, uint64, error) {
+		r := <-results
+		return r.value, r.stamp, nil
+	}, 10*time.Millisecond)
+	defer l.Close()
+
+	v, _ := l.Load("a")
+	assert.Equal(t, "v2", v)
+
+	time.Sleep(20 * time.Millisecond)
+	v, _ = l.Load("a")
+	assert.Equal(t, "v2", v)
+	assert.Eventually(t, func() bool { return l.Stats().VersionConflicts == 1 }, time.Second, time.Millisecond)
+	v, _ = l.Load("a")
+	assert.Equal(t, "v2", v, "older version must not overwrite the cached value")
+
+	assert.Eventually(t, func() bool {
+		v, _ := l.Load("a")
+		return v == "v3"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint64(1), l.Stats().VersionConflicts)
+}
+
+func TestNewVersionedDuringConstruction(t *testing.T) {
+	stamps := make(chan uint64, 2)
+	stamps <- 2
+	stamps <- 1
+	l := NewVersioned(func(ctx context.Context, key string) (string, uint64, error) {
+		stamp := <-stamps
+		return fmt.Sprint("v", stamp), stamp, nil
+	}, 10*time.Millisecond, OptionT[string, string](func(l *Loader[string, string]) {
+		// loads started by the options run before NewVersioned returns
+		l.Load("a")
+	}))
+	defer l.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	l.Load("a")
+	assert.Eventually(t, func() bool { return l.Stats().VersionConflicts == 1 }, time.Second, time.Millisecond)
+	v, _ := l.Load("a")
+	assert.Equal(t, "v2", v, "the value loaded during New must be stamped")
+}
+
+// fakeOtter records the TTLs and costs passed to otter
+type fakeOtter struct {
+	mutex sync.Mutex
+	items map[interface{}]interface{}
+	ttls  map[interface{}]time.Duration
+	costs map[interface{}]uint32
+}
+
+func (c *fakeOtter) Get(key interface{}) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	v, ok := c.items[key]
+	return v, ok
+}
+
+func (c *fakeOtter) Set(key interface{}, value interface{}, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items[key], c.ttls[key], c.costs[key] = value, ttl, OtterCost(key, value)
+	return true
+}
+
+func (c *fakeOtter) Delete(key interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.items, key)
+}
+
+func (c *fakeOtter) Range(fn func(key, value interface{}) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for k, v := range c.items {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+func TestNewOtter(t *testing.T) {
+	cache := &fakeOtter{items: map[interface{}]interface{}{}, ttls: map[interface{}]time.Duration{}, costs: map[interface{}]uint32{}}
+	strlen := func(value string) int64 { return int64(len(value)) }
+	l := NewOtter(func(ctx context.Context, key string) (string, error) {
+		return key + key, nil
+	}, time.Minute, cache, WithHardTTL(time.Hour), WithCost(strlen))
+
+	l.Load("abc")
+	_, info, _ := l.LoadWithInfo("abc")
+	assert.True(t, info.Cached)
+	assert.InDelta(t, time.Hour, cache.ttls["abc"], float64(time.Second), "the hard TTL must be passed to otter")
+	assert.Equal(t, uint32(6), cache.costs["abc"], "the cost must be passed to otter")
+
+	assert.NoError(t, l.Invalidate("abc"))
+	assert.Empty(t, cache.items)
+
+	l = NewOtter(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, cache)
+	l.Load("b")
+	assert.Equal(t, otterNoExpiry, cache.ttls["b"])
+	assert.Equal(t, uint32(1), cache.costs["b"])
+}
+
+// fakeTheine records the TTLs and costs passed to theine
+type fakeTheine struct {
+	fakeOtter
+}
+
+func (c *fakeTheine) SetWithTTL(key interface{}, value interface{}, cost int64, ttl time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items[key], c.ttls[key], c.costs[key] = value, ttl, uint32(cost)
+	return true
+}
+
+func TestNewTheine(t *testing.T) {
+	cache := &fakeTheine{fakeOtter{items: map[interface{}]interface{}{}, ttls: map[interface{}]time.Duration{}, costs: map[interface{}]uint32{}}}
+	strlen := func(value string) int64 { return int64(len(value)) }
+	fetches := 0
+	l := NewTheine(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return strings.Repeat(key, fetches), nil
+	}, time.Millisecond, cache, WithHardTTL(time.Hour), WithCost(strlen))
+
+	l.Load("a")
+	assert.InDelta(t, time.Hour, cache.ttls["a"], float64(time.Second), "the hard TTL must be passed to theine")
+	assert.Equal(t, uint32(1), cache.costs["a"])
+
+	time.Sleep(2 * time.Millisecond)
+	l.Load("a")
+	assert.Eventually(t, func() bool {
+		cache.mutex.Lock()
+		defer cache.mutex.Unlock()
+		return cache.costs["a"] == 2
+	}, time.Second, time.Millisecond, "refresh must pass the new cost to theine")
+
+	l = NewTheine(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, cache)
+	l.Load("b")
+	assert.Zero(t, cache.ttls["b"], "entries without hard TTL must never expire")
+	assert.NoError(t, l.Invalidate("b"))
+	_, ok := cache.Get("b")
+	assert.False(t, ok)
+}
+
+func TestEvents(t *testing.T) {
+	errRefresh := errors.New("refresh failed")
+	results := make(chan error, 2)
+	results <- nil
+	results <- errRefresh
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "v", <-results
+	}, 10*time.Millisecond)
+	events := l.Events()
+
+	next := func() Event[string] {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+			return Event[string]{}
+		}
+	}
+
+	l.Load("a")
+	assert.Equal(t, EventMiss, next().Type)
+	l.Load("a")
+	assert.Equal(t, EventHit, next().Type)
+
+	time.Sleep(20 * time.Millisecond)
+	l.Load("a")
+	assert.Equal(t, EventStaleHit, next().Type)
+	assert.Equal(t, EventRefreshStart, next().Type)
+	e := next()
+	assert.Equal(t, EventRefreshError, e.Type)
+	assert.Equal(t, "a", e.Key)
+	assert.ErrorIs(t, e.Err, errRefresh)
+
+	l.Invalidate("a")
+	assert.Equal(t, EventEvict, next().Type)
+	assert.Equal(t, "evict", EventEvict.String())
+
+	l.Close()
+	_, ok := <-events
+	assert.False(t, ok, "channel must be closed by Close")
+	_, ok = <-l.Events()
+	assert.False(t, ok)
+}
+
+func TestEventsCtx(t *testing.T) {
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "v", nil
+	}, time.Minute)
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := l.EventsCtx(ctx)
+	kept := l.Events()
+	cancel()
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-events:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond, "channel must be closed when ctx is done")
+
+	l.Load("a")
+	assert.Equal(t, EventMiss, (<-kept).Type)
+}
+
+func TestEventsEviction(t *testing.T) {
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}
+	evicted := func(t *testing.T, events <-chan Event[string], key string) {
+		t.Helper()
+		for {
+			select {
+			case e := <-events:
+				if e.Type == EventEvict {
+					assert.Equal(t, key, e.Key)
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no evict event")
+			}
+		}
+	}
+
+	t.Run("lru", func(t *testing.T) {
+		l := NewLRU(fetch, time.Minute, 1)
+		defer l.Close()
+		events := l.Events()
+		l.Load("a")
+		l.Load("b")
+		evicted(t, events, "a")
+	})
+	t.Run("bounded", func(t *testing.T) {
+		l := New(fetch, time.Minute, WithDriver(BoundedInMemoryCache(1)))
+		defer l.Close()
+		events := l.Events()
+		l.Load("a")
+		l.Load("b")
+		evicted(t, events, "a")
+	})
+	t.Run("tenants", func(t *testing.T) {
+		l := New(fetch, time.Minute, WithTenants(func(key string) string { return "t" }, 1))
+		defer l.Close()
+		events := l.Events()
+		l.Load("a")
+		l.Load("b")
+		evicted(t, events, "a")
+	})
+	t.Run("listener", func(t *testing.T) {
+		listener := &EvictionListener{}
+		l := New(fetch, time.Minute, WithEvictionListener(listener))
+		events := l.Events()
+		l.Load("a")
+		iface, _ := l.driver.Get("a")
+		listener.Evicted("a", iface)
+		evicted(t, events, "a")
+		l.Close()
+		assert.Empty(t, *listener.fns.Load(), "Close must unsubscribe the loader")
+	})
+	t.Run("hard expiry", func(t *testing.T) {
+		l := New(fetch, time.Millisecond, WithHardTTL(5*time.Millisecond))
+		defer l.Close()
+		events := l.Events()
+		l.Load("a")
+		time.Sleep(10 * time.Millisecond)
+		l.Load("a")
+		evicted(t, events, "a")
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	records := make(chan AuditRecord, 10)
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return "v", nil
+	}, 10*time.Millisecond, WithName("audited"), WithAuditLog(AuditFunc(func(record AuditRecord) {
+		records <- record
+	})))
+	defer l.Close()
+
+	next := func() AuditRecord {
+		select {
+		case r := <-records:
+			return r
+		case <-time.After(time.Second):
+			t.Fatal("no audit record")
+			return AuditRecord{}
+		}
+	}
+
+	l.LoadCtx(WithAuditReason(context.Background(), "first request"), "a")
+	r := next()
+	assert.Equal(t, AuditSet, r.Op)
+	assert.Equal(t, "a", r.Key)
+	assert.Equal(t, "audited", r.Loader)
+	assert.Equal(t, "first request", r.Reason)
+
+	l.Load("a")
+	time.Sleep(20 * time.Millisecond)
+	l.LoadCtx(WithAuditReason(context.Background(), "stale request"), "a")
+	r = next()
+	assert.Equal(t, AuditRefresh, r.Op)
+	assert.Equal(t, "stale request", r.Reason)
+
+	l.InvalidateCtx(WithAuditReason(context.Background(), "customer update"), "a")
+	r = next()
+	assert.Equal(t, AuditInvalidate, r.Op)
+	assert.Equal(t, "customer update", r.Reason)
+
+	l.PrimeCtx(WithAuditReason(context.Background(), "import"), "b", "w")
+	r = next()
+	assert.Equal(t, AuditSet, r.Op)
+	assert.Equal(t, "b", r.Key)
+	assert.Len(t, records, 0, "hits must not be recorded")
+
+	l.InvalidateAllCtx(WithAuditReason(context.Background(), "flush"))
+	r = next()
+	assert.Equal(t, AuditInvalidate, r.Op)
+	assert.Equal(t, "b", r.Key)
+	assert.Equal(t, "flush", r.Reason)
+
+	var buf bytes.Buffer
+	NewAuditWriter(&buf, nil).Append(AuditRecord{Time: time.Unix(0, 0).UTC(), Op: AuditRefresh, Key: 42, Err: errors.New("timeout")})
+	assert.Equal(t, `{"time":"1970-01-01T00:00:00Z","op":"refresh","key":"42","error":"timeout"}`+"\n", buf.String())
+}
+
+func TestAuditLogStoredOnly(t *testing.T) {
+	var mutex sync.Mutex
+	var records []AuditRecord
+	audit := AuditFunc(func(record AuditRecord) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		records = append(records, record)
+	})
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithAuditLog(audit), WithAdmissionPolicy(AdmissionFunc(func(key, value interface{}, cost int64) bool {
+		return key != "rejected"
+	})), WithFetchAll(func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"all": "v"}, nil
+	}, 0))
+	defer l.Close()
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(records) == 1
+	}, time.Second, time.Millisecond)
+	mutex.Lock()
+	assert.Equal(t, AuditRecord{Time: records[0].Time, Op: AuditSet, Key: "all", Reason: "fetch all"}, records[0])
+	records = nil
+	mutex.Unlock()
+
+	l.Load("rejected")
+	l.Prime("rejected", "v")
+	l.Preload(WithAuditReason(context.Background(), "warm up"))
+	mutex.Lock()
+	defer mutex.Unlock()
+	if assert.Len(t, records, 1, "rejected values must not be recorded") {
+		assert.Equal(t, "all", records[0].Key)
+		assert.Equal(t, "warm up", records[0].Reason)
+	}
+}
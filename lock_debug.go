@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// LockError is reported by WithLockDebug
+type LockError struct {
+	Key interface{}
+	// Held is how long the lock has been held
+	Held time.Duration
+	// Leaked is true if the unlock function is garbage collected without being called
+	Leaked bool
+}
+
+func (e *LockError) Error() string {
+	if e.Leaked {
+		return fmt.Sprintf("loader: lock of key %v is leaked after %v", e.Key, e.Held)
+	}
+	return fmt.Sprintf("loader: lock of key %v is held for %v", e.Key, e.Held)
+}
+
+// WithLockDebug reports the key locks that are held longer than threshold, and the unlock functions that are
+// garbage collected without being called. It's meant for tests and debug builds, it costs a timer per lock.
+// report receives *LockError from other goroutines, it panics if report is nil.
+func WithLockDebug(threshold time.Duration, report func(err error)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.lockDebug = threshold
+		cfg.onLockError = report
+	})
+}
+
+// debugKeyLocker wraps KeyLocker to track its locks, see WithLockDebug
+type debugKeyLocker[Key comparable] struct {
+	KeyLocker[Key]
+	threshold time.Duration
+	report    func(err error)
+}
+
+func newDebugKeyLocker[Key comparable](locker KeyLocker[Key], threshold time.Duration, report func(err error)) *debugKeyLocker[Key] {
+	if report == nil {
+		report = func(err error) { panic(err) }
+	}
+	return &debugKeyLocker[Key]{KeyLocker: locker, threshold: threshold, report: report}
+}
+
+// Lock implements KeyLocker
+func (d *debugKeyLocker[Key]) Lock(key Key) func() {
+	return d.track(key, d.KeyLocker.Lock(key))
+}
+
+// TryLock implements KeyLocker
+func (d *debugKeyLocker[Key]) TryLock(key Key) (func(), bool) {
+	unlock, ok := d.KeyLocker.TryLock(key)
+	if !ok {
+		return nil, false
+	}
+	return d.track(key, unlock), true
+}
+
+// LockCtx implements KeyLocker
+func (d *debugKeyLocker[Key]) LockCtx(ctx context.Context, key Key) (func(), error) {
+	unlock, err := d.KeyLocker.LockCtx(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return d.track(key, unlock), nil
+}
+
+// lockToken is referenced only by the unlock function, so its finalizer runs when the function is collected
+type lockToken struct {
+	key interface{}
+}
+
+func (d *debugKeyLocker[Key]) track(key Key, unlock func()) func() {
+	start := time.Now()
+	var unlocked atomic.Bool
+	timer := time.AfterFunc(d.threshold, func() {
+		if !unlocked.Load() {
+			d.report(&LockError{Key: key, Held: time.Since(start)})
+		}
+	})
+	token := &lockToken{key: key}
+	runtime.SetFinalizer(token, func(token *lockToken) {
+		if !unlocked.Load() {
+			timer.Stop()
+			d.report(&LockError{Key: token.key, Held: time.Since(start), Leaked: true})
+		}
+	})
+	return func() {
+		runtime.KeepAlive(token)
+		if unlocked.Swap(true) {
+			return
+		}
+		timer.Stop()
+		unlock()
+	}
+}
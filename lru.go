@@ -1,27 +1,84 @@
 package loader
 
 import (
+	"sync"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru"
+	"github.com/hashicorp/golang-lru/simplelru"
 )
 
 // lruWrapper wraps hashicorp's lru cache object, so it's compatible with loader cache
 type lruWrapper struct {
-	*lru.Cache
+	mutex    sync.Mutex
+	cache    *simplelru.LRU
+	size     int
+	listener EvictionListener
 }
 
-// Add item to cache
-func (c lruWrapper) Add(key interface{}, value interface{}) {
-	c.Cache.Add(key, value)
+func newLRUWrapper(size int) (*lruWrapper, error) {
+	cache, err := simplelru.NewLRU(size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &lruWrapper{cache: cache, size: size}, nil
+}
+
+// Add item to cache, the least recently used item is evicted when the cache is full
+func (c *lruWrapper) Add(key interface{}, value interface{}) {
+	var evicted evictedItem
+	var ok bool
+	c.mutex.Lock()
+	if !c.cache.Contains(key) && c.cache.Len() >= c.size {
+		evicted.key, evicted.value, ok = c.cache.RemoveOldest()
+	}
+	c.cache.Add(key, value)
+	c.mutex.Unlock()
+	if ok {
+		c.listener.Evicted(evicted.key, evicted.value)
+	}
+}
+
+// Get implements CacheDriver
+func (c *lruWrapper) Get(key interface{}) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Get(key)
+}
+
+// Remove implements Remover
+func (c *lruWrapper) Remove(key interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.Remove(key)
+}
+
+// Range implements Ranger, iterating from the oldest to the newest item
+func (c *lruWrapper) Range(fn func(key, value interface{}) bool) {
+	c.mutex.Lock()
+	keys := c.cache.Keys()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i], _ = c.cache.Peek(key)
+	}
+	c.mutex.Unlock()
+	for i, key := range keys {
+		if !fn(key, values[i]) {
+			return
+		}
+	}
+}
+
+// OnEvict implements EvictionNotifier
+func (c *lruWrapper) OnEvict(fn func(key, value interface{})) (cancel func()) {
+	return c.listener.OnEvict(fn)
 }
 
 // NewLRU creates Loader with lru based cache
 func NewLRU[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, size int, options ...Option) *Loader[Key, Value] {
-	cache, err := lru.New(size)
+	cache, err := newLRUWrapper(size)
 	if err != nil {
 		panic(err)
 	}
-	options = append(options, WithDriver(&lruWrapper{cache}))
+	options = append(options, WithDriver(cache))
 	return New(fn, ttl, options...)
 }
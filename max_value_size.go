@@ -0,0 +1,58 @@
+package loader
+
+import "fmt"
+
+// WithMaxValueSize refuses to cache values larger than maxSize.
+// The size is computed by the cost function of WithCost, or the serialized size for RemoteCache.
+// Oversized values are returned to the callers of the fetch, but they are removed from the cache
+// and counted in Stats.Oversized. The driver must implement Remover.
+func WithMaxValueSize(maxSize int64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.maxValueSize = maxSize
+	})
+}
+
+// valueSizer is implemented by drivers that can compute the serialized size of a value
+type valueSizer interface {
+	valueSize(value interface{}) (int64, error)
+}
+
+// checkMaxValueSize validates the drivers and options used with WithMaxValueSize
+func (l *Loader[Key, Value]) checkMaxValueSize() error {
+	if l.maxValueSize <= 0 {
+		return nil
+	}
+	if _, ok := l.driver.(Remover); !ok {
+		return fmt.Errorf("%w: WithMaxValueSize requires a driver that implements Remover", ErrInvalidConfig)
+	}
+	if _, ok := l.driver.(valueSizer); !ok && l.cost == nil {
+		return fmt.Errorf("%w: WithMaxValueSize requires WithCost or a driver that serializes values", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// oversized returns true if value must not be cached because of WithMaxValueSize
+func (l *Loader[Key, Value]) oversized(value Value) bool {
+	if l.maxValueSize <= 0 {
+		return false
+	}
+	if l.cost != nil {
+		return l.cost(value) > l.maxValueSize
+	}
+	size, err := l.driver.(valueSizer).valueSize(value)
+	// a value that can't be serialized would fail to be cached anyway
+	return err == nil && size > l.maxValueSize
+}
+
+// uncache removes item from the cache, unless it's been replaced by other item
+func (l *Loader[Key, Value]) uncache(key Key, item *cacheItem[Value]) {
+	dk := l.driverKey(key)
+	// items of write-through drivers are decoded on every Get, so they never match
+	if iface, ok := l.driver.Get(dk); ok && (iface == item || l.writeThrough) {
+		l.driver.(Remover).Remove(dk)
+		if l.deps != nil {
+			l.deps.evicted(key)
+		}
+		l.emit(EventEvict, key, nil)
+	}
+}
@@ -0,0 +1,48 @@
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// Middleware decorates a Fetcher, e.g. for logging, retries, metrics, or auth token injection
+type Middleware[Key comparable, Value any] func(next Fetcher[Key, Value]) Fetcher[Key, Value]
+
+// WithMiddleware wraps the fetcher with middlewares.
+// The first middleware is the outermost one, so it's called first.
+func WithMiddleware[Key comparable, Value any](middlewares ...Middleware[Key, Value]) Option {
+	return optionFunc(func(cfg *config) {
+		for _, mw := range middlewares {
+			cfg.middlewares = append(cfg.middlewares, mw)
+		}
+	})
+}
+
+// chain applies the middlewares of WithMiddleware to fn
+func chain[Key comparable, Value any](fn Fetcher[Key, Value], middlewares []interface{}, err *error) Fetcher[Key, Value] {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if mw := typedOption[Middleware[Key, Value]](middlewares[i], "WithMiddleware", err); mw != nil {
+			fn = mw(fn)
+		}
+	}
+	return fn
+}
+
+// Retry is a middleware that retries failed fetches up to attempts times in total, waiting delay between them.
+// It stops retrying when the fetch context is done.
+func Retry[Key comparable, Value any](attempts int, delay time.Duration) Middleware[Key, Value] {
+	return func(next Fetcher[Key, Value]) Fetcher[Key, Value] {
+		return func(ctx context.Context, key Key) (Value, error) {
+			value, err := next(ctx, key)
+			for i := 1; i < attempts && err != nil; i++ {
+				select {
+				case <-ctx.Done():
+					return value, err
+				case <-time.After(delay):
+				}
+				value, err = next(ctx, key)
+			}
+			return value, err
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Package mmapstore implements loader.RemoteStore on top of a memory-mapped file, for large read-mostly datasets.
+// The values survive restarts, and with loader.BlobCodec the []byte values of the loader alias the mapping,
+// so the blobs aren't copied to the heap:
+//
+//	store, err := mmapstore.Open(filepath.Join(cacheDir, "blobs.mmap"), 4<<30)
+//	driver := loader.RemoteCache[[]byte](store, loader.WithCodec(loader.BlobCodec))
+//
+// With other codecs, RemoteCache decodes the values to the heap like with any RemoteStore.
+//
+// The file is an append-only log of records with an index in memory, so Set and Delete append to it and the loader
+// handles the refresh and invalidation as with any RemoteStore. The file is preallocated to its maximum size,
+// sparse on most Unix filesystems; when it's full, Set and Delete return ErrFull.
+// The space of replaced, deleted, and expired values is reclaimed when the file is opened again.
+// Writes aren't synced, a crash can lose the latest records but never corrupts the older ones.
+package mmapstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrFull is returned when a record doesn't fit in the maximum size of the file
+	ErrFull = errors.New("mmapstore: file is full")
+	// ErrClosed is returned after Close
+	ErrClosed = errors.New("mmapstore: store is closed")
+)
+
+const (
+	// recordMagic starts every record, the zeroed end of the preallocated file doesn't
+	recordMagic = 0xca
+	// recordTombstone marks the records of Delete
+	recordTombstone = 1
+	// headerSize is magic, flags, 2 bytes of padding, key size, value size, expiry in unix milliseconds and CRC-32
+	headerSize = 24
+)
+
+// Store is loader.RemoteStore backed by a memory-mapped file.
+// The values returned by Get alias the mapping, so they must not be used after Close.
+type Store struct {
+	mutex   sync.RWMutex
+	f       *os.File
+	data    []byte
+	unmap   func() error
+	maxSize int64
+	// end is the offset of the next record
+	end   int64
+	index map[string]entry
+	// live is the size of the records in index
+	live   int64
+	closed bool
+}
+
+type entry struct {
+	// offset and size locate the value in data
+	offset    int64
+	size      int64
+	expiresAt int64
+}
+
+// Open opens or creates the file of Store, with room for maxSize bytes of records.
+// The file is compacted if most of it is taken by values that are replaced, deleted, or expired.
+func Open(path string, maxSize int64) (*Store, error) {
+	s, err := open(path, maxSize)
+	if err != nil || s.live >= s.end/2 {
+		return s, err
+	}
+	if err := s.compact(path); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return open(path, maxSize)
+}
+
+func open(path string, maxSize int64) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < maxSize {
+		err = f.Truncate(maxSize)
+	} else if err == nil {
+		maxSize = info.Size()
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	data, unmap, err := mapFile(f, int(maxSize))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &Store{f: f, data: data, unmap: unmap, maxSize: maxSize, index: map[string]entry{}}
+	s.scan()
+	return s, nil
+}
+
+// scan rebuilds the index from the records, stopping at the first one that is incomplete
+func (s *Store) scan() {
+	now := time.Now().UnixMilli()
+	for s.end+headerSize <= s.maxSize {
+		header := s.data[s.end : s.end+headerSize]
+		if header[0] != recordMagic {
+			break
+		}
+		keySize := int64(binary.LittleEndian.Uint32(header[4:]))
+		valueSize := int64(binary.LittleEndian.Uint32(header[8:]))
+		expiresAt := int64(binary.LittleEndian.Uint64(header[12:]))
+		size := headerSize + keySize + valueSize
+		if s.end+size > s.maxSize {
+			break
+		}
+		body := s.data[s.end+headerSize : s.end+size]
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[20:]) {
+			break
+		}
+		key := string(body[:keySize])
+		s.remove(key)
+		if header[1]&recordTombstone == 0 && (expiresAt == 0 || expiresAt > now) {
+			s.index[key] = entry{offset: s.end + headerSize + keySize, size: valueSize, expiresAt: expiresAt}
+			s.live += size
+		}
+		s.end += size
+	}
+}
+
+// compact rewrites the live records to a new file replacing path, then closes s
+func (s *Store) compact(path string) error {
+	tmp := path + ".compact"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	var offset int64
+	for key, e := range s.index {
+		record := newRecord(key, s.data[e.offset:e.offset+e.size], e.expiresAt, 0)
+		if _, err = f.WriteAt(record, offset); err != nil {
+			break
+		}
+		offset += int64(len(record))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func newRecord(key string, value []byte, expiresAt int64, flags byte) []byte {
+	record := make([]byte, headerSize+len(key)+len(value))
+	record[0], record[1] = recordMagic, flags
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(value)))
+	binary.LittleEndian.PutUint64(record[12:], uint64(expiresAt))
+	copy(record[headerSize:], key)
+	copy(record[headerSize+len(key):], value)
+	binary.LittleEndian.PutUint32(record[20:], crc32.ChecksumIEEE(record[headerSize:]))
+	return record
+}
+
+// Get implements loader.RemoteStore.
+// The returned slice is read from the mapping, it must not be modified and it's only valid until Close.
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return nil, ErrClosed
+	}
+	e, ok := s.index[key]
+	if !ok || e.expiresAt != 0 && e.expiresAt <= time.Now().UnixMilli() {
+		return nil, nil
+	}
+	return s.data[e.offset : e.offset+e.size : e.offset+e.size], nil
+}
+
+// Set implements loader.RemoteStore
+func (s *Store) Set(key string, data []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixMilli()
+	}
+	record := newRecord(key, data, expiresAt, 0)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	offset, err := s.append(record)
+	if err != nil {
+		return err
+	}
+	s.remove(key)
+	s.index[key] = entry{offset: offset + headerSize + int64(len(key)), size: int64(len(data)), expiresAt: expiresAt}
+	s.live += int64(len(record))
+	return nil
+}
+
+// Delete implements loader.RemoteStore
+func (s *Store) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.index[key]; !ok {
+		return nil
+	}
+	if _, err := s.append(newRecord(key, nil, 0, recordTombstone)); err != nil {
+		return err
+	}
+	s.remove(key)
+	return nil
+}
+
+// append writes record at the end of the file, the caller must hold the write lock
+func (s *Store) append(record []byte) (int64, error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+	offset := s.end
+	if offset+int64(len(record)) > s.maxSize {
+		return 0, ErrFull
+	}
+	if _, err := s.f.WriteAt(record, offset); err != nil {
+		return 0, err
+	}
+	s.end += int64(len(record))
+	return offset, nil
+}
+
+// remove drops key from the index
+func (s *Store) remove(key string) {
+	if e, ok := s.index[key]; ok {
+		s.live -= headerSize + int64(len(key)) + e.size
+		delete(s.index, key)
+	}
+}
+
+// Size returns the size of the records in the file, including the ones that are replaced, deleted, or expired
+func (s *Store) Size() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.end
+}
+
+// Close unmaps and closes the file.
+// Reading a slice returned by Get after Close crashes the process with SIGSEGV, and so does reading the values
+// of a loader that decodes them with loader.BlobCodec: close the store only after the loader and the users
+// of its values are done.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.unmap()
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package mmapstore
+
+import (
+	"errors"
+	"os"
+)
+
+func mapFile(f *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errors.New("mmapstore: memory-mapped files are not supported on this platform")
+}
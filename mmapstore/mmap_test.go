@@ -0,0 +1,130 @@
+package mmapstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	s, err := Open(path, 1<<20)
+	assert.NoError(t, err)
+
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("a", []byte("1"), 0))
+	assert.NoError(t, s.Set("a", []byte("2"), 0))
+	assert.NoError(t, s.Set("b", []byte("3"), time.Hour))
+	assert.NoError(t, s.Set("c", []byte("4"), 0))
+	assert.NoError(t, s.Set("short", []byte("5"), time.Millisecond))
+	assert.NoError(t, s.Delete("c"))
+	v, _ = s.Get("a")
+	assert.Equal(t, []byte("2"), v)
+	assert.Equal(t, 1, cap(v), "the value must not be appendable over the next record")
+
+	time.Sleep(5 * time.Millisecond)
+	v, _ = s.Get("short")
+	assert.Nil(t, v, "expired value must not be returned")
+	assert.NoError(t, s.Close())
+	_, err = s.Get("a")
+	assert.Equal(t, ErrClosed, err)
+
+	s, err = Open(path, 1<<20)
+	assert.NoError(t, err)
+	defer s.Close()
+	v, _ = s.Get("a")
+	assert.Equal(t, []byte("2"), v, "values must survive restarts")
+	v, _ = s.Get("b")
+	assert.Equal(t, []byte("3"), v)
+	v, _ = s.Get("c")
+	assert.Nil(t, v, "deletes must survive restarts")
+	assert.Equal(t, int64(2*(headerSize+2)), s.Size(), "replaced, deleted, and expired values must be compacted")
+}
+
+func TestFull(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store"), 64)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.Set("a", []byte(strings.Repeat("x", 30)), 0))
+	assert.Equal(t, ErrFull, s.Set("b", []byte(strings.Repeat("x", 30)), 0))
+	v, _ := s.Get("a")
+	assert.Len(t, v, 30)
+}
+
+func TestTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store")
+	s, err := Open(path, 1<<10)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set("a", []byte("1"), 0))
+	assert.NoError(t, s.Set("b", []byte("2"), 0))
+	end := s.Size()
+	assert.NoError(t, s.Close())
+
+	// corrupt the value of the last record, as if the write was interrupted
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	assert.NoError(t, err)
+	f.WriteAt([]byte{0}, end-1)
+	f.Close()
+
+	s, err = Open(path, 1<<10)
+	assert.NoError(t, err)
+	defer s.Close()
+	v, _ := s.Get("a")
+	assert.Equal(t, []byte("1"), v)
+	v, _ = s.Get("b")
+	assert.Nil(t, v, "incomplete record must be dropped")
+	assert.NoError(t, s.Set("c", []byte("3"), 0))
+	v, _ = s.Get("c")
+	assert.Equal(t, []byte("3"), v)
+}
+
+func TestRemoteCache(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store"), 1<<20)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(loader.RemoteCache[string](s)))
+	defer l.Close()
+	l.Load("a")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", v)
+	assert.Equal(t, 1, fetches)
+	assert.NoError(t, l.Invalidate("a"))
+	l.Load("a")
+	assert.Equal(t, 2, fetches)
+}
+
+func TestBlobCodec(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store"), 1<<20)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	l := loader.New(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte(strings.Repeat(key, 1000)), nil
+	}, time.Minute, loader.WithDriver(loader.RemoteCache[[]byte](s, loader.WithCodec(loader.BlobCodec))))
+	defer l.Close()
+	l.Load("a")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 1000), string(v))
+
+	start, end := uintptr(unsafe.Pointer(&s.data[0])), uintptr(unsafe.Pointer(&s.data[len(s.data)-1]))
+	p := uintptr(unsafe.Pointer(&v[0]))
+	assert.True(t, p >= start && p <= end, "value must alias the mapping")
+}
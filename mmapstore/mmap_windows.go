@@ -0,0 +1,28 @@
+//go:build windows
+
+package mmapstore
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func mapFile(f *os.File, size int) ([]byte, func() error, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, uint32(uint64(size)>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		syscall.CloseHandle(h)
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// the view is outside of the Go heap, convert its address without uintptr arithmetic
+	data := unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&addr))), size)
+	return data, func() error {
+		err := syscall.UnmapViewOfFile(addr)
+		syscall.CloseHandle(h)
+		return err
+	}, nil
+}
@@ -0,0 +1,146 @@
+package loader
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// MutationError is reported by WithMutationCheck when a cached value has been mutated
+type MutationError struct {
+	Key interface{}
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("loader: cached value of key %v has been mutated", e.Key)
+}
+
+// WithMutationCheck hashes every cached value after it's fetched and re-hashes it on each cache hit.
+// If the hash changes, a caller has mutated the shared value, and onMutation is called with *MutationError.
+// If onMutation is nil, Load panics instead. Hashing walks the whole value, so use it in development only.
+func WithMutationCheck(onMutation func(err error)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.mutationCheck = true
+		cfg.onMutation = onMutation
+	})
+}
+
+// checkMutation compares the hash of the cached value with the hash when it was stored
+func (l *Loader[Key, Value]) checkMutation(key Key, p *payload[Value]) {
+	if hashValue(p.value) == p.hash {
+		return
+	}
+	err := &MutationError{Key: key}
+	if l.onMutation == nil {
+		panic(err)
+	}
+	l.onMutation(err)
+}
+
+// hashValue deeply hashes v, following pointers, slices, maps, and unexported fields
+func hashValue(v interface{}) uint64 {
+	h := newValueHasher()
+	h.hash(reflect.ValueOf(v))
+	return h.sum
+}
+
+func newValueHasher() *valueHasher {
+	return &valueHasher{sum: fnvOffset, visited: map[uintptr]bool{}}
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+type valueHasher struct {
+	sum     uint64
+	visited map[uintptr]bool
+}
+
+func (h *valueHasher) write(x uint64) {
+	// fnv-1a on the 8 bytes of x
+	for i := 0; i < 8; i++ {
+		h.sum ^= x & 0xff
+		h.sum *= fnvPrime
+		x >>= 8
+	}
+}
+
+func (h *valueHasher) writeString(s string) {
+	f := fnv.New64a()
+	f.Write([]byte(s))
+	h.write(f.Sum64())
+}
+
+func (h *valueHasher) hash(v reflect.Value) {
+	if !v.IsValid() {
+		h.write(0)
+		return
+	}
+	h.write(uint64(v.Kind()))
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.write(1)
+		} else {
+			h.write(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.write(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		h.write(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		h.write(math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		h.write(math.Float64bits(real(c)))
+		h.write(math.Float64bits(imag(c)))
+	case reflect.String:
+		h.writeString(v.String())
+	case reflect.Pointer:
+		if v.IsNil() {
+			h.write(0)
+			return
+		}
+		// shared or cyclic pointers are hashed once
+		if h.visited[v.Pointer()] {
+			return
+		}
+		h.visited[v.Pointer()] = true
+		h.hash(v.Elem())
+	case reflect.Interface:
+		h.hash(v.Elem())
+	case reflect.Slice, reflect.Array:
+		h.write(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			h.hash(v.Index(i))
+		}
+	case reflect.Map:
+		h.write(uint64(v.Len()))
+		// map iteration order is random, so hash entries separately and combine them in sorted order.
+		// each entry tracks its own pointers, so the result doesn't depend on the order.
+		entries := make([]uint64, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entry := newValueHasher()
+			entry.hash(iter.Key())
+			entry.hash(iter.Value())
+			entries = append(entries, entry.sum)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+		for _, e := range entries {
+			h.write(e)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			h.hash(v.Field(i))
+		}
+	default:
+		// channels, functions, and unsafe pointers are compared by identity
+		h.write(uint64(v.Pointer()))
+	}
+}
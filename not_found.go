@@ -0,0 +1,45 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ErrNotFound can be returned by the fetcher, wrapped or not, to cache a negative result.
+// It's cached for the ttl of WithNotFoundTTL instead of the error ttl.
+var ErrNotFound = errors.New("loader: not found")
+
+// WithNotFoundTTL caches the errors that wrap ErrNotFound for ttl, usually longer than the error ttl
+// because a missing key is an answer of the origin, not a failure.
+func WithNotFoundTTL(ttl time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.notFoundTtl = ttl
+	})
+}
+
+// WithCacheZeroValues(false) doesn't cache the zero value returned with nil error, so the next Load fetches it again.
+// The value is still returned to the callers of the fetch. Return ErrNotFound instead to cache the miss.
+// The driver must implement Remover.
+func WithCacheZeroValues(cache bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.skipZeroValues = !cache
+	})
+}
+
+// checkZeroValues validates the driver used with WithCacheZeroValues
+func (l *Loader[Key, Value]) checkZeroValues() error {
+	if !l.skipZeroValues {
+		return nil
+	}
+	if _, ok := l.driver.(Remover); !ok {
+		return fmt.Errorf("%w: WithCacheZeroValues(false) requires a driver that implements Remover", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// uncachedZero returns true if value must not be cached because of WithCacheZeroValues
+func (l *Loader[Key, Value]) uncachedZero(value Value) bool {
+	return l.skipZeroValues && reflect.ValueOf(&value).Elem().IsZero()
+}
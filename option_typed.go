@@ -0,0 +1,58 @@
+package loader
+
+import (
+	"errors"
+	"time"
+)
+
+// OptionT configures Loader with hooks that need Key or Value type.
+// It's accepted by New alongside untyped options, and New panics if its types don't match the loader.
+type OptionT[Key comparable, Value any] func(l *Loader[Key, Value])
+
+func (o OptionT[Key, Value]) apply(cfg *config) {
+	cfg.typed = append(cfg.typed, o)
+}
+
+// WithDefault sets the value returned by Load when the fetch fails
+func WithDefault[Key comparable, Value any](value Value) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.def = value
+	}
+}
+
+// WithTTLFunc computes the soft TTL of each fetched item, overriding the ttl of New and WithErrorTTL.
+// err is the fetch error, value is the zero value when it's not nil.
+func WithTTLFunc[Key comparable, Value any](fn func(key Key, value Value, err error) time.Duration) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.ttlFunc = fn
+	}
+}
+
+// WithTransform modifies each successfully fetched value before it's cached
+func WithTransform[Key comparable, Value any](fn func(key Key, value Value) Value) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.transform = fn
+	}
+}
+
+// entryTTL returns the soft TTL for the fetch result
+func (l *Loader[Key, Value]) entryTTL(key Key, value Value, err error) time.Duration {
+	if l.ttlFunc != nil {
+		return l.ttlFunc(key, value, err)
+	}
+	if err != nil {
+		if l.notFoundTtl > 0 && errors.Is(err, ErrNotFound) {
+			return l.notFoundTtl
+		}
+		return l.errTtl
+	}
+	return l.ttl
+}
+
+// transformValue applies WithTransform to the fetched value
+func (l *Loader[Key, Value]) transformValue(key Key, value Value) Value {
+	if l.transform == nil {
+		return value
+	}
+	return l.transform(key, value)
+}
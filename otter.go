@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"math"
+	"time"
+)
+
+// OtterCache is the part of otter.CacheWithVariableTTL[any, any] (github.com/maypok86/otter) used by NewOtter
+type OtterCache interface {
+	Get(key interface{}) (interface{}, bool)
+	Set(key interface{}, value interface{}, ttl time.Duration) bool
+	Delete(key interface{})
+	Range(fn func(key, value interface{}) bool)
+}
+
+// otterNoExpiry is the otter TTL of the items of loaders without WithHardTTL, they're evicted by capacity instead
+const otterNoExpiry = 365 * 24 * time.Hour
+
+// otterDriver passes the hard expiry of the items to otter, re-adding them after every fetch
+type otterDriver struct {
+	cache OtterCache
+}
+
+// writeThrough implements writeThroughDriver, so the TTL of otter is renewed by refreshes
+func (d otterDriver) writeThrough() {}
+
+// Add implements CacheDriver
+func (d otterDriver) Add(key interface{}, value interface{}) {
+	ttl, expired := hardTTL(value)
+	switch {
+	case expired:
+		d.cache.Delete(key)
+	case ttl == 0:
+		d.cache.Set(key, value, otterNoExpiry)
+	default:
+		d.cache.Set(key, value, ttl)
+	}
+}
+
+// Get implements CacheDriver
+func (d otterDriver) Get(key interface{}) (interface{}, bool) {
+	return d.cache.Get(key)
+}
+
+// Remove implements Remover
+func (d otterDriver) Remove(key interface{}) {
+	d.cache.Delete(key)
+}
+
+// Range implements Ranger
+func (d otterDriver) Range(fn func(key, value interface{}) bool) {
+	d.cache.Range(fn)
+}
+
+// OtterCost is the cost function of the otter builder, it returns the cost computed by WithCost, or 1 without it
+func OtterCost(key, value interface{}) uint32 {
+	cost := itemCost(value)
+	if cost > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	if cost < 1 {
+		return 1
+	}
+	return uint32(cost)
+}
+
+// NewOtter creates Loader with otter based cache, an S3-FIFO cache bounded by the total cost.
+// Build the cache with variable TTL and OtterCost, so the hard TTL and the cost of WithCost are passed to otter,
+// and relay its evictions with EvictionListener, so the loader sends EventEvict for them:
+//
+//	evictions := &loader.EvictionListener{}
+//	cache, err := otter.MustBuilder[any, any](10_000).Cost(loader.OtterCost).WithVariableTTL().
+//		DeletionListener(func(key, value any, cause otter.DeletionCause) {
+//			if cause == otter.Size || cause == otter.Expired {
+//				evictions.Evicted(key, value)
+//			}
+//		}).Build()
+//	users := loader.NewOtter(fetchUser, time.Minute, cache, loader.WithHardTTL(time.Hour), loader.WithCost(userSize),
+//		loader.WithEvictionListener(evictions))
+//
+// Items of loaders without WithHardTTL are kept until otter evicts them for capacity, or for a year.
+func NewOtter[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, cache OtterCache, options ...Option) *Loader[Key, Value] {
+	options = append(options, WithDriver(otterDriver{cache}))
+	return New(fn, ttl, options...)
+}
@@ -0,0 +1,66 @@
+package loader
+
+import "sync"
+
+// pendingCalls tracks the items whose first fetch is in flight.
+// Latecomers wait on the ready channel of the item instead of the key lock, so they can give up when their context is done.
+// The items are also added to the driver, but the driver may reject or evict them before the fetch completes,
+// so the miss path checks here too and concurrent loads of a cold key share one fetch.
+type pendingCalls[Key comparable, Value any] struct {
+	mutex sync.Mutex
+	calls map[Key]pendingCall[Value]
+}
+
+type pendingCall[Value any] struct {
+	item *cacheItem[Value]
+	// stale is the hard expired payload replaced by item, it's served by WithDeadlineFallback
+	stale *payload[Value]
+}
+
+func newPendingCalls[Key comparable, Value any]() *pendingCalls[Key, Value] {
+	return &pendingCalls[Key, Value]{calls: map[Key]pendingCall[Value]{}}
+}
+
+func (p *pendingCalls[Key, Value]) get(key Key) *cacheItem[Value] {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.calls[key].item
+}
+
+// stale returns the stale payload of item, if it's still pending
+func (p *pendingCalls[Key, Value]) stale(key Key, item *cacheItem[Value]) *payload[Value] {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if call := p.calls[key]; call.item == item {
+		return call.stale
+	}
+	return nil
+}
+
+func (p *pendingCalls[Key, Value]) add(key Key, item *cacheItem[Value], stale *payload[Value]) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls[key] = pendingCall[Value]{item: item, stale: stale}
+}
+
+// done removes item once its first payload is stored
+func (p *pendingCalls[Key, Value]) done(key Key, item *cacheItem[Value]) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.calls[key].item == item {
+		delete(p.calls, key)
+	}
+}
+
+// forget removes the item of key, so loads after invalidation don't share a fetch started before it
+func (p *pendingCalls[Key, Value]) forget(key Key) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.calls, key)
+}
+
+func (p *pendingCalls[Key, Value]) forgetAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls = map[Key]pendingCall[Value]{}
+}
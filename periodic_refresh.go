@@ -0,0 +1,97 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WithPeriodicRefresh re-fetches every cached item of the loader every interval regardless of its TTL or access,
+// with up to concurrency fetches at a time. The driver must implement Ranger.
+func WithPeriodicRefresh(interval time.Duration, concurrency int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.periodicRefresh = interval
+		cfg.periodicConcurrency = concurrency
+	})
+}
+
+// checkPeriodicRefresh validates the driver used with WithPeriodicRefresh
+func (l *Loader[Key, Value]) checkPeriodicRefresh() error {
+	if l.periodicRefresh <= 0 {
+		return nil
+	}
+	if _, ok := l.driver.(Ranger); !ok {
+		return fmt.Errorf("%w: WithPeriodicRefresh requires a driver that implements Ranger", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// startPeriodicRefresh starts the loop of WithPeriodicRefresh
+func (l *Loader[Key, Value]) startPeriodicRefresh() {
+	if l.periodicRefresh > 0 && l.startBackground() {
+		go l.refreshPeriodically()
+	}
+}
+
+func (l *Loader[Key, Value]) refreshPeriodically() {
+	defer l.background.Done()
+	ticker := time.NewTicker(l.periodicRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.lifecycle.ctx.Done():
+			return
+		case <-ticker.C:
+			l.refreshAll()
+		}
+	}
+}
+
+type refreshJob[Key comparable, Value any] struct {
+	key  Key
+	item *cacheItem[Value]
+}
+
+// refreshAll re-fetches every cached item, it returns once all fetches are done
+func (l *Loader[Key, Value]) refreshAll() {
+	var jobs []refreshJob[Key, Value]
+	l.driver.(Ranger).Range(func(dk, value interface{}) bool {
+		key, ok := l.loaderKey(dk)
+		if !ok {
+			return true
+		}
+		if item, ok := value.(*cacheItem[Value]); ok {
+			jobs = append(jobs, refreshJob[Key, Value]{key, item})
+		}
+		return true
+	})
+
+	concurrency := l.periodicConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	queue := make(chan refreshJob[Key, Value])
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				// items that are loading or already refreshing are skipped
+				if job.item.beginRefresh() {
+					if !l.startBackground() {
+						job.item.endRefresh()
+						continue
+					}
+					l.refetch(withTriggerReason(context.Background(), FetchForceRefresh), job.key, job.item)
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,111 @@
+// Package pgsource invalidates loader items from PostgreSQL LISTEN/NOTIFY,
+// so database triggers evict the cached rows in every app instance without a broker.
+//
+// A trigger notifies the changed primary key:
+//
+//	CREATE FUNCTION notify_user_changed() RETURNS trigger AS $$
+//	BEGIN
+//		PERFORM pg_notify('users_changed', COALESCE(NEW.id, OLD.id)::text);
+//		RETURN NULL;
+//	END $$ LANGUAGE plpgsql;
+//	CREATE TRIGGER users_changed AFTER INSERT OR UPDATE OR DELETE ON users
+//		FOR EACH ROW EXECUTE FUNCTION notify_user_changed();
+//
+// It doesn't depend on a Postgres driver, adapt a dedicated connection, e.g. with pgx:
+//
+//	source := pgsource.New(func(ctx context.Context) (pgsource.Notification, error) {
+//		n, err := conn.WaitForNotification(ctx)
+//		if err != nil {
+//			return pgsource.Notification{}, err
+//		}
+//		return pgsource.Notification{Channel: n.Channel, Payload: n.Payload}, nil
+//	}, pgsource.PayloadKey, pgsource.WithListen(func(ctx context.Context) error {
+//		_, err := conn.Exec(ctx, "LISTEN users_changed")
+//		return err
+//	}))
+//	users := loader.New(fetchUser, time.Hour, loader.WithInvalidationSource[string](source, time.Second))
+package pgsource
+
+import (
+	"context"
+	"errors"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// Notification is a NOTIFY received on a listened channel
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// WaitFunc blocks until the next notification is received, or fails when ctx is done
+type WaitFunc func(ctx context.Context) (Notification, error)
+
+// DecodeFunc returns the keys invalidated by a notification.
+// A notification that fails to decode is skipped, see WithErrorHandler.
+type DecodeFunc[Key comparable] func(n Notification) ([]Key, error)
+
+// PayloadKey is DecodeFunc that invalidates the payload
+func PayloadKey(n Notification) ([]string, error) {
+	if n.Payload == "" {
+		return nil, errors.New("pgsource: notification without payload")
+	}
+	return []string{n.Payload}, nil
+}
+
+// Source is loader.InvalidationSource receiving Postgres notifications
+type Source[Key comparable] struct {
+	listen func(ctx context.Context) error
+	source loader.DecodingSource[Key, Notification]
+}
+
+var _ loader.InvalidationSource[string] = &Source[string]{}
+
+// Option configures Source
+type Option func(o *options)
+
+type options struct {
+	listen  func(ctx context.Context) error
+	onError func(err error)
+}
+
+// WithListen runs listen, which executes LISTEN, every time the loader subscribes,
+// so the channels are listened again after the connection is re-established.
+// Notifications sent while disconnected are lost, listen may also invalidate the data that could have changed.
+func WithListen(listen func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.listen = listen
+	}
+}
+
+// WithErrorHandler reports the notifications that fail to decode, nil is ignored
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.onError = fn
+		}
+	}
+}
+
+// New creates Source receiving notifications with wait and decoding their keys with decode
+func New[Key comparable](wait WaitFunc, decode DecodeFunc[Key], opts ...Option) *Source[Key] {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Source[Key]{
+		listen: o.listen,
+		source: loader.DecodingSource[Key, Notification]{Read: wait, Decode: decode, OnError: o.onError},
+	}
+}
+
+// Subscribe implements loader.InvalidationSource, it returns the error of listen or wait
+func (s *Source[Key]) Subscribe(ctx context.Context, invalidate func(key Key)) error {
+	if s.listen != nil {
+		if err := s.listen(ctx); err != nil {
+			return err
+		}
+	}
+	return s.source.Subscribe(ctx, invalidate)
+}
@@ -0,0 +1,75 @@
+package pgsource
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConn delivers notifications like a connection that drops once
+type fakeConn struct {
+	listens       int32
+	notifications chan Notification
+}
+
+func (c *fakeConn) listen(ctx context.Context) error {
+	atomic.AddInt32(&c.listens, 1)
+	return nil
+}
+
+func (c *fakeConn) wait(ctx context.Context) (Notification, error) {
+	select {
+	case n, ok := <-c.notifications:
+		if !ok {
+			return Notification{}, errors.New("connection closed")
+		}
+		return n, nil
+	case <-ctx.Done():
+		return Notification{}, ctx.Err()
+	}
+}
+
+func TestSource(t *testing.T) {
+	conn := &fakeConn{notifications: make(chan Notification)}
+	var decodeErrors int32
+	source := New(conn.wait, PayloadKey, WithListen(conn.listen), WithErrorHandler(func(err error) {
+		atomic.AddInt32(&decodeErrors, 1)
+	}))
+
+	var fetches int32
+	l := loader.New(func(ctx context.Context, key string) (int32, error) {
+		return atomic.AddInt32(&fetches, 1), nil
+	}, time.Hour, loader.WithInvalidationSource[string](source, time.Millisecond))
+	defer l.Close()
+
+	val, _ := l.Load("42")
+	assert.Equal(t, int32(1), val)
+
+	conn.notifications <- Notification{Channel: "users_changed"}
+	conn.notifications <- Notification{Channel: "users_changed", Payload: "42"}
+	assert.Eventually(t, func() bool {
+		val, _ := l.Load("42")
+		return val == 2
+	}, time.Second, time.Millisecond, "notified key must be invalidated")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&decodeErrors))
+
+	// the connection drops, the loader subscribes again and the channel is listened again
+	close(conn.notifications)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&conn.listens) >= 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestSourceNilErrorHandler(t *testing.T) {
+	conn := &fakeConn{notifications: make(chan Notification, 1)}
+	conn.notifications <- Notification{Channel: "users_changed"}
+	close(conn.notifications)
+	source := New(conn.wait, PayloadKey, WithErrorHandler(nil))
+	err := source.Subscribe(context.Background(), func(key string) {})
+	assert.EqualError(t, err, "connection closed", "nil error handler must be ignored")
+}
@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+)
+
+// readRepair is the state of WithReadRepair
+type readRepair[Key comparable, Value any] struct {
+	remote *Loader[Key, Value]
+	sample float64
+	// running is true while a check is in background
+	running atomic.Bool
+}
+
+// WithReadRepair checks a sample of the hits against remote, the shared tier of a tiered setup like WriteBack.
+// When both tiers have the key with different fetch times, or stamps of NewVersioned, the later entry is copied to the other tier,
+// so the tiers converge without waiting for the TTL. A key missing from either tier isn't repaired,
+// it may have been invalidated.
+// sample is the fraction of hits checked, from 0 to 1. Checks run in background, at most one at a time.
+func WithReadRepair[Key comparable, Value any](remote *Loader[Key, Value], sample float64) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.readRepair = &readRepair[Key, Value]{remote: remote, sample: sample}
+	}
+}
+
+// checkReadRepair starts the check of the hit of p, if it's sampled
+func (l *Loader[Key, Value]) checkReadRepair(key Key, p *payload[Value]) {
+	rr := l.readRepair
+	if p.err != nil || rand.Float64() >= rr.sample || !rr.running.CompareAndSwap(false, true) {
+		return
+	}
+	if !l.startBackground() {
+		rr.running.Store(false)
+		return
+	}
+	go func() {
+		defer l.background.Done()
+		defer rr.running.Store(false)
+		remote := rr.remote.peek(key)
+		repaired := false
+		switch {
+		case remote == nil:
+		case remote.newerThan(p):
+			repaired = l.replacePayload(key, p, remote)
+		case p.newerThan(remote):
+			repaired = rr.remote.replacePayload(key, remote, p)
+		}
+		if repaired {
+			l.counters.readRepairs.Add(1)
+		}
+	}()
+}
+
+// replacePayload caches a copy of p fetched by other loader, keeping its fetch time, with the TTLs of l.
+// The cached payload is only replaced if it's still the one seen by the check, so a refresh or Invalidate meanwhile isn't undone.
+func (l *Loader[Key, Value]) replacePayload(key Key, seen, p *payload[Value]) bool {
+	key = l.mapKey(key)
+	hk := l.hashedKey(key)
+	held, err := l.lockKey(context.Background(), key, hk)
+	if err != nil {
+		return false
+	}
+	defer held.unlock()
+
+	iface, ok := l.driverGetHashed(hk)
+	if !ok {
+		return false
+	}
+	item, ok := iface.(*cacheItem[Value])
+	if !ok {
+		return false
+	}
+	cur := item.payload.Load()
+	if cur == nil || !cur.sameFetch(seen) {
+		return false
+	}
+
+	cp := &payload[Value]{value: p.value, fetchedAt: p.fetchedAt, tag: p.tag, stamp: p.stamp, version: cur.version + 1}
+	cp.expire = cp.fetchedAt.Add(l.entryTTL(key, cp.value, nil))
+	if l.hardTTL > 0 {
+		cp.hardExpire = cp.fetchedAt.Add(l.hardTTL)
+	}
+	if l.mutationCheck {
+		cp.hash = hashValue(cp.value)
+	}
+	if !item.payload.CompareAndSwap(cur, cp) {
+		return false
+	}
+	l.stored(key, item, cp)
+	l.record(readRepairContext, AuditSet, key, nil)
+	return true
+}
+
+// readRepairContext is the context of the AuditRecords of read repair
+var readRepairContext = WithAuditReason(context.Background(), "read repair")
+
+// sameFetch reports whether p and other are the same fetch, other may be decoded again by a remote driver
+func (p *payload[Value]) sameFetch(other *payload[Value]) bool {
+	return p == other || (p.fetchedAt.Equal(other.fetchedAt) && p.stamp == other.stamp)
+}
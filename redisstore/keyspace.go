@@ -0,0 +1,104 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+)
+
+// Keyspace is loader.InvalidationSource of the keys changed in Redis, read from keyspace notifications.
+// Use it on the local (L1) loader in front of a shared Redis, so an update or delete by another instance
+// evicts the local copy:
+//
+//	source := redisstore.NewKeyspace(store, "users:*", func(redisKey string) (string, bool) {
+//		return strings.TrimPrefix(redisKey, "users:"), strings.HasPrefix(redisKey, "users:")
+//	})
+//	local := loader.New(fetchFromRedis, time.Minute, loader.WithInvalidationSource[string](source, time.Second))
+//
+// Redis only publishes the notifications when notify-keyspace-events has K and the classes of the events,
+// e.g. "K$gx" for strings, DEL, and expiration. Writes of this instance evict its own copy too,
+// so its next Load reads the value back from Redis.
+// Notifications published while the subscription is reconnecting are lost, the TTL of the local loader bounds how long
+// an item can stay stale.
+type Keyspace[Key comparable] struct {
+	store   *Store
+	pattern string
+	decode  func(redisKey string) (Key, bool)
+}
+
+var _ loader.InvalidationSource[string] = &Keyspace[string]{}
+
+// NewKeyspace creates Keyspace for the Redis keys that match the glob pattern.
+// decode returns the loader key of a Redis key, the notification is skipped if it returns false.
+func NewKeyspace[Key comparable](s *Store, pattern string, decode func(redisKey string) (Key, bool)) *Keyspace[Key] {
+	return &Keyspace[Key]{store: s, pattern: pattern, decode: decode}
+}
+
+// Subscribe implements loader.InvalidationSource. It holds a dedicated connection until ctx is done or the connection fails.
+func (k *Keyspace[Key]) Subscribe(ctx context.Context, invalidate func(key Key)) error {
+	c, err := k.store.connect()
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// unblock receive when ctx is done
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		c.Close()
+	}()
+
+	prefix := "__keyspace@" + strconv.Itoa(k.store.db) + "__:"
+	if _, err := c.do(k.store.timeout, "PSUBSCRIBE", prefix+k.pattern); err != nil {
+		return k.subscribeError(ctx, err)
+	}
+	// notifications can be far apart, only the subscription is bounded by the timeout
+	c.SetDeadline(time.Time{})
+	for {
+		reply, err := c.receive()
+		if err != nil {
+			return k.subscribeError(ctx, err)
+		}
+		// pmessage, pattern, channel, event
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 4 {
+			return fmt.Errorf("redis: unexpected notification %v", reply)
+		}
+		if kind, _ := toBytes(parts[0]); string(kind) != "pmessage" {
+			continue
+		}
+		channel, err := toBytes(parts[2])
+		if err != nil {
+			return err
+		}
+		redisKey, ok := cutPrefix(string(channel), prefix)
+		if !ok {
+			continue
+		}
+		if key, ok := k.decode(redisKey); ok {
+			invalidate(key)
+		}
+	}
+}
+
+// subscribeError returns the error of ctx instead of the error of the connection closed by it
+func (k *Keyspace[Key]) subscribeError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
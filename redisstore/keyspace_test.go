@@ -0,0 +1,57 @@
+package redisstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyspace(t *testing.T) {
+	var server *fakeServer
+	s := New(startFakeServer(t, func(fs *fakeServer) { server = fs }))
+	defer s.Close()
+	subscribed := func() bool {
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		return len(server.subscribers) == 1
+	}
+
+	source := NewKeyspace(s, "users:*", func(redisKey string) (string, bool) {
+		return strings.TrimPrefix(redisKey, "users:"), strings.HasPrefix(redisKey, "users:")
+	})
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		v, err := s.Get("users:" + key)
+		return string(v), err
+	}, time.Hour, loader.WithInvalidationSource[string](source, 10*time.Millisecond))
+	defer l.Close()
+	assert.Eventually(t, subscribed, time.Second, time.Millisecond)
+
+	v, _ := l.Load("a")
+	assert.Empty(t, v)
+	assert.NoError(t, s.Set("users:a", []byte("1"), 0))
+	assert.Eventually(t, func() bool {
+		v, _ := l.Load("a")
+		return v == "1"
+	}, time.Second, time.Millisecond, "set by another instance must evict the local item")
+
+	assert.NoError(t, s.Delete("users:a"))
+	assert.Eventually(t, func() bool {
+		v, _ := l.Load("a")
+		return v == ""
+	}, time.Second, time.Millisecond, "delete by another instance must evict the local item")
+
+	l.Close()
+	assert.Eventually(t, func() bool { return !subscribed() }, time.Second, time.Millisecond, "close must end the subscription")
+}
+
+func TestKeyspaceSubscribeError(t *testing.T) {
+	s := New(startFakeServer(t))
+	s.addr = "127.0.0.1:1"
+	err := NewKeyspace(s, "*", func(redisKey string) (string, bool) { return redisKey, true }).
+		Subscribe(context.Background(), func(string) {})
+	assert.Error(t, err)
+}
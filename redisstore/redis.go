@@ -0,0 +1,379 @@
+// Package redisstore implements loader.RemoteStore on top of Redis, with a minimal RESP client.
+//
+//	driver := loader.RemoteCache[User](redisstore.New("localhost:6379"))
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is loader.RemoteStore backed by Redis
+type Store struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+// Option configures Store
+type Option func(s *Store)
+
+// WithPassword authenticates every connection with password
+func WithPassword(password string) Option {
+	return func(s *Store) {
+		s.password = password
+	}
+}
+
+// WithDB selects the database of every connection
+func WithDB(db int) Option {
+	return func(s *Store) {
+		s.db = db
+	}
+}
+
+// WithPoolSize sets how many idle connections are kept, the default is 8
+func WithPoolSize(size int) Option {
+	return func(s *Store) {
+		s.pool = make(chan *conn, size)
+	}
+}
+
+// WithTimeout sets the dial, read, and write timeout, the default is 5 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Store) {
+		s.timeout = timeout
+	}
+}
+
+// New creates Store that connects to addr
+func New(addr string, options ...Option) *Store {
+	s := &Store{addr: addr, timeout: 5 * time.Second, pool: make(chan *conn, 8)}
+	for _, o := range options {
+		o(s)
+	}
+	return s
+}
+
+// Error is an error reply of Redis
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// Get implements loader.RemoteStore
+func (s *Store) Get(key string) ([]byte, error) {
+	reply, err := s.Do("GET", key)
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	return toBytes(reply)
+}
+
+// Set implements loader.RemoteStore
+func (s *Store) Set(key string, data []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, data}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := s.Do(args...)
+	return err
+}
+
+// SetNX sets key only if it doesn't exist, it returns false if it does. It implements loader.LeaseStore.
+func (s *Store) SetNX(key string, data []byte, ttl time.Duration) (bool, error) {
+	args := []interface{}{"SET", key, data, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	reply, err := s.Do(args...)
+	return reply != nil, err
+}
+
+// Delete implements loader.RemoteStore
+func (s *Store) Delete(key string) error {
+	_, err := s.Do("DEL", key)
+	return err
+}
+
+// Ping implements loader.Pinger
+func (s *Store) Ping(ctx context.Context) error {
+	_, err := s.Do("PING")
+	return err
+}
+
+// Time returns the clock of the Redis server
+func (s *Store) Time() (time.Time, error) {
+	reply, err := s.Do("TIME")
+	if err != nil {
+		return time.Time{}, err
+	}
+	parts, ok := reply.([]interface{})
+	if !ok || len(parts) != 2 {
+		return time.Time{}, fmt.Errorf("redis: unexpected TIME reply %v", reply)
+	}
+	var values [2]int64
+	for i, part := range parts {
+		b, err := toBytes(part)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if values[i], err = strconv.ParseInt(string(b), 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(values[0], values[1]*int64(time.Microsecond)), nil
+}
+
+// Clock returns the clock of the Redis server to be used with loader.WithClock.
+// It reads the offset from the local clock every refresh, so it doesn't cost a round trip per call.
+// While the server is unavailable, the last known offset is used.
+func (s *Store) Clock(refresh time.Duration) func() time.Time {
+	var mutex sync.Mutex
+	var offset time.Duration
+	var synced time.Time
+	return func() time.Time {
+		mutex.Lock()
+		defer mutex.Unlock()
+		now := time.Now()
+		if synced.IsZero() || now.Sub(synced) >= refresh {
+			synced = now
+			if server, err := s.Time(); err == nil {
+				// the reply is read after a round trip, split it evenly
+				offset = server.Sub(now) - time.Since(now)/2
+			}
+		}
+		return now.Add(offset)
+	}
+}
+
+// Keys returns the keys that matches the glob pattern, using SCAN so Redis isn't blocked
+func (s *Store) Keys(pattern string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := s.Do("SCAN", cursor, "MATCH", pattern, "COUNT", "1000")
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+		}
+		next, err := toBytes(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		batch, _ := parts[1].([]interface{})
+		for _, k := range batch {
+			b, err := toBytes(k)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, string(b))
+		}
+		if cursor = string(next); cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// TTL returns the remaining time to live of key, or zero if it never expires or doesn't exist
+func (s *Store) TTL(key string) (time.Duration, error) {
+	reply, err := s.Do("PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	ms, ok := reply.(int64)
+	if !ok || ms < 0 {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Do sends a command and returns its reply.
+// Arguments are strings or []byte, replies are []byte, int64, string, []interface{}, or nil.
+func (s *Store) Do(args ...interface{}) (interface{}, error) {
+	c, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(s.timeout, args...)
+	if err != nil {
+		var redisErr Error
+		if !errors.As(err, &redisErr) {
+			// the connection state is unknown after I/O errors
+			c.Close()
+			return nil, err
+		}
+	}
+	s.put(c)
+	return reply, err
+}
+
+func (s *Store) get() (*conn, error) {
+	select {
+	case c := <-s.pool:
+		return c, nil
+	default:
+	}
+	return s.connect()
+}
+
+// connect dials a new connection, authenticated and with the database selected
+func (s *Store) connect() (*conn, error) {
+	c, err := dial(s.addr, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if s.password != "" {
+		if _, err := c.do(s.timeout, "AUTH", s.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.do(s.timeout, "SELECT", strconv.Itoa(s.db)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *Store) put(c *conn) {
+	select {
+	case s.pool <- c:
+	default:
+		c.Close()
+	}
+}
+
+// Close closes the idle connections
+func (s *Store) Close() error {
+	for {
+		select {
+		case c := <-s.pool:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func dial(addr string, timeout time.Duration) (*conn, error) {
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(c), nil
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{Conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+}
+
+func (c *conn) do(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	if timeout > 0 {
+		c.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+// send writes a command as RESP array of bulk strings
+func (c *conn) send(args ...interface{}) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(c.w, "$%d\r\n", len(b))
+		c.w.Write(b)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// receive reads one RESP reply
+func (c *conn) receive() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.receive(); err != nil {
+				var redisErr Error
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
+
+func toBytes(reply interface{}) ([]byte, error) {
+	switch v := reply.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+}
@@ -0,0 +1,211 @@
+package redisstore
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer speaks enough RESP to test Store
+type fakeServer struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+	ttl   map[string]time.Duration
+	// clockOffset is how far the clock of TIME is ahead
+	clockOffset time.Duration
+	// subscribers receive the keyspace notifications of their pattern
+	subscribers map[*conn]string
+}
+
+func startFakeServer(t *testing.T, options ...func(s *fakeServer)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &fakeServer{data: map[string][]byte{}, ttl: map[string]time.Duration{}, subscribers: map[*conn]string{}}
+	for _, o := range options {
+		o(s)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(newConn(c))
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s *fakeServer) serve(c *conn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.subscribers, c)
+		s.mutex.Unlock()
+		c.Close()
+	}()
+	for {
+		req, err := c.receive()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range req.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		if args[0] == "PSUBSCRIBE" {
+			s.subscribe(c, args[1])
+			continue
+		}
+		reply := s.handle(args)
+		s.mutex.Lock()
+		c.w.WriteString(reply)
+		c.w.Flush()
+		s.mutex.Unlock()
+	}
+}
+
+func (s *fakeServer) subscribe(c *conn, pattern string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscribers[c] = pattern
+	fmt.Fprintf(c.w, "*3\r\n$10\r\npsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(pattern), pattern)
+	c.w.Flush()
+}
+
+// notify publishes the keyspace notification of db 0, s.mutex must be held
+func (s *fakeServer) notify(key, event string) {
+	channel := "__keyspace@0__:" + key
+	for c, pattern := range s.subscribers {
+		if ok, _ := path.Match(pattern, channel); ok {
+			fmt.Fprintf(c.w, "*4\r\n$8\r\npmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n",
+				len(pattern), pattern, len(channel), channel, len(event), event)
+			c.w.Flush()
+		}
+	}
+}
+
+func (s *fakeServer) handle(args []string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch args[0] {
+	case "GET":
+		v, ok := s.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch args[i] {
+			case "NX":
+				if _, ok := s.data[args[1]]; ok {
+					return "$-1\r\n"
+				}
+			case "PX":
+				var ms int64
+				fmt.Sscan(args[i+1], &ms)
+				ttl = time.Duration(ms) * time.Millisecond
+				i++
+			}
+		}
+		s.data[args[1]] = []byte(args[2])
+		delete(s.ttl, args[1])
+		if ttl > 0 {
+			s.ttl[args[1]] = ttl
+		}
+		s.notify(args[1], "set")
+		return "+OK\r\n"
+	case "DEL":
+		delete(s.data, args[1])
+		s.notify(args[1], "del")
+		return ":1\r\n"
+	case "PTTL":
+		if ttl, ok := s.ttl[args[1]]; ok {
+			return fmt.Sprintf(":%d\r\n", ttl.Milliseconds())
+		}
+		return ":-1\r\n"
+	case "SCAN":
+		var keys []string
+		for k := range s.data {
+			if ok, _ := path.Match(args[3], k); ok {
+				keys = append(keys, k)
+			}
+		}
+		reply := fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+		for _, k := range keys {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(k), k)
+		}
+		return reply
+	case "TIME":
+		now := time.Now().Add(s.clockOffset)
+		sec, usec := fmt.Sprint(now.Unix()), fmt.Sprint(now.Nanosecond()/1000)
+		return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(sec), sec, len(usec), usec)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := New(startFakeServer(t))
+	defer s.Close()
+
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("users:a", []byte("1\r\n2"), 0))
+	assert.NoError(t, s.Set("users:b", []byte("2"), time.Minute))
+	assert.NoError(t, s.Set("groups:a", []byte("3"), 0))
+	v, err = s.Get("users:a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1\r\n2"), v)
+
+	keys, err := s.Keys("users:*")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"users:a", "users:b"}, keys)
+
+	ttl, _ := s.TTL("users:b")
+	assert.Equal(t, time.Minute, ttl)
+	ttl, _ = s.TTL("users:a")
+	assert.Zero(t, ttl)
+
+	ok, err := s.SetNX("users:b", []byte("other"), time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok, "SetNX of existing key must fail")
+	ok, _ = s.SetNX("lease:a", []byte("1"), time.Second)
+	assert.True(t, ok)
+	ttl, _ = s.TTL("lease:a")
+	assert.Equal(t, time.Second, ttl)
+
+	assert.NoError(t, s.Delete("users:a"))
+	v, _ = s.Get("users:a")
+	assert.Nil(t, v)
+
+	_, err = s.Do("FLUSHALL")
+	assert.Equal(t, Error("ERR unknown command"), err)
+	v, err = s.Get("users:b")
+	assert.NoError(t, err, "connection must be reusable after error reply")
+	assert.Equal(t, []byte("2"), v)
+}
+
+func TestClock(t *testing.T) {
+	s := New(startFakeServer(t, func(s *fakeServer) { s.clockOffset = time.Hour }))
+	defer s.Close()
+
+	server, err := s.Time()
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), server, time.Second)
+
+	clock := s.Clock(time.Minute)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), clock(), time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), clock(), time.Second, "cached offset must be used")
+}
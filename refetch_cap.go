@@ -0,0 +1,33 @@
+package loader
+
+import "context"
+
+// WithMaxRefetchGoroutines caps the number of refetch goroutines running at once to n.
+// Beyond the cap, expired items keep serving their stale value until a goroutine finishes,
+// the next Load of the item tries again. It's a safety valve against runaway goroutine growth
+// when the origin slows down, WithRefreshWorkers queues the refreshes instead.
+func WithMaxRefetchGoroutines(n int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.maxRefetchGoroutines = n
+	})
+}
+
+// acquireRefetchSlot reserves a refetch goroutine, it returns false if WithMaxRefetchGoroutines is reached
+func (l *Loader[Key, Value]) acquireRefetchSlot() bool {
+	for {
+		live := l.counters.refetchGoroutines.Load()
+		if l.maxRefetchGoroutines > 0 && live >= int64(l.maxRefetchGoroutines) {
+			l.counters.refreshesSkipped.Add(1)
+			return false
+		}
+		if l.counters.refetchGoroutines.CompareAndSwap(live, live+1) {
+			return true
+		}
+	}
+}
+
+// refetchGoroutine runs refetch in the goroutine reserved by acquireRefetchSlot
+func (l *Loader[Key, Value]) refetchGoroutine(ctx context.Context, key Key, item *cacheItem[Value]) {
+	defer l.counters.refetchGoroutines.Add(-1)
+	l.refetch(ctx, key, item)
+}
@@ -0,0 +1,70 @@
+package loader
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// LeaseStore holds the refresh leases of WithRefreshLease, redisstore.Store implements it with SET NX
+type LeaseStore interface {
+	// SetNX sets key for ttl only if it doesn't exist, it returns false if it does
+	SetNX(key string, data []byte, ttl time.Duration) (bool, error)
+	// Delete releases the lease after the refresh
+	Delete(key string) error
+}
+
+// WithRefreshLease makes only one process of the fleet refresh an expired key in background, for loaders sharing
+// a remote driver like RemoteCache. The process that sets the lease key in store refreshes the key and writes it to
+// the driver, the others keep serving the stale value until they read the refreshed one.
+// The lease is taken after WithRateLimit lets the refresh start, and released when the refresh finishes.
+// ttl bounds how long a process that dies while refreshing blocks the others, it must cover a refresh.
+// It's clamped to the time the item is served stale: until its hard TTL, or its TTL without WithHardTTL.
+// Foreground fetches of missing keys don't take the lease. If store fails, the key is refreshed anyway.
+func WithRefreshLease(store LeaseStore, ttl time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.leaseStore = store
+		cfg.leaseTTL = ttl
+	})
+}
+
+// leaseHolder is stored in the lease keys, to tell which process holds them
+var leaseHolder = func() []byte {
+	host, _ := os.Hostname()
+	return []byte(fmt.Sprintf("%s:%d", host, os.Getpid()))
+}()
+
+// acquireLease returns true if this process must refresh the item of key, see WithRefreshLease.
+// release must be called after the refresh if it returns true.
+func (l *Loader[Key, Value]) acquireLease(key Key, item *cacheItem[Value]) (release func(), ok bool) {
+	if l.leaseStore == nil {
+		return func() {}, true
+	}
+	leaseKey := "lease:" + remoteKey(l.driverKey(key))
+	acquired, err := l.leaseStore.SetNX(leaseKey, leaseHolder, l.leaseTTLOf(item))
+	if err != nil {
+		// refreshing in every process is better than never refreshing while the store is down
+		return func() {}, true
+	}
+	if !acquired {
+		return nil, false
+	}
+	return func() { l.leaseStore.Delete(leaseKey) }, true
+}
+
+// leaseTTLOf clamps the lease TTL to the time the item is served stale
+func (l *Loader[Key, Value]) leaseTTLOf(item *cacheItem[Value]) time.Duration {
+	ttl := l.leaseTTL
+	p := item.payload.Load()
+	if p == nil {
+		return ttl
+	}
+	window := p.expire.Sub(p.fetchedAt)
+	if !p.hardExpire.IsZero() {
+		window = time.Until(p.hardExpire)
+	}
+	if window > 0 && window < ttl {
+		return window
+	}
+	return ttl
+}
@@ -0,0 +1,81 @@
+package loader
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// refreshQueue runs background refreshes with bounded concurrency.
+// When there are more pending refreshes than workers, the most accessed keys are refreshed first.
+type refreshQueue[Key comparable, Value any] struct {
+	mutex   sync.Mutex
+	tasks   refreshTasks[Key, Value]
+	workers int
+	max     int
+	run     func(ctx context.Context, key Key, item *cacheItem[Value])
+}
+
+func newRefreshQueue[Key comparable, Value any](max int, run func(ctx context.Context, key Key, item *cacheItem[Value])) *refreshQueue[Key, Value] {
+	return &refreshQueue[Key, Value]{max: max, run: run}
+}
+
+// push schedules the refresh, starting a new worker if the pool is not full
+func (q *refreshQueue[Key, Value]) push(ctx context.Context, key Key, item *cacheItem[Value]) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	heap.Push(&q.tasks, &refreshTask[Key, Value]{
+		ctx:  ctx,
+		key:  key,
+		item: item,
+		hits: item.hits.Load(),
+	})
+	if q.workers < q.max {
+		q.workers++
+		go q.work()
+	}
+}
+
+// work runs pending refreshes until the queue is empty
+func (q *refreshQueue[Key, Value]) work() {
+	for {
+		q.mutex.Lock()
+		if q.tasks.Len() == 0 {
+			q.workers--
+			q.mutex.Unlock()
+			return
+		}
+		task := heap.Pop(&q.tasks).(*refreshTask[Key, Value])
+		q.mutex.Unlock()
+
+		q.run(task.ctx, task.key, task.item)
+	}
+}
+
+type refreshTask[Key comparable, Value any] struct {
+	ctx  context.Context
+	key  Key
+	item *cacheItem[Value]
+	hits uint64
+}
+
+// refreshTasks implements heap.Interface, ordered by hits descending
+type refreshTasks[Key comparable, Value any] []*refreshTask[Key, Value]
+
+func (t refreshTasks[Key, Value]) Len() int           { return len(t) }
+func (t refreshTasks[Key, Value]) Less(i, j int) bool { return t[i].hits > t[j].hits }
+func (t refreshTasks[Key, Value]) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func (t *refreshTasks[Key, Value]) Push(x any) {
+	*t = append(*t, x.(*refreshTask[Key, Value]))
+}
+
+func (t *refreshTasks[Key, Value]) Pop() any {
+	old := *t
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = nil
+	*t = old[:n-1]
+	return task
+}
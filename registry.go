@@ -0,0 +1,174 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ManagedLoader is implemented by Loader of any type, it's what Registry needs to manage it
+type ManagedLoader interface {
+	InvalidateAll() error
+	Stats() Stats
+	WarmUp(ctx context.Context) error
+	Healthy(ctx context.Context) error
+	Close() error
+}
+
+var _ ManagedLoader = &Loader[string, string]{}
+
+// Registry manages named loaders of different types, sharing one cache driver.
+type Registry struct {
+	driver CacheDriver
+
+	mutex   sync.RWMutex
+	loaders map[string]ManagedLoader
+}
+
+// DefaultRegistry holds the loaders created with WithName, until they're closed.
+// Its loaders don't share a driver.
+var DefaultRegistry = NewRegistry(nil)
+
+// NewRegistry creates Registry whose loaders share driver
+func NewRegistry(driver CacheDriver) *Registry {
+	return &Registry{driver: driver, loaders: map[string]ManagedLoader{}}
+}
+
+// Register adds the loader with given name, replacing the existing one
+func (r *Registry) Register(name string, l ManagedLoader) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.loaders[name] = l
+}
+
+// Unregister removes the loader with given name
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.loaders, name)
+}
+
+// unregister removes the loader with given name, unless it's been replaced by other loader
+func (r *Registry) unregister(name string, l ManagedLoader) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.loaders[name] == l {
+		delete(r.loaders, name)
+	}
+}
+
+// Get returns the loader with given name
+func (r *Registry) Get(name string) (ManagedLoader, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	l, ok := r.loaders[name]
+	return l, ok
+}
+
+// Names returns the names of registered loaders, sorted
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.loaders))
+	for name := range r.loaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegistryLoader returns the loader with given name, creating it if it doesn't exist.
+// New loaders are named and namespaced by their name, and use the registry driver if it has one. Options can override them.
+// It panics if the existing loader has different types.
+func RegistryLoader[Key comparable, Value any](r *Registry, name string, fn Fetcher[Key, Value], ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.loaders[name]; ok {
+		l, ok := existing.(*Loader[Key, Value])
+		if !ok {
+			panic(fmt.Errorf("loader %q is registered with type %T", name, existing))
+		}
+		return l
+	}
+
+	defaults := []Option{WithNamespace(name), optionFunc(func(cfg *config) { cfg.name = name })}
+	if r.driver != nil {
+		defaults = append(defaults, WithDriver(r.driver))
+	}
+	options = append(defaults, options...)
+	l := New(fn, ttl, options...)
+	r.loaders[name] = l
+	return l
+}
+
+// all returns every registered loader with its name
+func (r *Registry) all() map[string]ManagedLoader {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	loaders := make(map[string]ManagedLoader, len(r.loaders))
+	for name, l := range r.loaders {
+		loaders[name] = l
+	}
+	return loaders
+}
+
+// Stats returns the stats of every loader by name
+func (r *Registry) Stats() map[string]Stats {
+	stats := map[string]Stats{}
+	for name, l := range r.all() {
+		stats[name] = l.Stats()
+	}
+	return stats
+}
+
+// TotalStats sums the stats of every loader
+func (r *Registry) TotalStats() Stats {
+	var total Stats
+	for _, stats := range r.Stats() {
+		total.add(stats)
+	}
+	if c, ok := r.driver.(CostReporter); ok {
+		total.Cost = c.Cost()
+	}
+	return total
+}
+
+// InvalidateAll invalidates every loader
+func (r *Registry) InvalidateAll() error {
+	return r.each(func(l ManagedLoader) error { return l.InvalidateAll() })
+}
+
+// WarmUp warms every loader up
+func (r *Registry) WarmUp(ctx context.Context) error {
+	return r.each(func(l ManagedLoader) error { return l.WarmUp(ctx) })
+}
+
+// Healthy checks every loader, it returns the first unhealthy one
+func (r *Registry) Healthy(ctx context.Context) error {
+	return r.each(func(l ManagedLoader) error { return l.Healthy(ctx) })
+}
+
+// Close closes every loader
+func (r *Registry) Close() error {
+	return r.each(func(l ManagedLoader) error { return l.Close() })
+}
+
+// each calls fn for every loader, it returns the first error after all loaders are processed
+func (r *Registry) each(fn func(l ManagedLoader) error) error {
+	var firstErr error
+	for _, name := range r.Names() {
+		l, ok := r.Get(name)
+		if !ok {
+			continue
+		}
+		if err := fn(l); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,67 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(InMemoryCache())
+	users := RegistryLoader(r, "users", func(ctx context.Context, id int) (string, error) {
+		return fmt.Sprint("user ", id), nil
+	}, time.Minute, WithWarmKeys(func(ctx context.Context) ([]int, error) {
+		return []int{1, 2}, nil
+	}))
+	counts := RegistryLoader(r, "counts", func(ctx context.Context, id int) (int, error) {
+		return id * 10, nil
+	}, time.Minute)
+
+	assert.Same(t, users, RegistryLoader(r, "users", func(ctx context.Context, id int) (string, error) {
+		return "", nil
+	}, time.Minute), "same name must return the existing loader")
+	assert.Panics(t, func() {
+		RegistryLoader(r, "counts", func(ctx context.Context, id int) (string, error) { return "", nil }, time.Minute)
+	}, "different types must panic")
+	assert.Equal(t, []string{"counts", "users"}, r.Names())
+
+	assert.NoError(t, r.WarmUp(context.Background()))
+	val, _ := users.Load(1)
+	assert.Equal(t, "user 1", val, "shared driver must not collide between loaders")
+	count, _ := counts.Load(1)
+	assert.Equal(t, 10, count)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 3}, r.TotalStats())
+	assert.NoError(t, r.InvalidateAll())
+	_, info, _ := users.LoadWithInfo(2)
+	assert.False(t, info.Cached, "invalidated item must be fetched again")
+	assert.NoError(t, r.Close())
+}
+
+func TestWithName(t *testing.T) {
+	l := New(func(ctx context.Context, id int) (int, error) {
+		return id, nil
+	}, time.Minute, WithName("test-with-name"))
+	assert.Equal(t, "test-with-name", l.Name())
+	registered, ok := DefaultRegistry.Get("test-with-name")
+	assert.True(t, ok)
+	assert.Same(t, l, registered)
+
+	l.Load(1)
+	assert.Equal(t, uint64(1), DefaultRegistry.Stats()["test-with-name"].Misses)
+
+	l.Close()
+	_, ok = DefaultRegistry.Get("test-with-name")
+	assert.False(t, ok, "closed loader must be unregistered")
+
+	r := NewRegistry(nil)
+	users := RegistryLoader(r, "users", func(ctx context.Context, id int) (int, error) {
+		return id, nil
+	}, time.Minute)
+	assert.Equal(t, "users", users.Name())
+	_, ok = DefaultRegistry.Get("users")
+	assert.False(t, ok, "loaders of other registry must not be added to DefaultRegistry")
+}
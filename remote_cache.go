@@ -0,0 +1,500 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// RemoteStore is a byte oriented storage outside of the process memory, such as redis or disk.
+// Get returns nil data without error if the key doesn't exist.
+// ttl of Set is zero if the value never expires.
+type RemoteStore interface {
+	Get(key string) ([]byte, error)
+	Set(key string, data []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// Codec serializes values for RemoteStore
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+// JSONCodec is Codec that uses encoding/json
+var JSONCodec Codec = jsonCodec{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ErrDecrypt is reported when a remote value can't be decrypted or has been tampered with
+var ErrDecrypt = errors.New("loader: can not decrypt remote value")
+
+type remoteCache[Value any] struct {
+	store   RemoteStore
+	codec   Codec
+	aead    cipher.AEAD
+	onError func(err error)
+	// failover is nil unless WithFailover is used
+	failover *failover
+	// clock is the time source of the records, nil means the local clock
+	clock func() time.Time
+	// edge is set if store is EdgeStore
+	edge *edgeWrites
+	// lag extends the soft expiry of the records read, see WithLagTolerance
+	lag time.Duration
+
+	// decoded holds the last *decodedItem of the keys, nil if WithDecodedItems is 0
+	decoded *lru.Cache
+	// aliasing is true if codec is aliasingCodec, the decoded values reference the data of the store
+	aliasing bool
+
+	// pending holds items that are still loading, so they are shared within the process
+	mutex   sync.Mutex
+	pending map[interface{}]*cacheItem[Value]
+}
+
+// decodedItem is the item decoded from data. Get returns the same item while the store holds the same data,
+// so the state of the item, like the refresh in flight, is shared by the loads of the process.
+type decodedItem[Value any] struct {
+	data []byte
+	item *cacheItem[Value]
+}
+
+// RemoteCacheOption configures RemoteCache
+type RemoteCacheOption func(c *remoteCacheConfig)
+
+type remoteCacheConfig struct {
+	codec      Codec
+	aead       cipher.AEAD
+	onError    func(err error)
+	failover   *failover
+	onFailover func(available bool, err error)
+	clock      func() time.Time
+	decoded    int
+
+	lagTolerance bool
+}
+
+// defaultDecodedItems is the default of WithDecodedItems
+const defaultDecodedItems = 4096
+
+// WithCodec sets the Codec of RemoteCache, the default is JSONCodec
+func WithCodec(codec Codec) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.codec = codec
+	}
+}
+
+// WithEncryption encrypts and authenticates values before they are written to the RemoteStore.
+// The key is used as additional data, so a value can't be swapped to other key.
+func WithEncryption(aead cipher.AEAD) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.aead = aead
+	}
+}
+
+// WithRemoteErrorHandler sets the function that is called when the RemoteStore or Codec fails.
+// Failed reads are treated as cache miss, and failed writes are dropped.
+func WithRemoteErrorHandler(onError func(err error)) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.onError = onError
+	}
+}
+
+// WithDecodedItems sets how many decoded items RemoteCache keeps in memory, the default is 4096.
+// An item is reused while the store holds the same data for its key, so concurrent stale loads start one refresh
+// instead of one each, and the data isn't decoded again. 0 decodes the data on every Get.
+func WithDecodedItems(size int) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.decoded = size
+	}
+}
+
+// WithClock sets the clock of the times written in the records, like the clock of the RemoteStore server,
+// so the expiry of records shared by machines doesn't depend on how far their local clocks are skewed.
+// The times are converted from the local clock when they're written, and back when they're read.
+// Without it, a record whose fetch time is ahead of the local clock is shifted back to now.
+func WithClock(now func() time.Time) RemoteCacheOption {
+	return func(c *remoteCacheConfig) {
+		c.clock = now
+	}
+}
+
+// RemoteCache creates cache driver that stores serialized values in store.
+// Only successful fetches are stored, errors are fetched again by the next load.
+// Value must be serializable by the codec.
+func RemoteCache[Value any](store RemoteStore, options ...RemoteCacheOption) CacheDriver {
+	cfg := &remoteCacheConfig{codec: JSONCodec, decoded: defaultDecodedItems}
+	for _, o := range options {
+		o(cfg)
+	}
+	if cfg.failover != nil {
+		cfg.failover.onChange = cfg.onFailover
+	}
+	c := &remoteCache[Value]{
+		store:    store,
+		codec:    cfg.codec,
+		aead:     cfg.aead,
+		onError:  cfg.onError,
+		failover: cfg.failover,
+		clock:    cfg.clock,
+		edge:     newEdgeWrites(store, cfg.onError),
+		pending:  map[interface{}]*cacheItem[Value]{},
+	}
+	if c.edge != nil && cfg.lagTolerance {
+		c.lag = c.edge.limits.Lag
+	}
+	if cfg.decoded > 0 {
+		c.decoded, _ = lru.New(cfg.decoded)
+	}
+	_, c.aliasing = cfg.codec.(aliasingCodec)
+	return c
+}
+
+// remoteRecord is the serialized form of payload
+type remoteRecord[Value any] struct {
+	Value      Value     `json:"v"`
+	FetchedAt  time.Time `json:"f"`
+	Expire     time.Time `json:"e"`
+	HardExpire time.Time `json:"h,omitempty"`
+	Hash       uint64    `json:"m,omitempty"`
+	Tag        string    `json:"t,omitempty"`
+	Version    uint64    `json:"n,omitempty"`
+	Stamp      uint64    `json:"s,omitempty"`
+}
+
+// writeThrough implements writeThroughDriver
+func (c *remoteCache[Value]) writeThrough() {}
+
+// Add implements CacheDriver
+func (c *remoteCache[Value]) Add(key interface{}, value interface{}) {
+	if local := c.local(); local != nil {
+		local.Add(key, value)
+		return
+	}
+	item := value.(*cacheItem[Value])
+	p := item.payload.Load()
+
+	c.mutex.Lock()
+	if p == nil {
+		c.pending[key] = item
+	} else if c.pending[key] == item {
+		delete(c.pending, key)
+	}
+	c.mutex.Unlock()
+
+	if p == nil || p.err != nil {
+		return
+	}
+	data, err := c.write(remoteKey(key), p)
+	if err != nil {
+		c.report(err)
+		if c.failover != nil && isStoreError(err) {
+			c.failover.fail(err).Add(key, value)
+		}
+		return
+	}
+	if !c.aliasing {
+		// with BlobCodec, keep the item decoded by the next Get, whose value is in the store instead of the heap
+		c.remember(key, data, item)
+	}
+	c.failover.recovered()
+}
+
+// Get implements CacheDriver
+func (c *remoteCache[Value]) Get(key interface{}) (interface{}, bool) {
+	if local := c.local(); local != nil {
+		return local.Get(key)
+	}
+	c.mutex.Lock()
+	item, ok := c.pending[key]
+	c.mutex.Unlock()
+	if ok {
+		return item, true
+	}
+
+	rk := remoteKey(key)
+	data, err := c.readData(rk)
+	if err != nil {
+		err = &storeError{err}
+		c.report(err)
+		if c.failover != nil {
+			c.failover.fail(err)
+		}
+		return nil, false
+	}
+	c.failover.recovered()
+	if data == nil {
+		c.forget(key)
+		return nil, false
+	}
+	if item := c.decodedItem(key, data); item != nil {
+		return item, true
+	}
+	r, err := c.decode(rk, data)
+	if err != nil {
+		c.report(err)
+		return nil, false
+	}
+	item = newCacheItem[Value]()
+	item.store(c.payload(r))
+	if !c.aliasing {
+		// the store may reuse data
+		data = append([]byte(nil), data...)
+	}
+	c.remember(key, data, item)
+	return item, true
+}
+
+// decodedItem returns the item decoded from the same data, if it's still kept
+func (c *remoteCache[Value]) decodedItem(key interface{}, data []byte) *cacheItem[Value] {
+	if c.decoded == nil {
+		return nil
+	}
+	if v, ok := c.decoded.Get(key); ok {
+		if d := v.(*decodedItem[Value]); bytes.Equal(d.data, data) {
+			return d.item
+		}
+	}
+	return nil
+}
+
+// remember keeps the item decoded from data, the caller must own data
+func (c *remoteCache[Value]) remember(key interface{}, data []byte, item *cacheItem[Value]) {
+	if c.decoded != nil && data != nil {
+		c.decoded.Add(key, &decodedItem[Value]{data: data, item: item})
+	}
+}
+
+func (c *remoteCache[Value]) forget(key interface{}) {
+	if c.decoded != nil {
+		c.decoded.Remove(key)
+	}
+}
+
+// Remove implements Remover
+func (c *remoteCache[Value]) Remove(key interface{}) {
+	if local := c.local(); local != nil {
+		if r, ok := local.(Remover); ok {
+			r.Remove(key)
+		}
+		c.failover.deleteLater(key)
+		return
+	}
+	c.mutex.Lock()
+	delete(c.pending, key)
+	c.mutex.Unlock()
+	c.forget(key)
+
+	rk := remoteKey(key)
+	if c.edge != nil {
+		c.edge.deleted(rk)
+	}
+	if err := c.store.Delete(rk); err != nil {
+		c.report(err)
+		if c.failover != nil {
+			c.failover.fail(err)
+			c.failover.deleteLater(key)
+		}
+		return
+	}
+	c.failover.recovered()
+}
+
+// readData reads the data of key, or the write of EdgeStore that is still delayed
+func (c *remoteCache[Value]) readData(key string) ([]byte, error) {
+	if c.edge != nil {
+		if data, ok := c.edge.pendingData(key); ok {
+			return data, nil
+		}
+	}
+	return c.store.Get(key)
+}
+
+// local returns the local driver of WithFailover while the store is down.
+// Before the store is tried again, the keys removed while it was down are deleted from it,
+// so their old values don't come back.
+func (c *remoteCache[Value]) local() CacheDriver {
+	local, retry := c.failover.local()
+	if !retry {
+		return local
+	}
+	err := c.failover.replay(func(key interface{}) error {
+		return c.store.Delete(remoteKey(key))
+	})
+	if err != nil {
+		err = &storeError{err}
+		c.report(err)
+		return c.failover.fail(err)
+	}
+	return nil
+}
+
+// write stores the payload, it returns the data written
+func (c *remoteCache[Value]) write(key string, p *payload[Value]) ([]byte, error) {
+	offset := c.clockOffset()
+	data, err := c.codec.Marshal(&remoteRecord[Value]{
+		Value:      p.value,
+		FetchedAt:  shiftTime(p.fetchedAt, offset),
+		Expire:     shiftTime(p.expire, offset),
+		HardExpire: shiftTime(p.hardExpire, offset),
+		Hash:       p.hash,
+		Tag:        p.tag,
+		Version:    p.version,
+		Stamp:      p.stamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(data)+c.aead.Overhead())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		data = c.aead.Seal(nonce, nonce, data, []byte(key))
+	}
+
+	var ttl time.Duration
+	if !p.hardExpire.IsZero() {
+		ttl = time.Until(p.hardExpire)
+	}
+	set := c.store.Set
+	if c.edge != nil {
+		set = c.edge.set
+	}
+	if err := set(key, data, ttl); err != nil {
+		return nil, &storeError{err}
+	}
+	return data, nil
+}
+
+// payload converts the record to the local clock
+func (c *remoteCache[Value]) payload(r *remoteRecord[Value]) *payload[Value] {
+	offset := -c.clockOffset()
+	// a value can't be fetched in the future, the clock of the writer is ahead
+	if ahead := time.Until(r.FetchedAt.Add(offset)); ahead > 0 {
+		offset -= ahead
+	}
+	p := &payload[Value]{
+		value:      r.Value,
+		fetchedAt:  shiftTime(r.FetchedAt, offset),
+		expire:     shiftTime(r.Expire, offset),
+		hardExpire: shiftTime(r.HardExpire, offset),
+		hash:       r.Hash,
+		tag:        r.Tag,
+		version:    r.Version,
+		stamp:      r.Stamp,
+	}
+	if c.lag > 0 {
+		p.expire = shiftTime(p.expire, c.lag)
+		if !p.hardExpire.IsZero() && p.expire.After(p.hardExpire) {
+			p.expire = p.hardExpire
+		}
+	}
+	return p
+}
+
+// clockOffset returns how far the clock of WithClock is ahead of the local clock
+func (c *remoteCache[Value]) clockOffset() time.Duration {
+	if c.clock == nil {
+		return 0
+	}
+	return time.Until(c.clock())
+}
+
+// shiftTime adds d to t, keeping the zero time
+func shiftTime(t time.Time, d time.Duration) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.Add(d)
+}
+
+func (c *remoteCache[Value]) decode(key string, data []byte) (*remoteRecord[Value], error) {
+	var err error
+	if c.aead != nil {
+		size := c.aead.NonceSize()
+		if len(data) < size {
+			return nil, ErrDecrypt
+		}
+		data, err = c.aead.Open(nil, data[:size], data[size:], []byte(key))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+	}
+
+	var r remoteRecord[Value]
+	if err := c.codec.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// RemoteEntry is an item written by RemoteCache, errors are never written so its Err is always nil
+type RemoteEntry[Value any] struct {
+	Value Value
+	EntryMeta
+}
+
+// DecodeRemoteEntry decodes the data of storeKey written by RemoteCache with the same options.
+// It's meant for tools that inspect remote caches, use json.RawMessage as Value to skip decoding the value.
+func DecodeRemoteEntry[Value any](storeKey string, data []byte, options ...RemoteCacheOption) (*RemoteEntry[Value], error) {
+	c := RemoteCache[Value](nil, options...).(*remoteCache[Value])
+	r, err := c.decode(storeKey, data)
+	if err != nil {
+		return nil, err
+	}
+	p := c.payload(r)
+	return &RemoteEntry[Value]{Value: p.value, EntryMeta: p.meta()}, nil
+}
+
+func (c *remoteCache[Value]) report(err error) {
+	if c.onError != nil {
+		c.onError(err)
+	}
+}
+
+// remoteKey converts driver key to the key of RemoteStore
+func remoteKey(key interface{}) string {
+	if nk, ok := key.(namespacedKey); ok {
+		return nk.namespace + ":" + fmt.Sprint(nk.key)
+	}
+	return fmt.Sprint(key)
+}
+
+// writeThroughDriver is implemented by drivers that serialize the item when it's added,
+// so the loader re-adds the item after every fetch
+type writeThroughDriver interface {
+	writeThrough()
+}
+
+// valueSize implements valueSizer
+func (c *remoteCache[Value]) valueSize(value interface{}) (int64, error) {
+	data, err := c.codec.Marshal(value)
+	return int64(len(data)), err
+}
+
+// Ping implements Pinger, it pings the store if the store implements Pinger
+func (c *remoteCache[Value]) Ping(ctx context.Context) error {
+	if p, ok := c.store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
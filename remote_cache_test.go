@@ -0,0 +1,423 @@
+package loader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+	// expires is the expiry of the keys set by SetNX
+	expires map[string]time.Time
+}
+
+func (s *memoryStore) Get(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memoryStore) Set(key string, data []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memoryStore) SetNX(key string, data []byte, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.data[key]; ok && (s.expires[key].IsZero() || time.Now().Before(s.expires[key])) {
+		return false, nil
+	}
+	s.data[key] = data
+	if s.expires == nil {
+		s.expires = map[string]time.Time{}
+	}
+	s.expires[key] = time.Time{}
+	if ttl > 0 {
+		s.expires[key] = time.Now().Add(ttl)
+	}
+	return true, nil
+}
+
+func TestRemoteCacheEncryption(t *testing.T) {
+	block, _ := aes.NewCipher(bytes.Repeat([]byte{1}, 32))
+	aead, _ := cipher.NewGCM(block)
+	store := &memoryStore{data: map[string][]byte{}}
+	var errs []error
+	driver := RemoteCache[string](store, WithEncryption(aead), WithRemoteErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	fetches := 0
+	fetch := func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "secret " + key, nil
+	}
+	l := New(fetch, time.Minute, WithDriver(driver), WithNamespace("users"))
+	val, _ := l.Load("alice")
+	assert.Equal(t, "secret alice", val)
+	assert.NotNil(t, store.data["users:alice"])
+	assert.False(t, bytes.Contains(store.data["users:alice"], []byte("secret")), "value must be encrypted")
+
+	entry, err := DecodeRemoteEntry[string]("users:alice", store.data["users:alice"], WithEncryption(aead))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret alice", entry.Value)
+	assert.True(t, entry.Expire.After(time.Now()))
+
+	// other process sharing the same store
+	other := New(fetch, time.Minute, WithDriver(RemoteCache[string](store, WithEncryption(aead))), WithNamespace("users"))
+	val, info, _ := other.LoadWithInfo("alice")
+	assert.Equal(t, "secret alice", val)
+	assert.True(t, info.Cached)
+	assert.Equal(t, 1, fetches)
+
+	// values can't be moved to other key
+	store.data["users:bob"] = store.data["users:alice"]
+	val, info, _ = l.LoadWithInfo("bob")
+	assert.Equal(t, "secret bob", val)
+	assert.False(t, info.Cached)
+	assert.NotEmpty(t, errs)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrDecrypt)
+	}
+
+	l.Invalidate("alice")
+	assert.Nil(t, store.data["users:alice"])
+}
+
+func TestCompressCodec(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	codec := CompressCodec(JSONCodec, GzipCompressor(gzip.BestSpeed), 256)
+	fetch := func(ctx context.Context, key int) (string, error) {
+		return strings.Repeat("x", key), nil
+	}
+	l := New(fetch, time.Minute, WithDriver(RemoteCache[string](store, WithCodec(codec))))
+
+	l.Load(10)
+	l.Load(1000)
+	assert.Equal(t, rawValue, store.data["10"][0])
+	assert.Equal(t, compressedValue, store.data["1000"][0])
+	assert.Less(t, len(store.data["1000"]), 1000)
+
+	other := New(fetch, time.Minute, WithDriver(RemoteCache[string](store, WithCodec(codec))))
+	for _, key := range []int{10, 1000} {
+		val, info, _ := other.LoadWithInfo(key)
+		assert.True(t, info.Cached)
+		assert.Equal(t, strings.Repeat("x", key), val)
+	}
+}
+
+func TestRemoteCacheMaxValueSize(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	fetch := func(ctx context.Context, key int) (string, error) {
+		return strings.Repeat("x", key), nil
+	}
+	l := New(fetch, time.Minute, WithDriver(RemoteCache[string](store)), WithMaxValueSize(100))
+	l.Load(10)
+	l.Load(1000)
+	assert.NotNil(t, store.data["10"])
+	assert.Nil(t, store.data["1000"], "oversized value must not be written")
+	assert.Equal(t, uint64(1), l.Stats().Oversized)
+}
+
+type pingStore struct {
+	memoryStore
+	err error
+}
+
+func (s *pingStore) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestRemoteCacheHealthy(t *testing.T) {
+	store := &pingStore{memoryStore: memoryStore{data: map[string][]byte{}}}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithDriver(RemoteCache[string](store)))
+	assert.NoError(t, l.Healthy(context.Background()))
+
+	store.err = errors.New("connection refused")
+	err := l.Healthy(context.Background())
+	assert.ErrorIs(t, err, ErrUnhealthy)
+	assert.Contains(t, err.Error(), "connection refused")
+
+	rec := httptest.NewRecorder()
+	HealthHandler(l).ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+type flakyStore struct {
+	memoryStore
+	down atomic.Bool
+}
+
+var errStoreDown = errors.New("store is down")
+
+func (s *flakyStore) Get(key string) ([]byte, error) {
+	if s.down.Load() {
+		return nil, errStoreDown
+	}
+	return s.memoryStore.Get(key)
+}
+
+func (s *flakyStore) Set(key string, data []byte, ttl time.Duration) error {
+	if s.down.Load() {
+		return errStoreDown
+	}
+	return s.memoryStore.Set(key, data, ttl)
+}
+
+func (s *flakyStore) Delete(key string) error {
+	if s.down.Load() {
+		return errStoreDown
+	}
+	return s.memoryStore.Delete(key)
+}
+
+func TestRemoteCacheFailoverInvalidate(t *testing.T) {
+	store := &flakyStore{memoryStore: memoryStore{data: map[string][]byte{}}}
+	fetches := 0
+	l := New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return fmt.Sprint("value ", fetches), nil
+	}, time.Minute, WithDriver(RemoteCache[string](store, WithFailover(time.Millisecond, nil))))
+
+	l.Load("a")
+	store.down.Store(true)
+	assert.NoError(t, l.Invalidate("a"))
+	val, _ := l.Load("a")
+	assert.Equal(t, "value 2", val)
+	assert.NoError(t, l.Invalidate("a"))
+
+	store.down.Store(false)
+	time.Sleep(2 * time.Millisecond)
+	val, _ = l.Load("a")
+	assert.Equal(t, "value 3", val, "invalidation made while the store was down must not be lost")
+}
+
+func TestRemoteCacheFailover(t *testing.T) {
+	store := &flakyStore{memoryStore: memoryStore{data: map[string][]byte{}}}
+	var events []bool
+	driver := RemoteCache[string](store, WithFailover(time.Millisecond, nil), WithFailoverHandler(func(available bool, err error) {
+		events = append(events, available)
+	}))
+	fetches := 0
+	l := New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return key, nil
+	}, time.Minute, WithDriver(driver))
+
+	store.down.Store(true)
+	val, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", val)
+	_, info, _ := l.LoadWithInfo("a")
+	assert.True(t, info.Cached, "local driver must cache while the store is down")
+	assert.Equal(t, []bool{false}, events)
+
+	store.down.Store(false)
+	time.Sleep(2 * time.Millisecond)
+	l.Load("a")
+	assert.Equal(t, []bool{false, true}, events)
+	assert.NotNil(t, store.data["a"], "store must be used again after it recovers")
+	assert.Equal(t, 2, fetches)
+}
+
+func TestRefreshLease(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	newLoader := func() *Loader[string, string] {
+		return New(func(ctx context.Context, key string) (string, error) {
+			n := fetches.Add(1)
+			if n > 1 {
+				<-release
+			}
+			return fmt.Sprint("value ", n), nil
+		}, 50*time.Millisecond, WithDriver(RemoteCache[string](store)), WithRefreshLease(store, time.Minute))
+	}
+	a, b := newLoader(), newLoader()
+
+	a.Load("k")
+	val, _ := b.Load("k")
+	assert.Equal(t, "value 1", val, "remote driver must be shared")
+
+	time.Sleep(60 * time.Millisecond)
+	a.Load("k")
+	assert.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+	store.mutex.Lock()
+	leaseExpire := store.expires["lease:k"]
+	store.mutex.Unlock()
+	assert.WithinDuration(t, time.Now(), leaseExpire, 50*time.Millisecond, "lease TTL must be clamped to the item TTL")
+	val, _ = b.Load("k")
+	b.Wait()
+	assert.Equal(t, "value 1", val, "other process must serve the stale value")
+	close(release)
+	a.Wait()
+	assert.Equal(t, int32(2), fetches.Load(), "only the lease holder must refresh")
+	store.mutex.Lock()
+	_, held := store.data["lease:k"]
+	store.mutex.Unlock()
+	assert.False(t, held, "lease must be released after the refresh")
+	val, _ = b.Load("k")
+	assert.Equal(t, "value 2", val, "other process must read the refreshed value")
+}
+
+func TestRemoteCacheClock(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	serverClock := func() time.Time { return time.Now().Add(time.Hour) }
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}
+	writer := New(fetch, time.Minute, WithDriver(RemoteCache[string](store, WithClock(serverClock))))
+	writer.Load("a")
+	var record remoteRecord[string]
+	assert.NoError(t, JSONCodec.Unmarshal(store.data["a"], &record))
+	assert.WithinDuration(t, serverClock(), record.FetchedAt, time.Second, "record must be written with the clock")
+
+	entry, err := DecodeRemoteEntry[string]("a", store.data["a"])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), entry.FetchedAt, time.Second, "record ahead of local clock must be shifted back to now")
+	assert.Equal(t, time.Minute, entry.Expire.Sub(entry.FetchedAt))
+
+	reader := New(fetch, time.Minute, WithDriver(RemoteCache[string](store, WithClock(serverClock))))
+	_, info, _ := reader.LoadWithInfo("a")
+	assert.True(t, info.Cached)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), info.Expire, time.Second, "record must be converted to the local clock")
+}
+
+// edgeStore is EdgeStore that records the TTLs of the writes
+type edgeStore struct {
+	memoryStore
+	limits EdgeLimits
+	ttls   []time.Duration
+}
+
+func (s *edgeStore) Set(key string, data []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	s.ttls = append(s.ttls, ttl)
+	s.mutex.Unlock()
+	return s.memoryStore.Set(key, data, ttl)
+}
+
+func (s *edgeStore) Limits() EdgeLimits {
+	return s.limits
+}
+
+func TestRemoteCacheEdgeStore(t *testing.T) {
+	store := &edgeStore{
+		memoryStore: memoryStore{data: map[string][]byte{}},
+		limits:      EdgeLimits{MinTTL: time.Minute, WriteInterval: 50 * time.Millisecond, Lag: time.Minute},
+	}
+	fetch := func(ctx context.Context, key string) (string, error) {
+		return "value " + key, nil
+	}
+	l := New(fetch, 10*time.Millisecond, WithHardTTL(time.Second), WithDriver(RemoteCache[string](store)))
+
+	l.Load("a")
+	assert.Equal(t, []time.Duration{time.Minute}, store.ttls, "TTL must be raised to MinTTL")
+	assert.NoError(t, l.Invalidate("a"))
+	l.Load("a")
+	l.Prime("a", "primed")
+	store.mutex.Lock()
+	assert.Len(t, store.ttls, 1, "writes within WriteInterval must be delayed")
+	store.mutex.Unlock()
+	val, _ := l.Load("a")
+	assert.Equal(t, "primed", val, "delayed write must be read by the process")
+	assert.Eventually(t, func() bool {
+		store.mutex.Lock()
+		defer store.mutex.Unlock()
+		return len(store.ttls) == 2 && store.data["a"] != nil
+	}, time.Second, time.Millisecond, "the last delayed write must be written after WriteInterval")
+	data, _ := store.Get("a")
+	entry, err := DecodeRemoteEntry[string]("a", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "primed", entry.Value)
+
+	l.Load("b")
+	time.Sleep(20 * time.Millisecond)
+	tolerant := New(fetch, 10*time.Millisecond, WithDriver(RemoteCache[string](store, WithLagTolerance())))
+	_, info, _ := tolerant.LoadWithInfo("b")
+	assert.True(t, info.Cached)
+	assert.False(t, info.Stale, "record within the lag must be fresh")
+	_, info, _ = l.LoadWithInfo("b")
+	assert.True(t, info.Stale, "record must be stale without WithLagTolerance")
+}
+
+func TestRemoteCacheSharesDecodedItem(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		return "value", nil
+	}, 10*time.Millisecond, WithDriver(RemoteCache[string](store)))
+	defer l.Close()
+
+	l.Load("a")
+	time.Sleep(20 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Load("a")
+		}()
+	}
+	wg.Wait()
+	close(release)
+	l.Wait()
+	assert.Equal(t, int32(2), fetches.Load(), "stale loads must share one refresh")
+	assert.Equal(t, uint64(9), l.Stats().RefreshesCoalesced)
+
+	store.Set("a", []byte(`{"v":"other","f":"2000-01-01T00:00:00Z","e":"2100-01-01T00:00:00Z"}`), 0)
+	val, _ := l.Load("a")
+	assert.Equal(t, "other", val, "data written by other process must be decoded again")
+}
+
+func TestBlobCodec(t *testing.T) {
+	store := &memoryStore{data: map[string][]byte{}}
+	l := New(func(ctx context.Context, key string) ([]byte, error) {
+		return []byte("blob " + key), nil
+	}, time.Minute, WithDriver(RemoteCache[[]byte](store, WithCodec(BlobCodec))))
+	l.Load("a")
+
+	data, _ := store.Get("a")
+	assert.True(t, bytes.HasSuffix(data, []byte("blob a")), "value must be written raw")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "blob a", string(v))
+	assert.Same(t, &data[len(data)-1], &v[len(v)-1], "value must alias the data of the store")
+
+	entry, err := DecodeRemoteEntry[[]byte]("a", data, WithCodec(BlobCodec))
+	assert.NoError(t, err)
+	assert.Equal(t, "blob a", string(entry.Value))
+	_, err = BlobCodec.Marshal("not bytes")
+	assert.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestCache memoizes the results of Loader for the lifetime of one request, so duplicate lookups of a key
+// within a request handler see the same result and don't touch the loader again. It has no TTL and no key locks.
+//
+//	ctx = loader.WithRequestScope(r.Context())
+//	user, err := users.Load(ctx, id)
+type RequestCache[Key comparable, Value any] struct {
+	l *Loader[Key, Value]
+}
+
+// NewRequestCache creates RequestCache on top of l, it's meant to be created once and shared by requests
+func NewRequestCache[Key comparable, Value any](l *Loader[Key, Value]) *RequestCache[Key, Value] {
+	return &RequestCache[Key, Value]{l: l}
+}
+
+// WithRequestScope returns ctx holding the memo of every RequestCache, the memo is dropped with ctx
+func WithRequestScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestScopeKey{}, &requestScope{memos: map[interface{}]interface{}{}})
+}
+
+type requestScopeKey struct{}
+
+type requestScope struct {
+	mutex sync.Mutex
+	// memos holds map[Key]requestResult[Value] of each RequestCache
+	memos map[interface{}]interface{}
+}
+
+type requestResult[Value any] struct {
+	value Value
+	err   error
+}
+
+// Load returns the result of key memoized in the request scope of ctx, loading it with the Loader the first time.
+// Without WithRequestScope, it works like LoadCtx of the Loader.
+func (c *RequestCache[Key, Value]) Load(ctx context.Context, key Key) (Value, error) {
+	scope, _ := ctx.Value(requestScopeKey{}).(*requestScope)
+	if scope == nil {
+		return c.l.LoadCtx(ctx, key)
+	}
+
+	scope.mutex.Lock()
+	memo, _ := scope.memos[c].(map[Key]requestResult[Value])
+	if memo == nil {
+		memo = map[Key]requestResult[Value]{}
+		scope.memos[c] = memo
+	}
+	r, ok := memo[key]
+	scope.mutex.Unlock()
+	if ok {
+		return r.value, r.err
+	}
+
+	// concurrent lookups of the same key in one request are deduplicated by the Loader
+	value, err := c.l.LoadCtx(ctx, key)
+	scope.mutex.Lock()
+	memo[key] = requestResult[Value]{value, err}
+	scope.mutex.Unlock()
+	return value, err
+}
+
+// Clear forgets the memoized result of key in the request scope of ctx, so the next Load asks the Loader again
+func (c *RequestCache[Key, Value]) Clear(ctx context.Context, key Key) {
+	scope, _ := ctx.Value(requestScopeKey{}).(*requestScope)
+	if scope == nil {
+		return
+	}
+	scope.mutex.Lock()
+	defer scope.mutex.Unlock()
+	if memo, ok := scope.memos[c].(map[Key]requestResult[Value]); ok {
+		delete(memo, key)
+	}
+}
@@ -0,0 +1,33 @@
+package loader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCache(t *testing.T) {
+	version := 1
+	l := New(func(ctx context.Context, key string) (int, error) {
+		return version, nil
+	}, time.Minute)
+	c := NewRequestCache(l)
+
+	ctx := WithRequestScope(context.Background())
+	val, _ := c.Load(ctx, "a")
+	assert.Equal(t, 1, val)
+
+	l.Invalidate("a")
+	version = 2
+	val, _ = c.Load(ctx, "a")
+	assert.Equal(t, 1, val, "result must be memoized for the request")
+
+	val, _ = c.Load(WithRequestScope(context.Background()), "a")
+	assert.Equal(t, 2, val, "other request must load from the loader")
+	val, _ = c.Load(context.Background(), "a")
+	assert.Equal(t, 2, val, "context without scope must load from the loader")
+	assert.Equal(t, uint64(2), l.Stats().Misses)
+	assert.Equal(t, uint64(1), l.Stats().Hits, "memoized lookup must not touch the loader")
+}
@@ -0,0 +1,76 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotModified is returned by RevalidatingFetcher when the previous entry is still valid
+var ErrNotModified = errors.New("loader: not modified")
+
+// Entry is a value with the validator of a conditional request
+type Entry[Value any] struct {
+	Value Value
+	// Tag is an opaque validator, such as ETag or Last-Modified, passed back with the entry on the next fetch
+	Tag       string
+	FetchedAt time.Time
+}
+
+// RevalidatingFetcher fetches the value of key, prev is the cached entry or nil if there is none.
+// It can issue a conditional request with prev.Tag (e.g. If-None-Match) and return ErrNotModified,
+// so the cached value is kept and only its TTL is renewed. prev is set for the refreshes of expired entries,
+// and for the fetches of entries past WithHardTTL that are still in the cache.
+// Returning a nil entry without error caches the zero value without a tag.
+type RevalidatingFetcher[Key comparable, Value any] func(ctx context.Context, key Key, prev *Entry[Value]) (*Entry[Value], error)
+
+// revalidation passes the previous entry to RevalidatingFetcher and the new tag back, through the fetch context.
+// It also carries the version returned by FetcherVersioned.
+type revalidation[Value any] struct {
+	prev        *Entry[Value]
+	tag         string
+	notModified bool
+	// stamp is set by FetcherVersioned if stamped is true
+	stamp   uint64
+	stamped bool
+}
+
+type revalidationKey struct{}
+
+// NewRevalidating creates Loader whose fetcher can revalidate the cached entries
+func NewRevalidating[Key comparable, Value any](fn RevalidatingFetcher[Key, Value], ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	options = append(options, optionFunc(func(cfg *config) {
+		cfg.revalidating = true
+	}))
+	return New(func(ctx context.Context, key Key) (Value, error) {
+		rv, _ := ctx.Value(revalidationKey{}).(*revalidation[Value])
+		if rv == nil {
+			rv = &revalidation[Value]{}
+		}
+		entry, err := fn(ctx, key, rv.prev)
+		if errors.Is(err, ErrNotModified) && rv.prev != nil {
+			rv.notModified = true
+			rv.tag = rv.prev.Tag
+			return rv.prev.Value, nil
+		}
+		if err != nil || entry == nil {
+			// a nil entry is the zero value without a tag
+			var zero Value
+			return zero, err
+		}
+		rv.tag = entry.Tag
+		return entry.Value, nil
+	}, ttl, options...)
+}
+
+// revalidationContext adds the cached entry p to ctx for NewRevalidating, p is nil if there is none
+func (l *Loader[Key, Value]) revalidationContext(ctx context.Context, p *payload[Value]) (context.Context, *revalidation[Value]) {
+	if !l.revalidating && !l.versioned {
+		return ctx, nil
+	}
+	rv := &revalidation[Value]{}
+	if p != nil && p.err == nil {
+		rv.prev = &Entry[Value]{Value: p.value, Tag: p.tag, FetchedAt: p.fetchedAt}
+	}
+	return context.WithValue(ctx, revalidationKey{}, rv), rv
+}
@@ -0,0 +1,204 @@
+package loader
+
+import (
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync"
+)
+
+var shardSeed = maphash.MakeSeed()
+
+type shardedCache struct {
+	shards []cacheShard
+}
+
+type cacheShard struct {
+	mutex sync.RWMutex
+	items map[interface{}]interface{}
+}
+
+// ShardedInMemoryCache creates in-memory cache driver split into n shards selected by key hash.
+// Each shard has its own lock, so it has less contention than InMemoryCache on write-heavy workloads.
+func ShardedInMemoryCache(n int) CacheDriver {
+	if n <= 0 {
+		n = 1
+	}
+	c := &shardedCache{shards: make([]cacheShard, n)}
+	for i := range c.shards {
+		c.shards[i].items = map[interface{}]interface{}{}
+	}
+	return c
+}
+
+func (c *shardedCache) shard(key interface{}) *cacheShard {
+	return c.shardHashed(hashKey(key))
+}
+
+func (c *shardedCache) shardHashed(hash uint64) *cacheShard {
+	return &c.shards[hash%uint64(len(c.shards))]
+}
+
+// Add implements CacheDriver
+func (c *shardedCache) Add(key interface{}, value interface{}) {
+	c.AddHashed(key, hashKey(key), value)
+}
+
+// Get implements CacheDriver
+func (c *shardedCache) Get(key interface{}) (interface{}, bool) {
+	return c.GetHashed(key, hashKey(key))
+}
+
+// AddHashed implements HashedDriver
+func (c *shardedCache) AddHashed(key interface{}, hash uint64, value interface{}) {
+	s := c.shardHashed(hash)
+	s.mutex.Lock()
+	s.items[key] = value
+	s.mutex.Unlock()
+}
+
+// GetHashed implements HashedDriver
+func (c *shardedCache) GetHashed(key interface{}, hash uint64) (interface{}, bool) {
+	s := c.shardHashed(hash)
+	s.mutex.RLock()
+	value, ok := s.items[key]
+	s.mutex.RUnlock()
+	return value, ok
+}
+
+// Remove implements Remover
+func (c *shardedCache) Remove(key interface{}) {
+	s := c.shard(key)
+	s.mutex.Lock()
+	delete(s.items, key)
+	s.mutex.Unlock()
+}
+
+// Range implements Ranger
+func (c *shardedCache) Range(fn func(key, value interface{}) bool) {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mutex.RLock()
+		keys := make([]interface{}, 0, len(s.items))
+		values := make([]interface{}, 0, len(s.items))
+		for key, value := range s.items {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+		s.mutex.RUnlock()
+
+		for j := range keys {
+			if !fn(keys[j], values[j]) {
+				return
+			}
+		}
+	}
+}
+
+var _ HashedDriver = &shardedCache{}
+
+// HashKey returns the hash that the in-memory drivers and InMemoryKeyLocker shard key by,
+// the loader passes it to HashedDriver and HashedKeyLocker.
+func HashKey(key interface{}) uint64 {
+	return hashKey(key)
+}
+
+// hashKey hashes common key types without allocation, other comparable keys are hashed by reflection
+// so keys that are == have the same hash, whatever their String methods print
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case namespacedKey:
+		return hashString(k.namespace) ^ hashKey(k.key)
+	case nil:
+		return 0
+	default:
+		return hashComparable(reflect.ValueOf(key))
+	}
+}
+
+// hashComparable hashes v consistently with ==, e.g. 0.0 and -0.0 have the same hash
+func hashComparable(v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return hashString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix64(v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			return mix64(1)
+		}
+		return 0
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return combineHash(hashFloat(real(c)), hashFloat(imag(c)))
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return mix64(uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return hashComparable(v.Elem())
+	case reflect.Array:
+		var h uint64
+		for i := 0; i < v.Len(); i++ {
+			h = combineHash(h, hashComparable(v.Index(i)))
+		}
+		return h
+	case reflect.Struct:
+		var h uint64
+		for i := 0; i < v.NumField(); i++ {
+			h = combineHash(h, hashComparable(v.Field(i)))
+		}
+		return h
+	default:
+		// maps, slices and funcs can't be keys
+		return 0
+	}
+}
+
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		// -0.0 == 0.0
+		return 0
+	}
+	return mix64(math.Float64bits(f))
+}
+
+func combineHash(h, x uint64) uint64 {
+	return mix64(h ^ (x + 0x9e3779b97f4a7c15 + h<<6 + h>>2))
+}
+
+func hashString(s string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(shardSeed)
+	h.WriteString(s)
+	return h.Sum64()
+}
+
+// mix64 is splitmix64 finalizer, spreading sequential integers across shards
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedInMemoryCache(t *testing.T) {
+	c := ShardedInMemoryCache(8)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(fmt.Sprint(i, j), j)
+				c.Add(i*100+j, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	val, ok := c.Get("3 42")
+	assert.True(t, ok)
+	assert.Equal(t, 42, val)
+	val, ok = c.Get(342)
+	assert.True(t, ok)
+	assert.Equal(t, 42, val)
+	_, ok = c.Get("missing")
+	assert.False(t, ok)
+
+	count := 0
+	c.(Ranger).Range(func(key, value interface{}) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1600, count)
+}
+
+func benchmarkDriverChurn(b *testing.B, c CacheDriver) {
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Add(i%1024, i)
+			c.Get((i + 512) % 1024)
+			i++
+		}
+	})
+}
+
+func BenchmarkInMemoryCacheChurn(b *testing.B) {
+	benchmarkDriverChurn(b, InMemoryCache())
+}
+
+func BenchmarkShardedInMemoryCacheChurn(b *testing.B) {
+	benchmarkDriverChurn(b, ShardedInMemoryCache(32))
+}
+
+// printedKey prints the same for different keys
+type printedKey struct{ id int }
+
+func (printedKey) String() string { return "key" }
+
+func TestHashKey(t *testing.T) {
+	assert.Equal(t, hashKey(0.0), hashKey(math.Copysign(0, -1)), "keys that are == must have the same hash")
+	assert.NotEqual(t, hashKey(printedKey{1}), hashKey(printedKey{2}))
+	assert.Equal(t, hashKey(K2("a", 1)), hashKey(K2("a", 1)))
+	assert.NotEqual(t, hashKey(K2("a", 1)), hashKey(K2("a", 2)))
+	var a, b interface{} = 1, 1
+	assert.Equal(t, hashKey(struct{ v interface{} }{a}), hashKey(struct{ v interface{} }{b}))
+
+	c := ShardedInMemoryCache(16)
+	c.Add(0.0, "zero")
+	val, ok := c.Get(math.Copysign(0, -1))
+	assert.True(t, ok)
+	assert.Equal(t, "zero", val)
+
+	var key interface{} = K2("tenant", 42)
+	allocs := testing.AllocsPerRun(100, func() { hashKey(key) })
+	assert.Equal(t, 0.0, allocs)
+}
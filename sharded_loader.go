@@ -0,0 +1,140 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// shardReplicas is the number of points of each shard on the hash ring, so keys spread evenly
+const shardReplicas = 160
+
+// ShardedLoader distributes keys across loaders by consistent hashing, for key spaces that exceed one LRU or one Redis instance.
+// The ring points of a shard come from its Name, or its position if it has none, and the hash is stable across processes,
+// so every instance sends a key to the same shard, and adding or removing a shard only moves the keys of that shard.
+//
+//	users := loader.NewShardedLoader(
+//		loader.New(fetchUser, time.Hour, loader.WithName("users-a"), loader.WithDriver(loader.RemoteCache[User](redisA))),
+//		loader.New(fetchUser, time.Hour, loader.WithName("users-b"), loader.WithDriver(loader.RemoteCache[User](redisB))),
+//	)
+type ShardedLoader[Key comparable, Value any] struct {
+	shards []*Loader[Key, Value]
+	ring   []ringPoint
+}
+
+var _ ManagedLoader = &ShardedLoader[string, string]{}
+
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// NewShardedLoader creates ShardedLoader over shards, which must not be empty.
+// It panics if two shards have the same Name, since they would share the points of the ring.
+func NewShardedLoader[Key comparable, Value any](shards ...*Loader[Key, Value]) *ShardedLoader[Key, Value] {
+	if len(shards) == 0 {
+		panic("loader: NewShardedLoader without shards")
+	}
+	s := &ShardedLoader[Key, Value]{shards: shards, ring: make([]ringPoint, 0, len(shards)*shardReplicas)}
+	ids := make(map[string]bool, len(shards))
+	for i, shard := range shards {
+		id := shard.Name()
+		if id == "" {
+			id = strconv.Itoa(i)
+		}
+		if ids[id] {
+			panic(fmt.Sprintf("loader: NewShardedLoader with duplicate shard name %q", id))
+		}
+		ids[id] = true
+		for r := 0; r < shardReplicas; r++ {
+			s.ring = append(s.ring, ringPoint{hash: stableHash(id + "#" + strconv.Itoa(r)), shard: i})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool { return s.ring[i].hash < s.ring[j].hash })
+	return s
+}
+
+// Shard returns the loader that owns key
+func (s *ShardedLoader[Key, Value]) Shard(key Key) *Loader[Key, Value] {
+	h := stableHash(fmt.Sprint(key))
+	i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= h })
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.shards[s.ring[i].shard]
+}
+
+// Shards returns the loaders in the order they were passed to NewShardedLoader
+func (s *ShardedLoader[Key, Value]) Shards() []*Loader[Key, Value] {
+	return append([]*Loader[Key, Value](nil), s.shards...)
+}
+
+// Load loads key from its shard
+func (s *ShardedLoader[Key, Value]) Load(key Key) (Value, error) {
+	return s.Shard(key).Load(key)
+}
+
+// LoadCtx loads key from its shard with ctx
+func (s *ShardedLoader[Key, Value]) LoadCtx(ctx context.Context, key Key) (Value, error) {
+	return s.Shard(key).LoadCtx(ctx, key)
+}
+
+// LoadWithInfoCtx loads key and its Info from its shard with ctx
+func (s *ShardedLoader[Key, Value]) LoadWithInfoCtx(ctx context.Context, key Key) (Value, Info, error) {
+	return s.Shard(key).LoadWithInfoCtx(ctx, key)
+}
+
+// Invalidate invalidates key in its shard
+func (s *ShardedLoader[Key, Value]) Invalidate(key Key) error {
+	return s.Shard(key).Invalidate(key)
+}
+
+// InvalidateAll invalidates every shard, it returns the first error
+func (s *ShardedLoader[Key, Value]) InvalidateAll() error {
+	return s.each(func(l *Loader[Key, Value]) error { return l.InvalidateAll() })
+}
+
+// WarmUp warms every shard up, it returns the first error
+func (s *ShardedLoader[Key, Value]) WarmUp(ctx context.Context) error {
+	return s.each(func(l *Loader[Key, Value]) error { return l.WarmUp(ctx) })
+}
+
+// Healthy checks every shard, it returns the first unhealthy one
+func (s *ShardedLoader[Key, Value]) Healthy(ctx context.Context) error {
+	return s.each(func(l *Loader[Key, Value]) error { return l.Healthy(ctx) })
+}
+
+// Close closes every shard, it returns the first error
+func (s *ShardedLoader[Key, Value]) Close() error {
+	return s.each(func(l *Loader[Key, Value]) error { return l.Close() })
+}
+
+// Stats sums the stats of every shard
+func (s *ShardedLoader[Key, Value]) Stats() Stats {
+	var total Stats
+	for _, l := range s.shards {
+		stats := l.Stats()
+		total.add(stats)
+		total.Cost += stats.Cost
+	}
+	return total
+}
+
+func (s *ShardedLoader[Key, Value]) each(fn func(l *Loader[Key, Value]) error) error {
+	var firstErr error
+	for i, l := range s.shards {
+		if err := fn(l); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+	return firstErr
+}
+
+// stableHash is FNV-1a, unlike hashKey it's the same in every process
+func stableHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return mix64(h.Sum64())
+}
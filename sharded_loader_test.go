@@ -0,0 +1,89 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedLoader(t *testing.T) {
+	newShards := func(n int) []*Loader[int, string] {
+		shards := make([]*Loader[int, string], n)
+		for i := range shards {
+			shard := i
+			shards[i] = New(func(ctx context.Context, key int) (string, error) {
+				return fmt.Sprintf("%d@%d", key, shard), nil
+			}, time.Minute, WithName(fmt.Sprintf("sharded-test-%d", i)))
+			t.Cleanup(func() { shards[shard].Close() })
+		}
+		return shards
+	}
+
+	s := NewShardedLoader(newShards(4)...)
+	const keys = 10000
+	counts := map[*Loader[int, string]]int{}
+	for key := 0; key < keys; key++ {
+		shard := s.Shard(key)
+		assert.Same(t, shard, s.Shard(key), "a key must always go to the same shard")
+		counts[shard]++
+		v, err := s.Load(key)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%d@%d", key, indexOf(s.Shards(), shard)), v)
+	}
+	for _, shard := range s.Shards() {
+		assert.InDelta(t, keys/4, counts[shard], keys/10, "keys must spread evenly")
+		assert.Equal(t, uint64(counts[shard]), shard.Stats().Misses)
+	}
+	assert.Equal(t, uint64(keys), s.Stats().Misses)
+
+	// adding a shard keeps the ring points of the others
+	extra := New(func(ctx context.Context, key int) (string, error) {
+		return "extra", nil
+	}, time.Minute, WithName("sharded-test-extra"))
+	defer extra.Close()
+	grown := NewShardedLoader(append(s.Shards(), extra)...)
+	moved := 0
+	for key := 0; key < keys; key++ {
+		if shard := grown.Shard(key); shard != s.Shard(key) {
+			assert.Same(t, extra, shard, "keys must only move to the added shard")
+			moved++
+		}
+	}
+	assert.InDelta(t, keys/5, moved, keys/10)
+
+	assert.NoError(t, s.Invalidate(1))
+	assert.NoError(t, s.InvalidateAll())
+	s.Load(1)
+	assert.Equal(t, uint64(keys+1), s.Stats().Misses)
+}
+
+func TestShardedLoaderDuplicateName(t *testing.T) {
+	fetch := func(ctx context.Context, key int) (string, error) {
+		return "", nil
+	}
+	a := New(fetch, time.Minute, WithName("sharded-dup"))
+	defer a.Close()
+	b := New(fetch, time.Minute, WithName("sharded-dup"))
+	defer b.Close()
+	assert.PanicsWithValue(t, `loader: NewShardedLoader with duplicate shard name "sharded-dup"`, func() {
+		NewShardedLoader(a, b)
+	})
+	// the position of an unnamed shard can't collide with a name either
+	unnamed := New(fetch, time.Minute)
+	defer unnamed.Close()
+	one := New(fetch, time.Minute, WithName("1"))
+	defer one.Close()
+	assert.Panics(t, func() { NewShardedLoader(one, unnamed) })
+}
+
+func indexOf[T comparable](items []T, item T) int {
+	for i, v := range items {
+		if v == item {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,27 @@
+package loader
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithStartupSmear shortens the ttl of the values fetched during the first window after New by a random duration
+// up to window, so the entries loaded while the process boots, like by WarmUp, don't all expire at once later.
+// The refreshes after the first one use the full ttl.
+func WithStartupSmear(window time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.startupSmear = window
+	})
+}
+
+// smear applies WithStartupSmear to the ttl of the value fetched at now
+func (l *Loader[Key, Value]) smear(now time.Time, ttl time.Duration) time.Duration {
+	if l.startupSmear <= 0 || ttl <= 0 || now.Sub(l.startedAt) >= l.startupSmear {
+		return ttl
+	}
+	spread := l.startupSmear
+	if spread > ttl {
+		spread = ttl
+	}
+	return ttl - time.Duration(rand.Int63n(int64(spread)))
+}
@@ -0,0 +1,150 @@
+// Package sqlitestore implements loader.RemoteStore on top of a SQLite database, a single-file persistent cache
+// for CLIs and desktop apps. It uses database/sql, open the database with a SQLite driver such as modernc.org/sqlite:
+//
+//	db, err := sql.Open("sqlite", filepath.Join(cacheDir, "cache.db")+"?_pragma=journal_mode(WAL)")
+//	store, err := sqlitestore.New(db)
+//	driver := loader.RemoteCache[User](store)
+//
+// Expired rows are never returned, and they're deleted periodically by DeleteExpired.
+// Deleted rows leave free pages in the file, run VACUUM on the database to shrink it.
+package sqlitestore
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store is loader.RemoteStore backed by a SQLite table
+type Store struct {
+	db              *sql.DB
+	table           string
+	cleanupInterval time.Duration
+	onError         func(err error)
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// Option configures Store
+type Option func(s *Store)
+
+// WithTable sets the name of the table, the default is loader_cache
+func WithTable(name string) Option {
+	return func(s *Store) {
+		s.table = name
+	}
+}
+
+// WithCleanupInterval sets how often the expired rows are deleted, the default is 1 minute. Zero disables it.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.cleanupInterval = interval
+	}
+}
+
+// WithErrorHandler reports the errors of the periodic cleanup
+func WithErrorHandler(fn func(err error)) Option {
+	return func(s *Store) {
+		s.onError = fn
+	}
+}
+
+// New creates Store, creating its table if it doesn't exist
+func New(db *sql.DB, options ...Option) (*Store, error) {
+	s := &Store{
+		db:              db,
+		table:           "loader_cache",
+		cleanupInterval: time.Minute,
+		onError:         func(error) {},
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for _, o := range options {
+		o(s)
+	}
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value BLOB NOT NULL, expires_at INTEGER NOT NULL)", s.table))
+	if err != nil {
+		return nil, fmt.Errorf("sqlitestore: create table %s: %w", s.table, err)
+	}
+	// the expired rows are found by the index instead of scanning the table
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)", s.table, s.table))
+	if err != nil {
+		return nil, fmt.Errorf("sqlitestore: create index of %s: %w", s.table, err)
+	}
+	if s.cleanupInterval > 0 {
+		go s.cleanupPeriodically()
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+// Get implements loader.RemoteStore
+func (s *Store) Get(key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE key = ? AND (expires_at = 0 OR expires_at > ?)", s.table),
+		key, nowMillis()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if data == nil && err == nil {
+		// an empty blob is still a value
+		data = []byte{}
+	}
+	return data, err
+}
+
+// Set implements loader.RemoteStore
+func (s *Store) Set(key string, data []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixMilli()
+	}
+	_, err := s.db.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (key, value, expires_at) VALUES (?, ?, ?)", s.table), key, data, expiresAt)
+	return err
+}
+
+// Delete implements loader.RemoteStore
+func (s *Store) Delete(key string) error {
+	_, err := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = ?", s.table), key)
+	return err
+}
+
+// DeleteExpired deletes the expired rows, it returns how many are deleted
+func (s *Store) DeleteExpired() (int64, error) {
+	res, err := s.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at != 0 AND expires_at <= ?", s.table), nowMillis())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) cleanupPeriodically() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if _, err := s.DeleteExpired(); err != nil {
+				s.onError(err)
+			}
+		}
+	}
+}
+
+// Close stops the periodic cleanup, the database is left open
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixMilli()
+}
@@ -0,0 +1,97 @@
+//go:build sqlite
+
+// The tests against a real SQLite database need modernc.org/sqlite, which isn't a dependency of the module:
+//
+//	go get modernc.org/sqlite && go test -tags sqlite ./sqlitestore
+
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cache.db")+"?_pragma=journal_mode(WAL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLite(t *testing.T) {
+	db := openSQLite(t)
+	s, err := New(db, WithCleanupInterval(0))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("a", []byte("1"), 0))
+	assert.NoError(t, s.Set("a", []byte("2"), 0))
+	assert.NoError(t, s.Set("b", []byte{}, 0))
+	assert.NoError(t, s.Set("short", []byte("3"), time.Millisecond))
+	v, _ = s.Get("a")
+	assert.Equal(t, []byte("2"), v)
+	v, _ = s.Get("b")
+	assert.NotNil(t, v, "empty value must not be a miss")
+
+	time.Sleep(5 * time.Millisecond)
+	v, _ = s.Get("short")
+	assert.Nil(t, v, "expired row must not be returned")
+	n, err := s.DeleteExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	assert.NoError(t, s.Delete("a"))
+	v, _ = s.Get("a")
+	assert.Nil(t, v)
+
+	var id, parent, notUsed int
+	var plan string
+	err = db.QueryRow("EXPLAIN QUERY PLAN DELETE FROM loader_cache WHERE expires_at != 0 AND expires_at <= ?", 0).Scan(&id, &parent, &notUsed, &plan)
+	assert.NoError(t, err)
+	assert.Contains(t, plan, "loader_cache_expires_at", "DeleteExpired must use the index of expires_at")
+}
+
+func TestSQLiteReopen(t *testing.T) {
+	db := openSQLite(t)
+	s, err := New(db, WithTable("reopened"), WithCleanupInterval(0))
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set("a", []byte("1"), time.Hour))
+	assert.NoError(t, s.Close())
+
+	s, err = New(db, WithTable("reopened"), WithCleanupInterval(0))
+	assert.NoError(t, err, "New must accept the existing table and index")
+	defer s.Close()
+	v, _ := s.Get("a")
+	assert.Equal(t, []byte("1"), v)
+}
+
+func TestSQLiteRemoteCache(t *testing.T) {
+	s, err := New(openSQLite(t))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(loader.RemoteCache[string](s)))
+	defer l.Close()
+	l.Load("a")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", v)
+	assert.Equal(t, 1, fetches)
+}
@@ -0,0 +1,212 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	loader "github.com/abihf/cache-loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDB understands the statements of Store, so it can be tested without a SQLite driver
+type fakeDB struct {
+	mutex sync.Mutex
+	rows  map[string]fakeRow
+}
+
+type fakeRow struct {
+	value     []byte
+	expiresAt int64
+}
+
+var (
+	fakeDBsMutex sync.Mutex
+	fakeDBs      = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("sqlitestore-fake", fakeDriver{})
+}
+
+func openFakeDB(t *testing.T) (*sql.DB, *fakeDB) {
+	fake := &fakeDB{rows: map[string]fakeRow{}}
+	fakeDBsMutex.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeDBsMutex.Unlock()
+	db, err := sql.Open("sqlitestore-fake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+func (db *fakeDB) len() int {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return len(db.rows)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMutex.Lock()
+	defer fakeDBsMutex.Unlock()
+	return &fakeConn{db: fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE"), strings.HasPrefix(s.query, "CREATE INDEX"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "INSERT OR REPLACE"):
+		s.db.rows[args[0].(string)] = fakeRow{value: args[1].([]byte), expiresAt: args[2].(int64)}
+		return driver.RowsAffected(1), nil
+	case strings.Contains(s.query, "WHERE key = ?"):
+		delete(s.db.rows, args[0].(string))
+		return driver.RowsAffected(1), nil
+	case strings.Contains(s.query, "WHERE expires_at != 0 AND expires_at <= ?"):
+		var n int64
+		for key, row := range s.db.rows {
+			if row.expiresAt != 0 && row.expiresAt <= args[0].(int64) {
+				delete(s.db.rows, key)
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	}
+	return nil, fmt.Errorf("unexpected statement %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	if !strings.HasPrefix(s.query, "SELECT value") {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	rows := &fakeRows{}
+	if row, ok := s.db.rows[args[0].(string)]; ok && (row.expiresAt == 0 || row.expiresAt > args[1].(int64)) {
+		rows.values = [][]byte{row.value}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values [][]byte
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	db, fake := openFakeDB(t)
+	s, err := New(db, WithCleanupInterval(0))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	v, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, s.Set("a", []byte("1"), 0))
+	assert.NoError(t, s.Set("b", []byte{}, 0))
+	assert.NoError(t, s.Set("short", []byte("2"), time.Millisecond))
+	v, _ = s.Get("a")
+	assert.Equal(t, []byte("1"), v)
+	v, _ = s.Get("b")
+	assert.NotNil(t, v, "empty value must not be a miss")
+
+	time.Sleep(5 * time.Millisecond)
+	v, _ = s.Get("short")
+	assert.Nil(t, v, "expired row must not be returned")
+	n, err := s.DeleteExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 2, fake.len())
+
+	assert.NoError(t, s.Delete("a"))
+	v, _ = s.Get("a")
+	assert.Nil(t, v)
+}
+
+func TestCleanupPeriodically(t *testing.T) {
+	db, fake := openFakeDB(t)
+	s, err := New(db, WithCleanupInterval(time.Millisecond))
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.Set("a", []byte("1"), time.Millisecond))
+	assert.Eventually(t, func() bool { return fake.len() == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, s.Close())
+}
+
+func TestRemoteCache(t *testing.T) {
+	db, _ := openFakeDB(t)
+	s, err := New(db)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	fetches := 0
+	l := loader.New(func(ctx context.Context, key string) (string, error) {
+		fetches++
+		return "value " + key, nil
+	}, time.Minute, loader.WithDriver(loader.RemoteCache[string](s)))
+	defer l.Close()
+	l.Load("a")
+	v, err := l.Load("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", v)
+	assert.Equal(t, 1, fetches)
+}
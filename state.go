@@ -0,0 +1,96 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// State describes the lifecycle of a cached item
+type State int
+
+const (
+	// StateLoading means the item is being fetched for the first time
+	StateLoading State = iota
+	// StateFresh means the item holds a value that is not expired
+	StateFresh
+	// StateStale means the item is expired and is not being refreshed
+	StateStale
+	// StateRefreshing means the item is expired and is being refreshed in background
+	StateRefreshing
+	// StateError means the item holds an error from the last fetch
+	StateError
+)
+
+func (s State) String() string {
+	switch s {
+	case StateLoading:
+		return "loading"
+	case StateFresh:
+		return "fresh"
+	case StateStale:
+		return "stale"
+	case StateRefreshing:
+		return "refreshing"
+	case StateError:
+		return "error"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// item states stored atomically in cacheItem.
+// loading -> idle on the first store, idle <-> refreshing for background refreshes.
+const (
+	itemLoading int32 = iota
+	itemIdle
+	itemRefreshing
+)
+
+// beginRefresh moves the item to refreshing state, it returns false if it's loading or already refreshing
+func (i *cacheItem[Value]) beginRefresh() bool {
+	return i.state.CompareAndSwap(itemIdle, itemRefreshing)
+}
+
+// endRefresh moves the item back to idle state
+func (i *cacheItem[Value]) endRefresh() {
+	i.state.CompareAndSwap(itemRefreshing, itemIdle)
+}
+
+// currentState combines the item state and its payload p into State
+func (i *cacheItem[Value]) currentState(p *payload[Value], now time.Time) State {
+	switch {
+	case p == nil || i.state.Load() == itemLoading:
+		return StateLoading
+	case i.state.Load() == itemRefreshing:
+		return StateRefreshing
+	case p.err != nil:
+		return StateError
+	case p.expire.Before(now):
+		return StateStale
+	default:
+		return StateFresh
+	}
+}
+
+// PanicError is returned by Load when the fetcher panics
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("loader: fetcher panicked: %v", e.Value)
+}
+
+// callFetcher calls the fetcher, converting panic into PanicError,
+// so the item never gets stuck in loading or refreshing state
+func (l *Loader[Key, Value]) callFetcher(ctx context.Context, key Key) (value Value, err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = l.def, &PanicError{Value: r}
+		}
+		l.sink.ObserveFetch(time.Since(start), err)
+	}()
+	return l.fn(ctx, key)
+}
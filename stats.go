@@ -0,0 +1,102 @@
+package loader
+
+import "sync/atomic"
+
+// Stats contains the counters of a Loader
+type Stats struct {
+	// Hits counts loads served from cache
+	Hits uint64
+	// Misses counts loads that have to fetch the value
+	Misses uint64
+	// Oversized counts fetched values that are not cached because of WithMaxValueSize
+	Oversized uint64
+	// Rejected counts fetched values that are not cached because of WithAdmissionPolicy
+	Rejected uint64
+	// DriverTimeouts counts driver Gets abandoned because of WithDriverTimeout
+	DriverTimeouts uint64
+	// RefetchGoroutines is the number of refetch goroutines running now, see WithMaxRefetchGoroutines
+	RefetchGoroutines int64
+	// RefreshesCoalesced counts loads of expired items that joined the refresh already in flight
+	RefreshesCoalesced uint64
+	// RefreshesSkipped counts refreshes not started because WithMaxRefetchGoroutines was reached
+	RefreshesSkipped uint64
+	// ReadRepairs counts entries copied between tiers by WithReadRepair
+	ReadRepairs uint64
+	// VersionConflicts counts fetched values discarded by NewVersioned because the cached version is newer
+	VersionConflicts uint64
+	// EventsDropped counts events not sent because a channel of Events was full
+	EventsDropped uint64
+	// Cost is the total cost of cached items, if the cache driver implements CostReporter
+	Cost int64
+}
+
+type counters struct {
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	oversized      atomic.Uint64
+	rejected       atomic.Uint64
+	driverTimeouts atomic.Uint64
+
+	refetchGoroutines  atomic.Int64
+	refreshesCoalesced atomic.Uint64
+	refreshesSkipped   atomic.Uint64
+	readRepairs        atomic.Uint64
+	versionConflicts   atomic.Uint64
+	eventsDropped      atomic.Uint64
+}
+
+// Stats returns the current counters of the loader
+func (l *Loader[Key, Value]) Stats() Stats {
+	stats := Stats{
+		Hits:           l.counters.hits.Load(),
+		Misses:         l.counters.misses.Load(),
+		Oversized:      l.counters.oversized.Load(),
+		Rejected:       l.counters.rejected.Load(),
+		DriverTimeouts: l.counters.driverTimeouts.Load(),
+
+		RefetchGoroutines:  l.counters.refetchGoroutines.Load(),
+		RefreshesCoalesced: l.counters.refreshesCoalesced.Load(),
+		RefreshesSkipped:   l.counters.refreshesSkipped.Load(),
+		ReadRepairs:        l.counters.readRepairs.Load(),
+		VersionConflicts:   l.counters.versionConflicts.Load(),
+		EventsDropped:      l.counters.eventsDropped.Load(),
+	}
+	if c, ok := l.driver.(CostReporter); ok {
+		stats.Cost = c.Cost()
+	}
+	return stats
+}
+
+// add sums the counters of other into s, except Cost
+func (s *Stats) add(other Stats) {
+	s.Hits += other.Hits
+	s.Misses += other.Misses
+	s.Oversized += other.Oversized
+	s.Rejected += other.Rejected
+	s.DriverTimeouts += other.DriverTimeouts
+	s.RefetchGoroutines += other.RefetchGoroutines
+	s.RefreshesCoalesced += other.RefreshesCoalesced
+	s.RefreshesSkipped += other.RefreshesSkipped
+	s.ReadRepairs += other.ReadRepairs
+	s.VersionConflicts += other.VersionConflicts
+	s.EventsDropped += other.EventsDropped
+}
+
+// countHit records a cache hit in the loader and tenant stats
+func (l *Loader[Key, Value]) countHit(key Key) {
+	l.counters.hits.Add(1)
+	l.sink.IncHit()
+	if l.tenants != nil {
+		l.tenants.tenantCounters(key).hits.Add(1)
+	}
+}
+
+// countMiss records a cache miss in the loader and tenant stats
+func (l *Loader[Key, Value]) countMiss(key Key) {
+	l.counters.misses.Add(1)
+	l.sink.IncMiss()
+	l.emit(EventMiss, key, nil)
+	if l.tenants != nil {
+		l.tenants.tenantCounters(key).misses.Add(1)
+	}
+}
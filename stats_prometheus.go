@@ -0,0 +1,93 @@
+package loader
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fetchBuckets are the upper bounds of the fetch duration histogram in seconds
+var fetchBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusExporter serves the stats of loaders in Prometheus text format without depending on the client library.
+// Mount it as http.Handler, for example on /metrics.
+type PrometheusExporter struct {
+	mutex sync.Mutex
+	sinks map[string]*prometheusSink
+}
+
+// NewPrometheusExporter creates PrometheusExporter
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{sinks: map[string]*prometheusSink{}}
+}
+
+// Sink returns StatsSink whose metrics are labeled with loader=name
+func (e *PrometheusExporter) Sink(name string) StatsSink {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	s, ok := e.sinks[name]
+	if !ok {
+		s = &prometheusSink{buckets: make([]atomic.Uint64, len(fetchBuckets))}
+		e.sinks[name] = s
+	}
+	return s
+}
+
+type prometheusSink struct {
+	AtomicStatsSink
+	buckets []atomic.Uint64
+}
+
+func (s *prometheusSink) ObserveFetch(d time.Duration, err error) {
+	s.AtomicStatsSink.ObserveFetch(d, err)
+	seconds := d.Seconds()
+	for i, le := range fetchBuckets {
+		if seconds <= le {
+			s.buckets[i].Add(1)
+			return
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	names := make([]string, 0, len(e.sinks))
+	for name := range e.sinks {
+		names = append(names, name)
+	}
+	sinks := e.sinks
+	e.mutex.Unlock()
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	counter := func(metric, help string, value func(s *prometheusSink) uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s{loader=%q} %d\n", metric, name, value(sinks[name]))
+		}
+	}
+	counter("cache_loader_hits_total", "Loads served from cache.", func(s *prometheusSink) uint64 { return s.Hits.Load() })
+	counter("cache_loader_misses_total", "Loads that fetched the value.", func(s *prometheusSink) uint64 { return s.Misses.Load() })
+	counter("cache_loader_refreshes_total", "Background refreshes of expired items.", func(s *prometheusSink) uint64 { return s.Refreshes.Load() })
+	counter("cache_loader_oversized_total", "Fetched values too large to be cached.", func(s *prometheusSink) uint64 { return s.Oversized.Load() })
+	counter("cache_loader_fetch_errors_total", "Fetches that returned error.", func(s *prometheusSink) uint64 { return s.FetchErrors.Load() })
+
+	metric := "cache_loader_fetch_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of the fetches.\n# TYPE %s histogram\n", metric, metric)
+	for _, name := range names {
+		s := sinks[name]
+		var cumulative uint64
+		for i, le := range fetchBuckets {
+			cumulative += s.buckets[i].Load()
+			fmt.Fprintf(w, "%s_bucket{loader=%q,le=\"%g\"} %d\n", metric, name, le, cumulative)
+		}
+		count := s.Fetches.Load()
+		fmt.Fprintf(w, "%s_bucket{loader=%q,le=\"+Inf\"} %d\n", metric, name, count)
+		fmt.Fprintf(w, "%s_sum{loader=%q} %g\n", metric, name, time.Duration(s.FetchTime.Load()).Seconds())
+		fmt.Fprintf(w, "%s_count{loader=%q} %d\n", metric, name, count)
+	}
+}
@@ -0,0 +1,62 @@
+package loader
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StatsSink receives the events of a Loader, so they can be exported to any metrics stack.
+// Methods are called synchronously from Load, so they must be cheap and thread safe.
+type StatsSink interface {
+	// IncHit is called when a load is served from cache
+	IncHit()
+	// IncMiss is called when a load has to fetch the value
+	IncMiss()
+	// IncRefresh is called when an expired item is refreshed in background
+	IncRefresh()
+	// IncOversized is called when a value is not cached because of WithMaxValueSize
+	IncOversized()
+	// ObserveFetch is called after every call of the Fetcher
+	ObserveFetch(duration time.Duration, err error)
+}
+
+// WithStatsSink sends the events of the loader to sink
+func WithStatsSink(sink StatsSink) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.sink = sink
+	})
+}
+
+// NopStatsSink is StatsSink that ignores every event
+type NopStatsSink struct{}
+
+func (NopStatsSink) IncHit()                                 {}
+func (NopStatsSink) IncMiss()                                {}
+func (NopStatsSink) IncRefresh()                             {}
+func (NopStatsSink) IncOversized()                           {}
+func (NopStatsSink) ObserveFetch(d time.Duration, err error) {}
+
+// AtomicStatsSink is StatsSink that counts the events with atomic counters
+type AtomicStatsSink struct {
+	Hits        atomic.Uint64
+	Misses      atomic.Uint64
+	Refreshes   atomic.Uint64
+	Oversized   atomic.Uint64
+	Fetches     atomic.Uint64
+	FetchErrors atomic.Uint64
+	// FetchTime is the total duration of the fetches in nanoseconds
+	FetchTime atomic.Int64
+}
+
+func (s *AtomicStatsSink) IncHit()       { s.Hits.Add(1) }
+func (s *AtomicStatsSink) IncMiss()      { s.Misses.Add(1) }
+func (s *AtomicStatsSink) IncRefresh()   { s.Refreshes.Add(1) }
+func (s *AtomicStatsSink) IncOversized() { s.Oversized.Add(1) }
+
+func (s *AtomicStatsSink) ObserveFetch(d time.Duration, err error) {
+	s.Fetches.Add(1)
+	s.FetchTime.Add(int64(d))
+	if err != nil {
+		s.FetchErrors.Add(1)
+	}
+}
@@ -0,0 +1,139 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomicStatsSink(t *testing.T) {
+	sink := &AtomicStatsSink{}
+	l := New(func(ctx context.Context, key string) (string, error) {
+		if key == "bad" {
+			return "", errors.New("bad")
+		}
+		return key, nil
+	}, time.Minute, WithStatsSink(sink))
+	l.Load("a")
+	l.Load("a")
+	l.Load("bad")
+
+	assert.Equal(t, uint64(1), sink.Hits.Load())
+	assert.Equal(t, uint64(2), sink.Misses.Load())
+	assert.Equal(t, uint64(2), sink.Fetches.Load())
+	assert.Equal(t, uint64(1), sink.FetchErrors.Load())
+}
+
+func TestPrometheusExporter(t *testing.T) {
+	exporter := NewPrometheusExporter()
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithStatsSink(exporter.Sink("users")))
+	l.Load("a")
+	l.Load("a")
+
+	rec := httptest.NewRecorder()
+	exporter.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, `cache_loader_hits_total{loader="users"} 1`)
+	assert.Contains(t, body, `cache_loader_misses_total{loader="users"} 1`)
+	assert.Contains(t, body, `cache_loader_fetch_duration_seconds_bucket{loader="users",le="+Inf"} 1`)
+	assert.Contains(t, body, `cache_loader_fetch_duration_seconds_count{loader="users"} 1`)
+}
+
+func TestStatsDSink(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	sink, err := NewStatsDSink(server.LocalAddr().String(), "users", WithStatsDFlushInterval(time.Hour))
+	assert.NoError(t, err)
+
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithStatsSink(sink))
+	l.Load("a")
+	l.Load("a")
+	l.Load("b")
+	assert.NoError(t, sink.Close(), "Close must flush the metrics")
+
+	buf := make([]byte, 2048)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(buf[:n]), "\n")
+	assert.Len(t, lines, 4, "metrics must be sent in one packet")
+	sort.Strings(lines)
+	assert.Equal(t, "users.hit:1|c", lines[2])
+	assert.Equal(t, "users.miss:2|c", lines[3], "counters must be aggregated")
+	assert.True(t, strings.HasPrefix(lines[0], "users.fetch:") && strings.HasSuffix(lines[0], "|ms"))
+}
+
+func TestStatsDFlushInterval(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	sink, err := NewStatsDSink(server.LocalAddr().String(), "users", WithStatsDFlushInterval(time.Millisecond))
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	for i := 0; i < 1000; i++ {
+		sink.ObserveFetch(time.Millisecond, nil)
+	}
+	buf := make([]byte, 2048)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.LessOrEqual(t, n, statsDMaxPacket, "packets must fit the MTU")
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "users.fetch:1.000|ms\n"))
+}
+
+func TestStatsDTags(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	sink, err := NewStatsDSink(server.LocalAddr().String(), "cache", WithStatsDTags(), WithLoaderTags("users", "v2"), WithStatsDTags(), WithStatsDTags("env:test"))
+	assert.NoError(t, err)
+
+	sink.IncHit()
+	assert.NoError(t, sink.Close())
+	buf := make([]byte, 512)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "cache.hit:1|c|#loader:users,namespace:v2,env:test", string(buf[:n]))
+}
+
+func TestStatsDNoTags(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	sink, err := NewStatsDSink(server.LocalAddr().String(), "cache", WithStatsDTags())
+	assert.NoError(t, err)
+
+	sink.IncHit()
+	assert.NoError(t, sink.Close())
+	buf := make([]byte, 512)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "cache.hit:1|c", string(buf[:n]))
+}
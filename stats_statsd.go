@@ -0,0 +1,170 @@
+package loader
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsDMaxPacket keeps the packets under the usual MTU, so they aren't fragmented
+const statsDMaxPacket = 1432
+
+// statsDMaxTimings bounds the fetch timings buffered between flushes, the ones beyond are dropped
+const statsDMaxTimings = 10000
+
+// StatsDSink is StatsSink that sends the events to a StatsD server over UDP.
+// Counters are aggregated and timings are buffered, and a goroutine flushes them every interval
+// in packets of several metrics, so the loads don't wait for a syscall. Write errors are ignored like any StatsD client does.
+type StatsDSink struct {
+	conn   net.Conn
+	prefix string
+	// suffix holds the DogStatsD tags
+	suffix   string
+	interval time.Duration
+
+	hits, misses, refreshes, oversized, fetchErrors atomic.Uint64
+
+	mutex   sync.Mutex
+	timings []float64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// StatsDOption configures StatsDSink
+type StatsDOption func(s *StatsDSink)
+
+// WithStatsDTags adds DogStatsD tags, such as "env:prod", to every metric, it does nothing without tags
+func WithStatsDTags(tags ...string) StatsDOption {
+	return func(s *StatsDSink) {
+		if len(tags) == 0 {
+			return
+		}
+		if s.suffix == "" {
+			s.suffix = "|#" + strings.Join(tags, ",")
+		} else {
+			s.suffix += "," + strings.Join(tags, ",")
+		}
+	}
+}
+
+// WithLoaderTags adds DogStatsD tags of the loader name and key namespace, like Registry and WithNamespace use
+func WithLoaderTags(name, namespace string) StatsDOption {
+	tags := []string{"loader:" + name}
+	if namespace != "" {
+		tags = append(tags, "namespace:"+namespace)
+	}
+	return WithStatsDTags(tags...)
+}
+
+// WithStatsDFlushInterval sets how often the metrics are sent, the default is 1 second
+func WithStatsDFlushInterval(interval time.Duration) StatsDOption {
+	return func(s *StatsDSink) {
+		s.interval = interval
+	}
+}
+
+// NewStatsDSink creates StatsDSink that sends the metrics named prefix.hit, prefix.miss, etc. to addr.
+// Use WithStatsDTags or WithLoaderTags for Datadog. Close flushes the remaining metrics.
+func NewStatsDSink(addr, prefix string, options ...StatsDOption) (*StatsDSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &StatsDSink{
+		conn:     conn,
+		prefix:   prefix + ".",
+		interval: time.Second,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, o := range options {
+		o(s)
+	}
+	go s.flushPeriodically()
+	return s, nil
+}
+
+func (s *StatsDSink) IncHit()       { s.hits.Add(1) }
+func (s *StatsDSink) IncMiss()      { s.misses.Add(1) }
+func (s *StatsDSink) IncRefresh()   { s.refreshes.Add(1) }
+func (s *StatsDSink) IncOversized() { s.oversized.Add(1) }
+
+func (s *StatsDSink) ObserveFetch(d time.Duration, err error) {
+	s.mutex.Lock()
+	if len(s.timings) < statsDMaxTimings {
+		s.timings = append(s.timings, float64(d)/float64(time.Millisecond))
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		s.fetchErrors.Add(1)
+	}
+}
+
+func (s *StatsDSink) flushPeriodically() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush sends the metrics since the last flush, packing as many lines as fit into each packet
+func (s *StatsDSink) flush() {
+	var packet []byte
+	add := func(metric string) {
+		line := s.prefix + metric + s.suffix
+		if len(packet) > 0 && len(packet)+1+len(line) > statsDMaxPacket {
+			s.conn.Write(packet)
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	counters := []struct {
+		name    string
+		counter *atomic.Uint64
+	}{
+		{"hit", &s.hits},
+		{"miss", &s.misses},
+		{"refresh", &s.refreshes},
+		{"oversized", &s.oversized},
+		{"fetch_error", &s.fetchErrors},
+	}
+	for _, c := range counters {
+		if n := c.counter.Swap(0); n > 0 {
+			add(c.name + ":" + strconv.FormatUint(n, 10) + "|c")
+		}
+	}
+
+	s.mutex.Lock()
+	timings := s.timings
+	s.timings = nil
+	s.mutex.Unlock()
+	for _, ms := range timings {
+		add("fetch:" + strconv.FormatFloat(ms, 'f', 3, 64) + "|ms")
+	}
+	if len(packet) > 0 {
+		s.conn.Write(packet)
+	}
+}
+
+// Close sends the remaining metrics and closes the UDP connection
+func (s *StatsDSink) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return s.conn.Close()
+}
@@ -0,0 +1,270 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StreamFetcher fetches large value as a stream, StreamLoader closes the reader
+type StreamFetcher[Key comparable] func(ctx context.Context, key Key) (io.ReadCloser, error)
+
+// BlobStore stores the content of StreamLoader outside of the process memory, such as temp files or object storage
+type BlobStore interface {
+	// Put stores the content of r and returns its id
+	Put(r io.Reader) (id string, err error)
+	Open(id string) (io.ReadCloser, error)
+	Delete(id string) error
+}
+
+type dirBlobStore struct {
+	dir string
+}
+
+// DirBlobStore creates BlobStore that stores blobs as files in dir, or in the temp dir if it's empty
+func DirBlobStore(dir string) BlobStore {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return &dirBlobStore{dir: dir}
+}
+
+func (s *dirBlobStore) Put(r io.Reader) (string, error) {
+	f, err := os.CreateTemp(s.dir, "cache-loader-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return filepath.Base(f.Name()), nil
+}
+
+func (s *dirBlobStore) Open(id string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, id))
+}
+
+func (s *dirBlobStore) Delete(id string) error {
+	return os.Remove(filepath.Join(s.dir, id))
+}
+
+// blobRef is the cached value of StreamLoader
+type blobRef struct {
+	ID   string
+	Size int64
+}
+
+// StreamLoader caches blob-like values in BlobStore, so callers can stream them without holding them in memory.
+// The blob of a key is deleted when the key is fetched again, invalidated, evicted by the driver, or when the loader is closed,
+// but not before the readers that opened it are closed.
+type StreamLoader[Key comparable] struct {
+	loader *Loader[Key, blobRef]
+	store  BlobStore
+
+	mutex sync.Mutex
+	// current is the blob of each cached key, blobs are the ones that aren't deleted yet
+	current map[Key]string
+	blobs   map[string]*blob
+}
+
+// blob counts the readers of a stored blob, it's deleted once it's retired and the last reader is closed
+type blob struct {
+	readers int
+	retired bool
+}
+
+// NewStreamLoader creates StreamLoader, options are applied to the underlying Loader
+func NewStreamLoader[Key comparable](fn StreamFetcher[Key], ttl time.Duration, store BlobStore, options ...Option) *StreamLoader[Key] {
+	s := &StreamLoader[Key]{store: store, current: map[Key]string{}, blobs: map[string]*blob{}}
+	options = append([]Option{OptionT[Key, blobRef](func(l *Loader[Key, blobRef]) {
+		l.onEvict = s.evicted
+	})}, options...)
+	s.loader = New(func(ctx context.Context, key Key) (blobRef, error) {
+		return s.fetch(ctx, fn, key)
+	}, ttl, options...)
+	return s
+}
+
+func (s *StreamLoader[Key]) fetch(ctx context.Context, fn StreamFetcher[Key], key Key) (blobRef, error) {
+	r, err := fn(ctx, key)
+	if err != nil {
+		return blobRef{}, err
+	}
+	defer r.Close()
+
+	counter := &countingReader{r: r}
+	id, err := s.store.Put(counter)
+	if err != nil {
+		return blobRef{}, err
+	}
+
+	s.mutex.Lock()
+	old, ok := s.current[key]
+	s.current[key] = id
+	s.blobs[id] = &blob{}
+	s.mutex.Unlock()
+	if ok {
+		s.retire(old)
+	}
+	return blobRef{ID: id, Size: counter.n}, nil
+}
+
+// Load opens the cached content of key, the caller must close it
+func (s *StreamLoader[Key]) Load(ctx context.Context, key Key) (io.ReadCloser, int64, error) {
+	ref, err := s.loader.LoadCtx(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !s.acquire(ref.ID) {
+		// the blob was replaced by concurrent refresh
+		ref, err = s.loader.LoadCtx(ctx, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !s.acquire(ref.ID) {
+			return nil, 0, fmt.Errorf("loader: blob of %v: %w", key, fs.ErrNotExist)
+		}
+	}
+	r, err := s.store.Open(ref.ID)
+	if err != nil {
+		s.release(ref.ID)
+		return nil, 0, err
+	}
+	return &blobReader{ReadCloser: r, release: func() { s.release(ref.ID) }}, ref.Size, nil
+}
+
+// Invalidate removes key from the cache and deletes its blob
+func (s *StreamLoader[Key]) Invalidate(key Key) error {
+	if err := s.loader.Invalidate(key); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	id, ok := s.current[key]
+	delete(s.current, key)
+	s.mutex.Unlock()
+	if ok {
+		return s.retire(id)
+	}
+	return nil
+}
+
+// evicted retires the blob of the item evicted by the driver, unless the key has been fetched again
+func (s *StreamLoader[Key]) evicted(key Key, item *cacheItem[blobRef]) {
+	p := item.payload.Load()
+	if p == nil || p.err != nil {
+		return
+	}
+	s.mutex.Lock()
+	current := s.current[key] == p.value.ID
+	if current {
+		delete(s.current, key)
+	}
+	s.mutex.Unlock()
+	if current {
+		s.retire(p.value.ID)
+	}
+}
+
+// acquire counts a reader of the blob, it reports false if the blob is deleted or about to be
+func (s *StreamLoader[Key]) acquire(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	b, ok := s.blobs[id]
+	if !ok || b.retired {
+		return false
+	}
+	b.readers++
+	return true
+}
+
+func (s *StreamLoader[Key]) release(id string) {
+	s.mutex.Lock()
+	b := s.blobs[id]
+	b.readers--
+	deleted := b.retired && b.readers == 0
+	if deleted {
+		delete(s.blobs, id)
+	}
+	s.mutex.Unlock()
+	if deleted {
+		s.store.Delete(id)
+	}
+}
+
+// retire deletes the blob once it has no readers, new readers can't open it anymore
+func (s *StreamLoader[Key]) retire(id string) error {
+	s.mutex.Lock()
+	b, ok := s.blobs[id]
+	if !ok {
+		s.mutex.Unlock()
+		return nil
+	}
+	b.retired = true
+	deleted := b.readers == 0
+	if deleted {
+		delete(s.blobs, id)
+	}
+	s.mutex.Unlock()
+	if deleted {
+		return s.store.Delete(id)
+	}
+	return nil
+}
+
+// Close closes the underlying Loader, waits for its background refreshes, and deletes every blob,
+// the ones that are still being read are deleted when their readers are closed.
+// StreamLoader must not be used after it's closed.
+func (s *StreamLoader[Key]) Close() error {
+	s.loader.Close()
+	s.loader.Wait()
+
+	s.mutex.Lock()
+	ids := make([]string, 0, len(s.blobs))
+	for id := range s.blobs {
+		ids = append(ids, id)
+	}
+	s.current = map[Key]string{}
+	s.mutex.Unlock()
+	var first error
+	for _, id := range ids {
+		if err := s.retire(id); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// blobReader releases the blob when it's closed
+type blobReader struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *blobReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
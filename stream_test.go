@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLoader(t *testing.T) {
+	dir := t.TempDir()
+	fetches := 0
+	fetch := func(ctx context.Context, key string) (io.ReadCloser, error) {
+		fetches++
+		return io.NopCloser(strings.NewReader(strings.Repeat(key, 1000))), nil
+	}
+	l := NewStreamLoader(fetch, time.Minute, DirBlobStore(dir))
+
+	for i := 0; i < 2; i++ {
+		r, size, err := l.Load(context.Background(), "ab")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2000), size)
+		content, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, strings.Repeat("ab", 1000), string(content))
+	}
+	assert.Equal(t, 1, fetches)
+
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	assert.NoError(t, l.Invalidate("ab"))
+	files, _ = os.ReadDir(dir)
+	assert.Empty(t, files, "invalidated blob must be deleted")
+
+	r, _, _ := l.Load(context.Background(), "cd")
+	r.Close()
+	assert.NoError(t, l.Close())
+	files, _ = os.ReadDir(dir)
+	assert.Empty(t, files, "Close must delete blobs")
+}
+
+func TestStreamLoaderBlobLifetime(t *testing.T) {
+	dir := t.TempDir()
+	fetch := func(ctx context.Context, key string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(key)), nil
+	}
+	l := NewStreamLoader(fetch, time.Minute, DirBlobStore(dir), WithDriver(BoundedInMemoryCache(1)))
+	blobs := func() int {
+		files, _ := os.ReadDir(dir)
+		return len(files)
+	}
+
+	r, _, err := l.Load(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.NoError(t, l.Invalidate("a"))
+	assert.Equal(t, 1, blobs(), "blob must not be deleted while it's being read")
+	content, _ := io.ReadAll(r)
+	assert.Equal(t, "a", string(content))
+	assert.NoError(t, r.Close())
+	r.Close()
+	assert.Equal(t, 0, blobs(), "blob must be deleted when its last reader is closed")
+
+	r, _, _ = l.Load(context.Background(), "b")
+	r.Close()
+	r, _, _ = l.Load(context.Background(), "c")
+	r.Close()
+	assert.Equal(t, 1, blobs(), "blob of the evicted key must be deleted")
+
+	r, _, _ = l.Load(context.Background(), "c")
+	assert.NoError(t, l.Close())
+	assert.Equal(t, 1, blobs(), "Close must keep the blobs that are being read")
+	r.Close()
+	assert.Equal(t, 0, blobs())
+}
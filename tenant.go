@@ -0,0 +1,191 @@
+package loader
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithTenants partitions the cache by the tenant of each key, each tenant has its own bounded cache
+// holding items up to limit cost (the number of items, unless WithCost is used).
+// So one noisy tenant can't evict the items of other tenants. It replaces the driver,
+// so it can't be combined with WithDriver or the constructors that set one, like NewLRU.
+func WithTenants[Key comparable](tenantOf func(key Key) string, limit int64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.tenantOf = tenantOf
+		cfg.tenantLimit = limit
+	})
+}
+
+// tenancy tracks the stats of each tenant
+type tenancy[Key comparable] struct {
+	tenantOf func(key Key) string
+	driver   *tenantDriver
+
+	mutex    sync.RWMutex
+	counters map[string]*counters
+}
+
+func (t *tenancy[Key]) tenantCounters(key Key) *counters {
+	tenant := t.tenantOf(key)
+
+	t.mutex.RLock()
+	c, ok := t.counters[tenant]
+	t.mutex.RUnlock()
+	if ok {
+		return c
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if c, ok = t.counters[tenant]; !ok {
+		c = &counters{}
+		t.counters[tenant] = c
+	}
+	return c
+}
+
+// checkTenants rejects WithTenants with WithDriver, the driver would be dropped silently
+func (l *Loader[Key, Value]) checkTenants() error {
+	if l.tenantOf != nil && l.customDriver {
+		return fmt.Errorf("%w: WithTenants can't be used with WithDriver, it replaces the driver", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// setupTenants replaces the driver with tenant partitioned one, if WithTenants is used
+func (l *Loader[Key, Value]) setupTenants(tenantOf func(Key) string) {
+	if tenantOf == nil {
+		return
+	}
+	driver := newTenantDriver(func(dk interface{}) string {
+		key, ok := l.loaderKey(dk)
+		if !ok {
+			return ""
+		}
+		return tenantOf(key)
+	}, l.tenantLimit)
+	l.driver = driver
+	l.tenants = &tenancy[Key]{tenantOf: tenantOf, driver: driver, counters: map[string]*counters{}}
+}
+
+// TenantStats returns the stats of each tenant, it's empty if WithTenants is not used
+func (l *Loader[Key, Value]) TenantStats() map[string]Stats {
+	stats := map[string]Stats{}
+	if l.tenants == nil {
+		return stats
+	}
+
+	l.tenants.mutex.RLock()
+	defer l.tenants.mutex.RUnlock()
+	for tenant, c := range l.tenants.counters {
+		stats[tenant] = Stats{
+			Hits:   c.hits.Load(),
+			Misses: c.misses.Load(),
+			Cost:   l.tenants.driver.tenantCost(tenant),
+		}
+	}
+	return stats
+}
+
+// tenantDriver is a cache driver with one BoundedInMemoryCache per tenant
+type tenantDriver struct {
+	tenantOf func(dk interface{}) string
+	limit    int64
+
+	mutex      sync.RWMutex
+	partitions map[string]*boundedCache
+	listener   EvictionListener
+}
+
+func newTenantDriver(tenantOf func(dk interface{}) string, limit int64) *tenantDriver {
+	return &tenantDriver{tenantOf: tenantOf, limit: limit, partitions: map[string]*boundedCache{}}
+}
+
+func (d *tenantDriver) partition(key interface{}) *boundedCache {
+	tenant := d.tenantOf(key)
+
+	d.mutex.RLock()
+	p, ok := d.partitions[tenant]
+	d.mutex.RUnlock()
+	if ok {
+		return p
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if p, ok = d.partitions[tenant]; !ok {
+		p = BoundedInMemoryCache(d.limit).(*boundedCache)
+		p.OnEvict(d.listener.Evicted)
+		d.partitions[tenant] = p
+	}
+	return p
+}
+
+// Add implements CacheDriver
+func (d *tenantDriver) Add(key interface{}, value interface{}) {
+	d.partition(key).Add(key, value)
+}
+
+// Get implements CacheDriver
+func (d *tenantDriver) Get(key interface{}) (interface{}, bool) {
+	return d.partition(key).Get(key)
+}
+
+// peek implements peeker
+func (d *tenantDriver) peek(key interface{}) (interface{}, bool) {
+	return d.partition(key).peek(key)
+}
+
+// Remove implements Remover
+func (d *tenantDriver) Remove(key interface{}) {
+	d.partition(key).Remove(key)
+}
+
+func (d *tenantDriver) all() []*boundedCache {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	partitions := make([]*boundedCache, 0, len(d.partitions))
+	for _, p := range d.partitions {
+		partitions = append(partitions, p)
+	}
+	return partitions
+}
+
+// Range implements Ranger
+func (d *tenantDriver) Range(fn func(key, value interface{}) bool) {
+	next := true
+	for _, p := range d.all() {
+		p.Range(func(key, value interface{}) bool {
+			next = fn(key, value)
+			return next
+		})
+		if !next {
+			return
+		}
+	}
+}
+
+// Cost implements CostReporter
+func (d *tenantDriver) Cost() int64 {
+	var total int64
+	for _, p := range d.all() {
+		total += p.Cost()
+	}
+	return total
+}
+
+func (d *tenantDriver) tenantCost(tenant string) int64 {
+	d.mutex.RLock()
+	p, ok := d.partitions[tenant]
+	d.mutex.RUnlock()
+	if !ok {
+		return 0
+	}
+	return p.Cost()
+}
+
+// OnEvict implements EvictionNotifier
+func (d *tenantDriver) OnEvict(fn func(key, value interface{})) (cancel func()) {
+	return d.listener.OnEvict(fn)
+}
@@ -0,0 +1,66 @@
+package loader
+
+import "time"
+
+// TheineCache is the part of theine.Cache[any, any] (github.com/Yiling-J/theine-go) used by NewTheine
+type TheineCache interface {
+	Get(key interface{}) (interface{}, bool)
+	SetWithTTL(key interface{}, value interface{}, cost int64, ttl time.Duration) bool
+	Delete(key interface{})
+	Range(fn func(key, value interface{}) bool)
+}
+
+// theineDriver passes the cost and the hard expiry of the items to theine, re-adding them after every fetch
+type theineDriver struct {
+	cache TheineCache
+}
+
+// writeThrough implements writeThroughDriver, so the cost and the TTL are renewed by refreshes
+func (d theineDriver) writeThrough() {}
+
+// Add implements CacheDriver
+func (d theineDriver) Add(key interface{}, value interface{}) {
+	ttl, expired := hardTTL(value)
+	if expired {
+		d.cache.Delete(key)
+		return
+	}
+	// zero ttl never expires
+	d.cache.SetWithTTL(key, value, itemCost(value), ttl)
+}
+
+// Get implements CacheDriver
+func (d theineDriver) Get(key interface{}) (interface{}, bool) {
+	return d.cache.Get(key)
+}
+
+// Remove implements Remover
+func (d theineDriver) Remove(key interface{}) {
+	d.cache.Delete(key)
+}
+
+// Range implements Ranger
+func (d theineDriver) Range(fn func(key, value interface{}) bool) {
+	d.cache.Range(fn)
+}
+
+// NewTheine creates Loader with theine based cache, a W-TinyLFU cache bounded by the total cost.
+// The cost of WithCost and the hard expiry of every entry are passed to theine,
+// which removes the hard expired entries proactively instead of waiting for them to be evicted.
+// Relay the evictions of theine with EvictionListener, so the loader sends EventEvict for them:
+//
+//	evictions := &loader.EvictionListener{}
+//	cache, err := theine.NewBuilder[any, any](10_000).
+//		RemovalListener(func(key, value any, reason theine.RemoveReason) {
+//			if reason != theine.REMOVED {
+//				evictions.Evicted(key, value)
+//			}
+//		}).Build()
+//	users := loader.NewTheine(fetchUser, time.Minute, cache, loader.WithHardTTL(time.Hour), loader.WithCost(userSize),
+//		loader.WithEvictionListener(evictions))
+//
+// Entries of loaders without WithHardTTL never expire in theine, they're evicted for capacity only.
+func NewTheine[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, cache TheineCache, options ...Option) *Loader[Key, Value] {
+	options = append(options, WithDriver(theineDriver{cache}))
+	return New(fn, ttl, options...)
+}
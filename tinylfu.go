@@ -0,0 +1,95 @@
+package loader
+
+import "sync"
+
+// tinyLFU estimates key frequencies with a count-min sketch of 4-bit counters.
+// Counters are halved periodically, so the frequency reflects recent accesses.
+type tinyLFU struct {
+	mutex     sync.Mutex
+	counters  [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+const (
+	sketchDepth   = 4
+	maxSketchFreq = 15
+)
+
+// WithTinyLFU makes BoundedInMemoryCache admit a new item only if it's accessed more frequently than the item it would evict,
+// so one-hit-wonder keys don't push out hot entries. samples is the expected number of distinct keys.
+// Rejected items are not cached, so loading them calls the fetcher until they become popular enough.
+// Every Get counts as an access, hit or miss, while Add doesn't, so a load counts once.
+func WithTinyLFU(samples int) BoundedCacheOption {
+	return func(c *boundedCache) {
+		c.admission = newTinyLFU(samples)
+	}
+}
+
+func newTinyLFU(samples int) *tinyLFU {
+	width := 16
+	for width < samples {
+		width <<= 1
+	}
+	t := &tinyLFU{mask: uint64(width - 1), resetAt: width * 10}
+	for i := range t.counters {
+		t.counters[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// index returns the counter index of hash h in row i, using double hashing
+func (t *tinyLFU) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|1)) & t.mask
+}
+
+// increment records an access to key
+func (t *tinyLFU) increment(key interface{}) {
+	h := hashKey(key)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i := range t.counters {
+		idx := t.index(h, i)
+		if t.counters[i][idx] < maxSketchFreq {
+			t.counters[i][idx]++
+		}
+	}
+	t.additions++
+	if t.additions >= t.resetAt {
+		t.reset()
+	}
+}
+
+// reset halves all counters, the caller must hold the mutex
+func (t *tinyLFU) reset() {
+	for i := range t.counters {
+		for j := range t.counters[i] {
+			t.counters[i][j] >>= 1
+		}
+	}
+	t.additions /= 2
+}
+
+// estimate returns the estimated access frequency of key
+func (t *tinyLFU) estimate(key interface{}) uint8 {
+	h := hashKey(key)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	freq := uint8(maxSketchFreq)
+	for i := range t.counters {
+		if c := t.counters[i][t.index(h, i)]; c < freq {
+			freq = c
+		}
+	}
+	return freq
+}
+
+// admit reports whether candidate is worth evicting victim
+func (t *tinyLFU) admit(candidate, victim interface{}) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
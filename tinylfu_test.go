@@ -0,0 +1,51 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTinyLFUAdmission(t *testing.T) {
+	c := BoundedInMemoryCache(3, WithTinyLFU(100))
+	for _, key := range []string{"a", "b", "c"} {
+		c.Add(key, key)
+		for i := 0; i < 5; i++ {
+			c.Get(key)
+		}
+	}
+
+	// a scan of one-hit-wonders must not evict the hot keys
+	for i := 0; i < 20; i++ {
+		c.Add(fmt.Sprint("scan", i), i)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		_, ok := c.Get(key)
+		assert.True(t, ok, "hot key %s must stay in cache", key)
+	}
+
+	// a key that becomes popular is admitted
+	for i := 0; i < 10; i++ {
+		c.Get("d")
+	}
+	c.Add("d", "d")
+	_, ok := c.Get("d")
+	assert.True(t, ok, "popular key must be admitted")
+}
+
+func TestTinyLFUCountsLoadsOnce(t *testing.T) {
+	driver := BoundedInMemoryCache(10, WithTinyLFU(100))
+	l := New(func(ctx context.Context, key string) (string, error) {
+		return key, nil
+	}, time.Minute, WithDriver(driver), WithCost(func(value string) int64 { return 1 }))
+	defer l.Close()
+	sketch := driver.(*boundedCache).admission
+
+	l.Load("a")
+	assert.Equal(t, uint8(1), sketch.estimate("a"), "a miss must count once")
+	l.Load("a")
+	assert.Equal(t, uint8(2), sketch.estimate("a"), "a hit must count once")
+}
@@ -0,0 +1,44 @@
+//go:build go1.20
+
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// LoadFunc is the fetcher of Untyped, the signature of the interface{} based API
+type LoadFunc func(key interface{}) (interface{}, error)
+
+// Untyped is the interface{} based API on top of Loader, for code that can't use generics, like Get of the pre-generics version.
+// Keys must hold comparable values, Get panics like a map does otherwise.
+// It needs go1.20, the first release where interface{} satisfies comparable.
+type Untyped struct {
+	*Loader[interface{}, interface{}]
+}
+
+// NewUntyped creates Untyped that fetches with fn, options are the same as New
+func NewUntyped(fn LoadFunc, ttl time.Duration, options ...Option) *Untyped {
+	return &Untyped{New(untypedFetcher(fn), ttl, options...)}
+}
+
+// NewUntypedLRU creates Untyped with lru based cache, like NewLRU
+func NewUntypedLRU(fn LoadFunc, ttl time.Duration, size int, options ...Option) *Untyped {
+	return &Untyped{NewLRU(untypedFetcher(fn), ttl, size, options...)}
+}
+
+// Get the item, it works like Load. Unlike Loader.Get it isn't deprecated, it's the main method of the facade.
+func (u *Untyped) Get(key interface{}) (interface{}, error) {
+	return u.Load(key)
+}
+
+// GetCtx is the context aware Get, it works like LoadCtx
+func (u *Untyped) GetCtx(ctx context.Context, key interface{}) (interface{}, error) {
+	return u.LoadCtx(ctx, key)
+}
+
+func untypedFetcher(fn LoadFunc) Fetcher[interface{}, interface{}] {
+	return func(ctx context.Context, key interface{}) (interface{}, error) {
+		return fn(key)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build go1.20
+
+package loader
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUntyped(t *testing.T) {
+	fetches := 0
+	l := NewUntypedLRU(func(key interface{}) (interface{}, error) {
+		fetches++
+		if key == nil {
+			return nil, fmt.Errorf("nil key")
+		}
+		return fmt.Sprint("value ", key), nil
+	}, time.Minute, 10)
+
+	val, err := l.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "value a", val)
+	val, _ = l.Get(1)
+	assert.Equal(t, "value 1", val)
+	l.Get("a")
+	assert.Equal(t, 2, fetches, "keys of different types must be cached separately")
+
+	_, err = l.Get(nil)
+	assert.Error(t, err)
+
+	l.Invalidate("a")
+	l.Get("a")
+	assert.Equal(t, 4, fetches, "generic methods must work on the same cache")
+}
@@ -0,0 +1,94 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidConfig is wrapped by the errors of NewE, and by the panics of New
+var ErrInvalidConfig = errors.New("loader: invalid config")
+
+// NewE works like New, but it validates the options and returns the error instead of panicking.
+// Unlike New, it also rejects nonsensical values, like a nil fetcher, a nil driver, or negative durations.
+func NewE[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, options ...Option) (*Loader[Key, Value], error) {
+	if fn == nil {
+		return nil, fmt.Errorf("%w: fetcher must not be nil", ErrInvalidConfig)
+	}
+	cfg := newConfig(ttl, options)
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return newLoader(fn, cfg)
+}
+
+// NewLRUE works like NewLRU, but it returns the errors of NewE and of invalid size instead of panicking
+func NewLRUE[Key comparable, Value any](fn Fetcher[Key, Value], ttl time.Duration, size int, options ...Option) (*Loader[Key, Value], error) {
+	cache, err := newLRUWrapper(size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: lru size %d: %v", ErrInvalidConfig, size, err)
+	}
+	options = append(options, WithDriver(cache))
+	return NewE(fn, ttl, options...)
+}
+
+// validate checks the values of the options, the checks that need the loader types are done by newLoader
+func (cfg *config) validate() error {
+	if cfg.driver == nil {
+		return fmt.Errorf("%w: driver must not be nil", ErrInvalidConfig)
+	}
+	if cfg.cf == nil {
+		return fmt.Errorf("%w: context factory must not be nil", ErrInvalidConfig)
+	}
+	if cfg.sink == nil {
+		return fmt.Errorf("%w: stats sink must not be nil", ErrInvalidConfig)
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ttl", cfg.ttl},
+		{"WithErrorTTL", cfg.errTtl},
+		{"WithHardTTL", cfg.hardTTL},
+		{"WithNotFoundTTL", cfg.notFoundTtl},
+		{"WithMinRefreshInterval", cfg.minRefreshInterval},
+		{"WithRefreshAhead", cfg.refreshAhead},
+		{"WithInvalidationDebounce", cfg.invalidationDebounce},
+		{"WithBatchWindow", cfg.batchWindow},
+		{"WithPeriodicRefresh", cfg.periodicRefresh},
+		{"WithDriverTimeout", cfg.driverTimeout},
+		{"WithStartupSmear", cfg.startupSmear},
+		{"WithLockDebug", cfg.lockDebug},
+		{"WithRefreshLease", cfg.leaseTTL},
+		{"WithInvalidationSource retry", cfg.invalidationRetry},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("%w: %s must not be negative, got %v", ErrInvalidConfig, d.name, d.value)
+		}
+	}
+	if cfg.hardTTL > 0 && cfg.hardTTL < cfg.ttl {
+		return fmt.Errorf("%w: WithHardTTL %v must not be shorter than ttl %v", ErrInvalidConfig, cfg.hardTTL, cfg.ttl)
+	}
+	counts := []struct {
+		name  string
+		value int64
+	}{
+		{"WithRefreshWorkers", int64(cfg.refreshWorkers)},
+		{"WithMaxRefetchGoroutines", int64(cfg.maxRefetchGoroutines)},
+		{"WithBatchWindow size", int64(cfg.batchSize)},
+		{"WithPeriodicRefresh concurrency", int64(cfg.periodicConcurrency)},
+		{"WithRefreshErrorThreshold window", int64(cfg.refreshErrorWindow)},
+		{"WithMaxValueSize", cfg.maxValueSize},
+		{"WithTenants limit", cfg.tenantLimit},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			return fmt.Errorf("%w: %s must not be negative, got %d", ErrInvalidConfig, c.name, c.value)
+		}
+	}
+	if cfg.refreshErrorWindow > 0 && (cfg.maxRefreshErrorRate < 0 || cfg.maxRefreshErrorRate > 1) {
+		return fmt.Errorf("%w: WithRefreshErrorThreshold rate must be between 0 and 1, got %v", ErrInvalidConfig, cfg.maxRefreshErrorRate)
+	}
+	return nil
+}
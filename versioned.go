@@ -0,0 +1,46 @@
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// FetcherVersioned fetches the value of key with its version, like a row version or an update counter,
+// which must grow with every change of the value
+type FetcherVersioned[Key comparable, Value any] func(ctx context.Context, key Key) (Value, uint64, error)
+
+// NewVersioned creates Loader whose fetcher stamps the values with versions.
+// A fetch that returns an older version than the cached value is discarded and the cached value is kept,
+// e.g. a refresh that read a lagging replica after a concurrent fetch read the update.
+// The stamps are kept by RemoteCache, and WithReadRepair compares them instead of the fetch times.
+func NewVersioned[Key comparable, Value any](fn FetcherVersioned[Key, Value], ttl time.Duration, options ...Option) *Loader[Key, Value] {
+	options = append(options, optionFunc(func(cfg *config) {
+		cfg.versioned = true
+	}))
+	return New(func(ctx context.Context, key Key) (Value, error) {
+		value, stamp, err := fn(ctx, key)
+		if rv, _ := ctx.Value(revalidationKey{}).(*revalidation[Value]); rv != nil && err == nil {
+			rv.stamp = stamp
+			rv.stamped = true
+		}
+		return value, err
+	}, ttl, options...)
+}
+
+// olderStamp returns true if the value of item has newer stamp, counting the conflict
+func (l *Loader[Key, Value]) olderStamp(item *cacheItem[Value], stamp uint64) bool {
+	cur := item.payload.Load()
+	if cur == nil || cur.err != nil || cur.stamp <= stamp {
+		return false
+	}
+	l.counters.versionConflicts.Add(1)
+	return true
+}
+
+// newerThan returns true if p is a later entry of the key than other, by stamp if both have one, or by fetch time
+func (p *payload[Value]) newerThan(other *payload[Value]) bool {
+	if p.stamp != 0 && other.stamp != 0 {
+		return p.stamp > other.stamp
+	}
+	return p.fetchedAt.After(other.fetchedAt)
+}
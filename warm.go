@@ -0,0 +1,103 @@
+package loader
+
+import (
+	"context"
+	"time"
+)
+
+// WithWarmKeys sets the keys loaded by WarmUp, e.g. the most popular items.
+func WithWarmKeys[Key comparable](keys func(ctx context.Context) ([]Key, error)) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.warmKeys = keys
+	})
+}
+
+// Warm loads the keys into the cache, it returns the first error.
+func (l *Loader[Key, Value]) Warm(ctx context.Context, keys ...Key) error {
+	ctx = withTriggerReason(ctx, FetchWarmUp)
+	var firstErr error
+	for _, key := range keys {
+		if _, err := l.LoadCtx(ctx, key); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WithFetchAll bulk populates the cache with fetchAll when the loader is created and every interval
+// (or only by Preload and WarmUp if it's zero). The items expire and are refreshed key by key like fetched ones,
+// so the Fetcher is only used for misses and refreshes between preloads.
+func WithFetchAll[Key comparable, Value any](fetchAll func(ctx context.Context) (map[Key]Value, error), interval time.Duration) OptionT[Key, Value] {
+	return func(l *Loader[Key, Value]) {
+		l.fetchAll = fetchAll
+		if l.startBackground() {
+			go l.preloadPeriodically(interval)
+		}
+	}
+}
+
+// Preload adds every value from the function of WithFetchAll into the cache
+func (l *Loader[Key, Value]) Preload(ctx context.Context) error {
+	if l.fetchAll == nil {
+		return nil
+	}
+	values, err := l.fetchAll(ctx)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		l.PrimeCtx(ctx, key, value)
+	}
+	return nil
+}
+
+// Prime adds value of key into the cache as if it were fetched, replacing the cached one
+func (l *Loader[Key, Value]) Prime(key Key, value Value) {
+	l.PrimeCtx(context.Background(), key, value)
+}
+
+// PrimeCtx is Prime with the context that carries WithAuditReason
+func (l *Loader[Key, Value]) PrimeCtx(ctx context.Context, key Key, value Value) {
+	key = l.mapKey(key)
+	item := newCacheItem[Value]()
+	l.driver.Add(l.driverKey(key), item)
+	if _, outcome := l.storeValue(key, item, value, nil); outcome == valueCached {
+		l.record(ctx, AuditSet, key, nil)
+	}
+}
+
+func (l *Loader[Key, Value]) preloadPeriodically(interval time.Duration) {
+	defer l.background.Done()
+	ctx := WithAuditReason(l.lifecycle.ctx, "fetch all")
+	l.Preload(ctx)
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.lifecycle.ctx.Done():
+			return
+		case <-ticker.C:
+			l.Preload(ctx)
+		}
+	}
+}
+
+// WarmUp preloads the cache with WithFetchAll and loads the keys from WithWarmKeys,
+// it does nothing if neither option is set.
+func (l *Loader[Key, Value]) WarmUp(ctx context.Context) error {
+	if err := l.Preload(ctx); err != nil {
+		return err
+	}
+	if l.warmKeys == nil {
+		return nil
+	}
+	keys, err := l.warmKeys(ctx)
+	if err != nil {
+		return err
+	}
+	return l.Warm(ctx, keys...)
+}